@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -18,6 +19,13 @@ func main() {
 func handleError(err error) {
 	if err != nil {
 		log.Error(err)
+
+		// 被信号中断、子进程非0退出等错误使用不同的退出码，错误输出到标准错误，不混入子进程的输出
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitErr.ExitCode())
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
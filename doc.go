@@ -0,0 +1,11 @@
+// Package shamir 是 shamir (t, n) 门限秘密共享的公开 API，供其他程序直接嵌入使用
+//
+// 使用 Split 将任意长度的秘密拆分为 n 个份额，任意 t 个份额使用 Combine 可以还原出秘密。
+// 秘密按素数的大小分段加密，最后一段是秘密的 sha256 值，还原时用它校验结果，
+// 份额数量不足、份额损坏或来自不同的秘密时 Combine 返回错误而不是错误的秘密。
+//
+// 每个 Share 包含门限值、持有人的x、y密钥以及必须密钥，使用 MarshalText 和 UnmarshalText
+// 与文本互相转换，也可以使用 Keys 和 ParseKeys 与命令行 shamir encrypt/decrypt 的密钥互相转换。
+//
+// 错误都可以使用 errors.Is 与本包的错误变量比较，与单个份额有关的错误是 *ShareError
+package shamir
@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"shamir/pkg/server"
+	"shamir/pkg/utils/agent"
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/graceful"
+	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/secure"
+	"shamir/pkg/utils/shamir"
+	taskgroup "shamir/pkg/utils/task-group"
+)
+
+const (
+	defaultAgentTTL = time.Hour
+	// agentPidEnv 后台运行的 agent 的进程号，用于 kill $SHAMIR_AGENT_PID 停止 agent
+	agentPidEnv = "SHAMIR_AGENT_PID"
+	// agentDaemonFlag 后台运行时子进程的参数，子进程输出环境变量后关闭标准输入输出
+	agentDaemonFlag = "daemon-child"
+)
+
+type AgentCmdConf struct {
+	socket     string
+	ttl        time.Duration
+	foreground bool
+	daemon     bool
+}
+
+func NewAgentCommand() *cobra.Command {
+	cmd := &cobra.Command{}
+	conf := &AgentCmdConf{}
+	cmd.Use = "agent"
+	cmd.Short = "Run an agent holding shares in locked memory, like ssh-agent"
+	cmd.Long =
+		`Run an agent holding shares in locked memory, like ssh-agent
+
+The agent listens on a unix socket, only processes of the same user can use it.
+Custodians load their shares once with "shamir agent add", then use them in later steps of a ceremony
+without rewriting key files: "shamir decrypt --agent" pulls shares from the agent,
+"shamir agent contribute" lets the agent submit a share to a recovery session of "shamir serve".
+Shares are wiped when they expire, when removed, or when the agent stops.
+
+The agent forks into background like ssh-agent and prints the socket path and pid as shell commands,
+use eval to set $` + agent.SocketEnv + ` for other commands, and kill $` + agentPidEnv + ` to stop it.
+Use -D to run in foreground, the commands are printed to stdout and logs to stderr.`
+	cmd.Example = `eval $(shamir agent)
+shamir agent -D --socket ./agent.sock > agent.env &
+shamir agent add -i /media/usb1 --identity ~/.ssh/id_ed25519
+shamir agent list
+shamir decrypt --agent -t 2
+shamir agent contribute 3f2a9c0d1e4b5a6c --server https://ceremony:8420 --session e9705725214c87d3557a86efdc2aa552 --submit-token-file submit.txt
+shamir agent remove --all
+`
+	cmd.Args = NoArgs
+	cmd.PersistentFlags().StringVar(&conf.socket, "socket", agent.DefaultSocket(), "The unix socket of the agent")
+	cmd.Flags().DurationVar(&conf.ttl, "ttl", defaultAgentTTL, "Shares will be wiped after the ttl unless refreshed")
+	cmd.Flags().BoolVarP(&conf.foreground, "foreground", "D", false, "Run in foreground, do not fork into background")
+	cmd.Flags().BoolVar(&conf.daemon, agentDaemonFlag, false, "Run as the background child process")
+	_ = cmd.Flags().MarkHidden(agentDaemonFlag)
+
+	cmd.AddCommand(newAgentAddCommand(conf))
+	cmd.AddCommand(newAgentListCommand(conf))
+	cmd.AddCommand(newAgentRefreshCommand(conf))
+	cmd.AddCommand(newAgentRemoveCommand(conf))
+	cmd.AddCommand(newAgentContributeCommand(conf))
+
+	cmd.RunE = conf.RunE
+	return cmd
+}
+
+func (a *AgentCmdConf) RunE(cmd *cobra.Command, _ []string) error {
+	if a.ttl <= 0 {
+		return fmt.Errorf("invalid ttl %s", a.ttl)
+	}
+	// 输出的路径在其他目录中也能使用
+	socket, err := filepath.Abs(a.socket)
+	if err != nil {
+		return err
+	}
+	a.socket = socket
+	if !a.foreground && !a.daemon {
+		return a.fork(cmd)
+	}
+
+	listener, err := agent.Listen(a.socket)
+	if err != nil {
+		return err
+	}
+
+	keyring := agent.NewKeyring(agent.WithTTL(a.ttl))
+	tg := taskgroup.NewTaskGroup()
+	tg.Add(agent.NewServer(listener, keyring))
+	tg.Add(server.NewJanitor(keyring, janitorInterval))
+
+	stop := func() {
+		tg.StopAll()
+		keyring.Close()
+	}
+	g := graceful.NewGraceFul(graceful.WithSignalHandlers(map[os.Signal]graceful.Handler{
+		syscall.SIGINT:  stop,
+		syscall.SIGTERM: stop,
+	}))
+
+	tg.StartAll()
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s=%s; export %s;\n", agent.SocketEnv, a.socket, agent.SocketEnv)
+	log.Infof("agent pid %d listening on %s", os.Getpid(), a.socket)
+	if a.daemon {
+		// 父进程读到环境变量后退出，关闭标准输入输出，eval $(shamir agent) 才能返回
+		if err = detachStdio(); err != nil {
+			stop()
+			return err
+		}
+	}
+	g.Wait()
+	log.Info("agent stopped")
+	return nil
+}
+
+// fork 在新的会话中运行 agent 子进程，转发子进程输出的环境变量，并输出子进程的进程号
+func (a *AgentCmdConf) fork(cmd *cobra.Command) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{"agent", "--socket", a.socket, "--ttl", a.ttl.String(), "--" + agentDaemonFlag}
+	child := exec.Command(executable, args...)
+	child.Stderr = os.Stderr
+	child.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	stdout, err := child.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = child.Start(); err != nil {
+		return fmt.Errorf("start agent failed: %w", err)
+	}
+
+	// 子进程启动失败时不会输出环境变量，错误已经输出到标准错误
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, agent.SocketEnv+"=") {
+		_ = child.Wait()
+		return fmt.Errorf("start agent failed: %s", strings.TrimSpace(line))
+	}
+	out := cmd.OutOrStdout()
+	_, _ = fmt.Fprint(out, line)
+	_, _ = fmt.Fprintf(out, "%s=%d; export %s;\necho Agent pid %d;\n", agentPidEnv, child.Process.Pid, agentPidEnv,
+		child.Process.Pid)
+	return child.Process.Release()
+}
+
+// detachStdio 将标准输入、输出和错误重定向到 /dev/null
+func detachStdio() error {
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer null.Close()
+	for _, fd := range []int{unix.Stdin, unix.Stdout, unix.Stderr} {
+		if err = unix.Dup2(int(null.Fd()), fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *AgentCmdConf) client() *agent.Client {
+	return agent.NewClient(a.socket)
+}
+
+type agentAddConf struct {
+	agent *AgentCmdConf
+	ttl   time.Duration
+	// 复用解密时读取密钥文件的逻辑，包括解封和签名校验
+	decrypt DecryptCmdConf
+}
+
+func newAgentAddCommand(a *AgentCmdConf) *cobra.Command {
+	cmd := &cobra.Command{}
+	conf := &agentAddConf{agent: a}
+	cmd.Use = "add"
+	cmd.Short = "Load shares from key files into the agent"
+	cmd.Long =
+		`Load shares from key files into the agent
+
+All usable key pairs found in the inputs are loaded, together with the necessary key.
+Sealed shares are opened with --identity, --pgp-secret-key or passphrases before loading.`
+	cmd.Example = `shamir agent add -i ./keys/
+shamir agent add -i /media/usb1 --identity ~/.ssh/id_ed25519 --trust dealer.pub --ttl 30m`
+	cmd.Args = NoArgs
+	cmd.Flags().StringArrayVarP(&conf.decrypt.inputPaths, "input-path", "i", nil, "The path of keys, can be repeated. "+
+		"Accepts directories (searched recursively), key files, .zip/.tar/.tar.gz archives and glob patterns")
+	cmd.Flags().DurationVar(&conf.ttl, "ttl", 0, "Shares will be wiped after the ttl unless refreshed, "+
+		"default is the ttl of the agent")
+	conf.decrypt.seal.addDecryptFlags(cmd)
+	conf.decrypt.sign.addDecryptFlags(cmd)
+
+	cmd.RunE = conf.RunE
+	return cmd
+}
+
+func (c *agentAddConf) RunE(cmd *cobra.Command, _ []string) error {
+	d := &c.decrypt
+	if len(d.inputPaths) == 0 {
+		return fmt.Errorf("please use -i to input key files")
+	}
+	if err := d.sign.loadTrusted(); err != nil {
+		return err
+	}
+	if err := d.seal.parseIdentities(); err != nil {
+		return err
+	}
+	defer d.seal.destroy()
+
+	shares, err := d.loadShareFiles(cmd)
+	if err != nil {
+		return err
+	}
+	necessary, err := readSource(d.necessaryFile)
+	if err != nil {
+		return err
+	}
+
+	client := c.agent.client()
+	for _, share := range shares {
+		x, y, e := d.readShareFiles(share)
+		if e != nil {
+			return e
+		}
+
+		keys := &agent.Share{
+			Comment:   share.String(),
+			Necessary: bytes.TrimSpace(necessary),
+			X:         x,
+			Y:         y,
+		}
+		info, e := client.Add(keys, c.ttl)
+		secure.Wipe(x)
+		secure.Wipe(y)
+		if e != nil {
+			return fmt.Errorf("add keys of %s failed: %w", share, e)
+		}
+		infof(cmd, "added %s as %s, expires at %s", share, info.ID, info.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// readShareFiles 读取持有人完整的x、y密钥，密钥文件被加密时解密读取，返回的密钥由调用者清零
+func (d *DecryptCmdConf) readShareFiles(share *shareFiles) ([]byte, []byte, error) {
+	key, opened, err := d.openShareFiles(share)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeClosers(opened)
+
+	x := &secure.Buffer{}
+	if _, err = io.Copy(x, key.x); err != nil {
+		x.Reset()
+		return nil, nil, fmt.Errorf("read x key file %s failed: %w", share.x.Source(), err)
+	}
+	y := &secure.Buffer{}
+	if _, err = io.Copy(y, key.y); err != nil {
+		x.Reset()
+		y.Reset()
+		return nil, nil, fmt.Errorf("read y key file %s failed: %w", share.y.Source(), err)
+	}
+	return bytes.TrimSpace(x.Bytes()), bytes.TrimSpace(y.Bytes()), nil
+}
+
+func newAgentListCommand(a *AgentCmdConf) *cobra.Command {
+	var format string
+	cmd := &cobra.Command{}
+	cmd.Use = "list"
+	cmd.Short = "List shares held by the agent, without any key"
+	cmd.Args = NoArgs
+	cmd.Flags().StringVar(&format, "format", Table, "The output format, [table|yaml|json|csv]")
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		infos, err := a.client().List()
+		if err != nil {
+			return err
+		}
+
+		header := []string{"ID", "COMMENT", "CHUNKS", "NECESSARY KEY", "EXPIRES AT"}
+		data := make([][]string, 0, len(infos))
+		for _, info := range infos {
+			data = append(data, []string{info.ID, info.Comment, strconv.Itoa(info.Chunks),
+				strconv.FormatBool(info.HasNecessary), info.ExpiresAt.Format(time.RFC3339)})
+		}
+		return RenderData(format, header, data, infos, cmd.OutOrStdout())
+	}
+	return cmd
+}
+
+func newAgentRefreshCommand(a *AgentCmdConf) *cobra.Command {
+	var ttl time.Duration
+	cmd := &cobra.Command{}
+	cmd.Use = "refresh ID..."
+	cmd.Short = "Restart the ttl of shares held by the agent"
+	cmd.Args = cobra.MinimumNArgs(1)
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "The new ttl, default is the ttl used when added")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		infos, err := a.client().Refresh(ttl, args...)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			infof(cmd, "%s expires at %s", info.ID, info.ExpiresAt.Format(time.RFC3339))
+		}
+		return nil
+	}
+	return cmd
+}
+
+func newAgentRemoveCommand(a *AgentCmdConf) *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{}
+	cmd.Use = "remove ID..."
+	cmd.Short = "Wipe shares held by the agent"
+	cmd.Flags().BoolVar(&all, "all", false, "Wipe all shares")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if all == (len(args) != 0) {
+			_ = cmd.Usage()
+			return fmt.Errorf("please specify share ids or --all")
+		}
+
+		n, err := a.client().Remove(args...)
+		if err != nil {
+			return err
+		}
+		infof(cmd, "%d shares removed", n)
+		return nil
+	}
+	return cmd
+}
+
+func newAgentContributeCommand(a *AgentCmdConf) *cobra.Command {
+	var serverURL, session, tokenFile string
+	cmd := &cobra.Command{}
+	cmd.Use = "contribute ID"
+	cmd.Short = "Let the agent submit a share to a recovery session of shamir serve"
+	cmd.Args = ExactArgs(1)
+	cmd.Flags().StringVar(&serverURL, "server", "", "The url of shamir serve, such as https://ceremony:8420")
+	cmd.Flags().StringVar(&session, "session", "", "The id of the recovery session")
+	cmd.Flags().StringVar(&tokenFile, "submit-token-file", "", "The file contains the submit token of the session, "+
+		"given by the initiator of the session")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if serverURL == "" || session == "" || tokenFile == "" {
+			return fmt.Errorf("please use --server, --session and --submit-token-file")
+		}
+		token, err := os.ReadFile(filepath.Clean(tokenFile))
+		if err != nil {
+			return fmt.Errorf("read submit token file failed: %w", err)
+		}
+
+		progress, err := a.client().Contribute(args[0], serverURL, session, strings.TrimSpace(string(token)))
+		if err != nil {
+			return err
+		}
+		infof(cmd, "session %s: %d of %d shares collected", progress.ID, progress.Collected, progress.Threshold)
+		return nil
+	}
+	return cmd
+}
+
+// pullShares 从 agent 中取出份额作为 -x、-y 和 -n 使用，未指定 -n 时使用 agent 中保存的必须密钥，
+// 指定 -t 时只取 t 个份额
+func (d *DecryptCmdConf) pullShares(cmd *cobra.Command) error {
+	if d.ssss.compat != CompatNone {
+		return fmt.Errorf("can not use --agent with --compat %s", d.ssss.compat)
+	}
+	if d.interactive || len(d.inputPaths) != 0 || len(d.xKeys) != 0 || len(d.yKeys) != 0 {
+		return fmt.Errorf("can not use --agent with --interactive, -i, -x or -y")
+	}
+
+	shares, err := agent.NewClient(d.agentSocket).Get()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, share := range shares {
+			share.Wipe()
+		}
+	}()
+	if len(shares) == 0 {
+		return fmt.Errorf("no shares in agent, please use shamir agent add")
+	}
+
+	necessary := d.necessary
+	if necessary == "" {
+		for _, share := range shares {
+			if len(share.Necessary) == 0 || string(share.Necessary) == necessary {
+				continue
+			}
+			if necessary != "" {
+				return fmt.Errorf("agent holds shares of different secrets, please use -n")
+			}
+			necessary = string(share.Necessary)
+		}
+	}
+	primes, ok := code.EncodeKeys(necessary)
+	if necessary == "" || !ok {
+		return fmt.Errorf("no valid necessary key in agent, please use -n")
+	}
+
+	seen := make(map[string]struct{}, len(shares))
+	for _, share := range shares {
+		if d.t > 0 && len(d.xKeys) == d.t {
+			break
+		}
+		if len(share.Necessary) != 0 && string(share.Necessary) != necessary {
+			log.Debugf("skip %s in agent: belongs to another secret", share.Comment)
+			continue
+		}
+		// -x、-y 的密钥同样是 string，与命令行输入的密钥一样无法清零
+		x, y := string(share.X), string(share.Y)
+		firstX, e := checkShareKeys(x, y, len(primes))
+		if e != nil {
+			warnf(cmd, "skip %s in agent: %v", share.Comment, e)
+			continue
+		}
+		if _, ok = seen[firstX]; ok {
+			continue
+		}
+
+		seen[firstX] = struct{}{}
+		d.xKeys = append(d.xKeys, x)
+		d.yKeys = append(d.yKeys, y)
+		infof(cmd, "use %s in agent", share.Comment)
+	}
+
+	if len(d.xKeys) < shamir.MinThreshold || len(d.xKeys) < d.t {
+		return fmt.Errorf("only %d usable shares in agent, not enough to restore the secret", len(d.xKeys))
+	}
+	d.necessary = necessary
+	return nil
+}
@@ -4,25 +4,56 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"math/big"
-	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
-	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/agent"
+	"shamir/pkg/utils/graceful"
+	"shamir/pkg/utils/manifest"
 	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/progress"
+	"shamir/pkg/utils/secure"
 	"shamir/pkg/utils/shamir"
+	"shamir/pkg/utils/source"
+	"shamir/pkg/utils/tree"
 )
 
 type DecryptCmdConf struct {
 	xKeys []string
 	yKeys []string
 
-	necessary         string
-	inputPath, output string
+	necessary  string
+	inputPaths []string
+	output     string
+	// necessaryFile 从输入中找到的必须密钥文件
+	necessaryFile *source.File
 
 	t int
+
+	// interactive 在终端中关闭回显逐个输入份额
+	interactive bool
+	// agent 从 shamir agent 中取出份额
+	agent       bool
+	agentSocket string
+	// interrupt 收到 SIGINT/SIGTERM 时中断解密，删除写了一部分的秘密文件
+	interrupt *graceful.SignalContext
+	// tx 输出到文件时，秘密全部解密并校验后才出现在输出路径
+	tx *secure.Transaction
+	// tree -o 以 / 结尾时，将 tar 格式的秘密还原为目录
+	tree *tree.Extractor
+	// rewind 可以清空重写的输出，尝试密钥对的组合时直接解密到输出，成功时 restored 为 true，不需要再解密一遍
+	rewind   rewinder
+	restored bool
+
+	manifest manifestConf
+	progress progressConf
+	exec     execConf
+	ssss     ssssConf
+	seal     sealConf
+	sign     signConf
 }
 
 func NewDecryptCommand() *cobra.Command {
@@ -35,126 +66,195 @@ func NewDecryptCommand() *cobra.Command {
 
 You can use it to decrypt n keys which contains (x, y) and one necessary key to secret.
 The insertion order of x、y must be the same, and they must be the counts, xKey and yKey will be combined into one key.
+
+When manifest.json is in the input, the restored secret is checked with the commitment in it.
+The commitment only protects -o and --exec: the secret file is not left and the command is not run when the check fails,
+but the secret printed to stdout is written before the check and can only be reported as failed afterwards.
 `
 	cmd.Example = `shamir decrypt -n 123456789 -x 455 -y 455 -x 666 -y 666
 shamir decrypt -i ./ -t 2
+shamir decrypt --interactive -t 2 -o ./secret.txt
+shamir decrypt --agent -t 2 -o ./secret.txt
 shamir decrypt -i ./keys/ -t 2 -o ./secret.txt
+shamir decrypt -i ./keys/ -t 2 -o ./restored/
+shamir decrypt -i /media/usb1 -i ./alice.zip -i './mail/*.tar.gz' -t 3
+shamir decrypt -i ./keys/ -t 2 --identity ~/.ssh/id_ed25519 --identity ./age-key.txt
+shamir decrypt -i ./keys/ -t 2 --pgp-secret-key alice.asc --pgp-secret-key bob.asc
+shamir decrypt -i ./keys/ -t 2 --trust dealer.pub
+shamir decrypt --compat ssss -t 3 < shares.txt
+shamir decrypt --compat ssss -t 3 -y 1-5f8e... -y 3-0b2c... -y 4-8a1d...
+shamir decrypt -i ./keys/ -t 2 --exec -- gpg --batch --passphrase-fd 0 -d backup.gpg
+shamir decrypt -i ./keys/ -t 2 --exec --exec-via env --exec-env PGPASSWORD -- psql -h db
+shamir decrypt -i ./keys/ -t 2 --exec --exec-via memfd -- ssh-add {}
 `
-	cmd.Args = NoArgs
+	cmd.Args = conf.exec.parseArgs
 	// 设置全局flag
-	cmd.Flags().StringVarP(&conf.inputPath, "input-path", "i", "", "The path of keys")
-	cmd.Flags().StringVarP(&conf.output, "output", "o", "", "The secret output file")
+	cmd.Flags().StringArrayVarP(&conf.inputPaths, "input-path", "i", nil, "The path of keys, can be repeated. "+
+		"Accepts directories (searched recursively), key files, .zip/.tar/.tar.gz archives and glob patterns")
+	cmd.Flags().StringVarP(&conf.output, "output", "o", "", "The secret output file, "+
+		"restore the files into the directory when it ends with /, for secret split from directory or multiple files")
 	cmd.Flags().StringVarP(&conf.necessary, "necessary", "n", "", "The necessary key")
 	cmd.Flags().IntVarP(&conf.t, "threshold", "t", 0, "The key's threshold, use t keys to decrypt the secret. "+
-		"must use -t when use -p")
+		"must use -t when use -i without manifest.json, or use --compat ssss")
 	cmd.Flags().StringSliceVarP(&conf.xKeys, "x-key", "x", []string{}, "The key of X")
-	cmd.Flags().StringSliceVarP(&conf.yKeys, "y-key", "y", []string{}, "The key of Y")
+	cmd.Flags().StringSliceVarP(&conf.yKeys, "y-key", "y", []string{}, "The key of Y, "+
+		"or the ssss share when use --compat ssss")
+	cmd.Flags().BoolVar(&conf.interactive, "interactive", false, "Input the necessary key and shares one by one "+
+		"in the terminal with echo disabled, keep keys out of shell history and process list. Must use with -t")
+	cmd.Flags().BoolVar(&conf.agent, "agent", false, "Pull shares and the necessary key from shamir agent "+
+		"on $"+agent.SocketEnv)
+	cmd.Flags().StringVar(&conf.agentSocket, "agent-socket", agent.DefaultSocket(), "The unix socket of shamir agent, "+
+		"the same as --socket of shamir agent. Only work with --agent")
+	conf.progress.addFlags(cmd)
+	conf.exec.addFlags(cmd)
+	conf.ssss.addFlags(cmd, false)
+	conf.seal.addDecryptFlags(cmd)
+	conf.sign.addDecryptFlags(cmd)
 
 	cmd.RunE = conf.RunE
 	return cmd
 }
 
 func (d *DecryptCmdConf) RunE(cmd *cobra.Command, _ []string) error {
-	if err := d.check(); err != nil {
+	if err := d.ssss.check(); err != nil {
+		return err
+	}
+	if err := d.exec.check(d.output); err != nil {
 		return err
 	}
+	if d.agent {
+		if err := d.pullShares(cmd); err != nil {
+			return err
+		}
+	}
+	if d.interactive {
+		if err := d.collectShares(cmd); err != nil {
+			return err
+		}
+	}
+	if d.ssss.compat == CompatSsss {
+		return d.runSsss(cmd)
+	}
 
-	keyReaders, necessaryReader, taskInputIndicator, err := d.getInput()
-	if err != nil {
+	if err := d.check(); err != nil {
 		return err
 	}
-	defer taskInputIndicator.Fail()
+	defer d.seal.destroy()
 
+	d.interrupt = graceful.NewSignalContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer d.interrupt.Stop()
 	output, taskOutputIndicator, err := d.getOutput(cmd)
 	if err != nil {
 		return err
 	}
 	defer taskOutputIndicator.Fail()
+	d.rewind, _ = output.(rewinder)
 
-	keyEncoders := getKeyEncoders(keyReaders)
-	nes := code.NewKeyEncoder(necessaryReader)
-	secretDecoder := code.NewSecretDecoder(output)
+	keyReaders, necessaryReader, taskInputIndicator, err := d.getInput(cmd)
+	if err != nil {
+		return err
+	}
+	defer taskInputIndicator.Fail()
+	d.hintContent(cmd)
 
-	for {
-		keys, necessaryKey, isHash, e := getKeys(keyEncoders, nes)
-		if e != nil {
-			return e
+	var reporter *progress.Reporter
+	if !d.restored {
+		if reporter, err = d.newReporter(cmd); err != nil {
+			return err
 		}
-
-		e = d.decrypt(keys, necessaryKey, secretDecoder)
-		if e != nil {
-			return e
+		if reporter != nil {
+			reporter.Start()
+			defer reporter.Finish(false)
 		}
-
-		if !isHash {
-			continue
+		if err = d.combine(keyReaders, necessaryReader, d.manifest.secretWriter(output), reporter); err != nil {
+			return err
 		}
-
-		e = secretDecoder.HashCheck()
-		if e != nil {
-			return e
+	}
+	if d.tree != nil {
+		// 等待还原出全部文件后再校验秘密
+		if err = output.Close(); err != nil {
+			return fmt.Errorf("restore files failed: %w", err)
 		}
-		break
+	}
+	if d.interrupt.Err() != nil {
+		return d.interrupt.Cause()
+	}
+	if err = d.manifest.verify(); err != nil {
+		return err
+	}
+	if reporter != nil {
+		reporter.Finish(true)
+	}
+
+	taskInputIndicator.Success()
+	// 秘密交给子进程，子进程退出后由 taskOutputIndicator 清零，期间的信号转发给子进程
+	if d.exec.enabled {
+		d.interrupt.Stop()
+		return d.exec.run(cmd)
 	}
 
+	taskOutputIndicator.Success()
 	// console上的输出换行显示
 	if d.output == "" {
 		output.Write([]byte("\n"))
+		return err
 	}
-	taskInputIndicator.Success()
-	taskOutputIndicator.Success()
-	return err
-}
-
-func (d *DecryptCmdConf) decrypt(keys []code.Key, prime *big.Int, secretWriter *code.SecretDecoder) error {
-	secret, e := shamir.Decrypt(keys, prime)
-	if e != nil {
-		return e
+	if err = d.tx.Commit(); err != nil {
+		if d.tree != nil {
+			d.tree.Rollback()
+		}
+		return err
 	}
-
-	e = secretWriter.Write(secret)
-	if e != nil {
-		return e
+	if d.tree != nil {
+		return d.tree.Restore()
 	}
-
 	return nil
 }
 
-func getKeys(keyReaders []*xyKeyEncoder, necessaryReader *code.KeyEncoder) ([]code.Key, *big.Int, bool, error) {
-	keys := make([]code.Key, 0, len(keyReaders))
-	isHash := false
-	for i, reader := range keyReaders {
-		key, ok, err := reader.encoder()
-		if err != nil {
-			return nil, nil, false, err
-		}
-		if i != 0 && isHash != ok {
-			return nil, nil, false, fmt.Errorf("keys not match")
-		}
-
-		isHash = ok
-		keys = append(keys, key)
+// hintContent 秘密是多个文件打包的 tar 归档，但没有以目录的形式输出时提示
+func (d *DecryptCmdConf) hintContent(cmd *cobra.Command) {
+	loaded := d.manifest.loaded
+	if loaded == nil || loaded.Content != manifest.ContentTar || d.exec.enabled || isTreeOutput(d.output) {
+		return
 	}
+	infof(cmd, "the secret is a tar archive of files, use -o <dir>/ to restore the files")
+}
 
-	necessaryKey, ok, err := necessaryReader.Read()
-	if err != nil {
-		return nil, nil, false, err
-	}
+// isTreeOutput -o 以路径分隔符结尾时将秘密还原为目录
+func isTreeOutput(output string) bool {
+	return strings.HasSuffix(output, string(filepath.Separator))
+}
 
-	if isHash != ok {
-		return nil, nil, false, fmt.Errorf("necessary key not match")
+// combine 从密钥对和必须密钥中逐段解密秘密写入 output，最后用秘密中的hash值校验，reporter 不为nil时统计进度
+func (d *DecryptCmdConf) combine(keyReaders []*keyReadWriter, necessaryReader io.Reader, output io.Writer,
+	reporter *progress.Reporter) error {
+	keys := getKeyEncoders(keyReaders)
+	if reporter != nil {
+		keys[0] = &progressKeyReader{KeyReader: keys[0], reporter: reporter}
+		output = &progressWriter{writer: output, reporter: reporter}
 	}
 
-	return keys, necessaryKey, isHash, nil
+	_, err := io.Copy(output, d.interrupt.Reader(shamir.NewCombineReader(keys, necessaryReader)))
+	return err
 }
 
-func (d *DecryptCmdConf) check() error {
-	if d.inputPath != "" {
-		if !path.IsExist(d.inputPath) {
-			return fmt.Errorf("input path %q not exist", d.inputPath)
+// newReporter 进度的总量是必须密钥的段数，包括最后一段hash值
+func (d *DecryptCmdConf) newReporter(cmd *cobra.Command) (*progress.Reporter, error) {
+	chunks := strings.Count(d.necessary, "_") + 1
+	if d.necessaryFile != nil {
+		var err error
+		if chunks, err = countKeyChunks(d.necessaryFile); err != nil {
+			return nil, err
 		}
+	}
 
-		if d.t < shamir.MinThreshold {
+	return d.progress.newReporter(cmd, "decrypt", d.output != "" || d.exec.enabled, progress.WithTotalChunks(int64(chunks))), nil
+}
+
+func (d *DecryptCmdConf) check() error {
+	if len(d.inputPaths) != 0 {
+		// 没有 -t 时使用输入中清单的门限值
+		if d.t != 0 && d.t < shamir.MinThreshold {
 			return fmt.Errorf("invalid threshold, please use -t correctly when use input keys by path")
 		}
 	} else {
@@ -178,14 +278,24 @@ func (d *DecryptCmdConf) check() error {
 	if d.output != "" && path.IsExist(d.output) {
 		return fmt.Errorf("output file %q is exist", d.output)
 	}
+	if err := d.progress.check(); err != nil {
+		return err
+	}
 
-	return nil
+	if d.sign.trustKey != "" && len(d.inputPaths) == 0 {
+		return fmt.Errorf("can not verify dealer signature of keys from command line, please use -i")
+	}
+	if err := d.sign.loadTrusted(); err != nil {
+		return err
+	}
+
+	return d.seal.parseIdentities()
 }
 
-func (d *DecryptCmdConf) getInput() ([]*keyReadWriter, io.ReadWriter, *TaskIndicator, error) {
+func (d *DecryptCmdConf) getInput(cmd *cobra.Command) ([]*keyReadWriter, io.ReadWriter, *TaskIndicator, error) {
 	var keys = make([]*keyReadWriter, 0, d.t)
 	var necessary io.ReadWriteCloser
-	if d.inputPath == "" {
+	if len(d.inputPaths) == 0 {
 		necessary = NewReadWriteCloser(bytes.NewBufferString(d.necessary))
 
 		for i, xKey := range d.xKeys {
@@ -195,71 +305,69 @@ func (d *DecryptCmdConf) getInput() ([]*keyReadWriter, io.ReadWriter, *TaskIndic
 		return keys, necessary, NewTaskIndicator(nil, nil), nil
 	}
 
-	// 从指定文件夹拿取
-	var opened []io.Closer
-	d.inputPath = filepath.Clean(d.inputPath)
-	keysName, necessaryName, err := path.GetKeysName(d.inputPath)
+	// 从所有输入中拿取，跳过有问题的密钥对，找到能还原出秘密的 t 个密钥对
+	shares, err := d.loadShareFiles(cmd)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-
-	if len(keysName) < d.t {
-		return nil, nil, nil, fmt.Errorf("invalid input key files, key files can not less than threshold")
+	if d.t < shamir.MinThreshold {
+		return nil, nil, nil, fmt.Errorf("invalid threshold, please use -t correctly when use input keys by path without manifest")
+	}
+	shares, err = d.findShares(cmd, shares)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	for i := 0; i < d.t; i++ {
-		xKeyFileName := filepath.Join(d.inputPath, keysName[i].XKey)
-		xKeyFile, err := os.OpenFile(xKeyFileName, os.O_RDONLY, defaultFilePermission)
-		if err != nil {
-			closeClosers(opened)
-			return nil, nil, nil, fmt.Errorf("open x key file %s failed: %w", xKeyFileName, err)
-		}
-		opened = append(opened, xKeyFile)
-
-		yKeyFileName := filepath.Join(d.inputPath, keysName[i].YKey)
-		yKeyFile, err := os.OpenFile(yKeyFileName, os.O_RDONLY, defaultFilePermission)
-		if err != nil {
-			closeClosers(opened)
-			return nil, nil, nil, fmt.Errorf("open y key file %s failed: %w", yKeyFileName, err)
-		}
-		opened = append(opened, yKeyFile)
-		keys = append(keys, NewKeyReadWriter(xKeyFile, yKeyFile))
+	for _, share := range shares {
+		infof(cmd, "use keys of %s", share)
+	}
+	if d.restored {
+		return nil, nil, NewTaskIndicator(nil, nil), nil
 	}
 
-	necessaryKeyFileName := filepath.Join(d.inputPath, necessaryName)
-	necessary, err = os.OpenFile(necessaryKeyFileName, os.O_RDONLY, defaultFilePermission)
+	keys, necessaryReader, opened, err := d.openShares(shares)
 	if err != nil {
-		closeClosers(opened)
-		return nil, nil, nil, fmt.Errorf("open necessary key file %s failed: %w", necessaryKeyFileName, err)
+		return nil, nil, nil, err
 	}
-	opened = append(opened, necessary)
 
-	return keys, necessary, NewTaskIndicator(func() { closeClosers(opened) }, func() { closeClosers(opened) }), nil
+	return keys, NewReadOnly(necessaryReader), NewTaskIndicator(func() { closeClosers(opened) }, func() { closeClosers(opened) }), nil
 }
 
 func (d *DecryptCmdConf) getOutput(cmd *cobra.Command) (io.WriteCloser, *TaskIndicator, error) {
+	if d.exec.enabled {
+		return d.exec.open()
+	}
 	if d.output == "" {
 		return NewWriteCloser(cmd.OutOrStdout()), NewTaskIndicator(nil, nil), nil
 	}
 
+	treeOutput := isTreeOutput(d.output)
 	d.output = filepath.Clean(d.output)
 	if path.IsExist(d.output) {
 		return nil, nil, fmt.Errorf("invalid output file path %q, is exist", d.output)
 	}
 
-	secret, err := os.OpenFile(d.output, os.O_CREATE|os.O_WRONLY, defaultFilePermission)
+	d.tx = secure.NewTransaction()
+	if treeOutput {
+		d.tree = tree.NewExtractor(d.output, d.tx)
+		return d.tree.Writer(), NewTaskIndicator(nil, d.tree.Rollback), nil
+	}
+	secret, err := d.tx.Create(d.output)
 	if err != nil {
 		return nil, nil, fmt.Errorf("create secret file %q failed: %w", d.output, err)
 	}
 
-	return secret, NewTaskIndicator(
-		func() { closeClosers([]io.Closer{secret}) },
-		func() { rollback([]io.Closer{secret}, []string{d.output}) },
-	), nil
+	return secret, NewTaskIndicator(nil, d.tx.Rollback), nil
+}
+
+// rewinder 可以清空已写入的内容重新写入的输出，如 -o 的文件和 --exec 的秘密，标准输出和目录不能重写
+type rewinder interface {
+	io.Writer
+	Rewind() error
 }
 
-func getKeyEncoders(keys []*keyReadWriter) []*xyKeyEncoder {
-	decoders := make([]*xyKeyEncoder, 0, len(keys))
+func getKeyEncoders(keys []*keyReadWriter) []shamir.KeyReader {
+	decoders := make([]shamir.KeyReader, 0, len(keys))
 	for _, key := range keys {
 		decoders = append(decoders, key.ToXYKeyEncoder())
 	}
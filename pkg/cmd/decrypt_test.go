@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"shamir/pkg/utils/path"
+)
+
+const testSecret = "this is a secret.同时可以使用中文。"
+
+// runShamir 运行一次命令，返回标准输出和标准错误
+func runShamir(t *testing.T, args ...string) (string, string, error) {
+	command, err := NewMainCommand()
+	require.NoError(t, err)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	command.SetOut(stdout)
+	command.SetErr(stderr)
+	command.SetArgs(append([]string{"--log", filepath.Join(t.TempDir(), "shamir.log")}, args...))
+	err = command.Execute()
+	return stdout.String(), stderr.String(), err
+}
+
+// encryptShares 将 testSecret 拆分到临时目录中，keepManifest 为false时删除清单，以便篡改密钥文件
+func encryptShares(t *testing.T, threshold, n int, keepManifest bool) string {
+	dir := filepath.Join(t.TempDir(), "keys")
+	_, _, err := runShamir(t, "encrypt", "-f", "-t", fmt.Sprint(threshold), "-n", fmt.Sprint(n), "-o", dir, testSecret)
+	require.NoError(t, err)
+	if !keepManifest {
+		require.NoError(t, os.Remove(filepath.Join(dir, path.ManifestFileName)))
+	}
+	return dir
+}
+
+// tamperShare 改写持有人y密钥的第一个字符，密钥仍然可以读取，但不在原来的多项式上
+func tamperShare(t *testing.T, dir string, holder int) {
+	file := filepath.Join(dir, path.YKeyFilePrefix+fmt.Sprint(holder))
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	if data[0] == 'Z' {
+		data[0] = 'Y'
+	} else {
+		data[0] = 'Z'
+	}
+	require.NoError(t, os.WriteFile(file, data, 0600))
+}
+
+func TestDecryptSkipBadShare(t *testing.T) {
+	// t+1 个密钥对时只能逐个组合校验
+	dir := encryptShares(t, 2, 3, false)
+	tamperShare(t, dir, 0)
+
+	stdout, stderr, err := runShamir(t, "decrypt", "-i", dir, "-t", "2")
+	require.NoError(t, err)
+	assert.Equal(t, testSecret+"\n", stdout)
+	assert.Contains(t, stderr, "2 combinations of keys failed, restored the secret by holders 1,2")
+
+	// 密钥对足够多时先排除不一致的密钥对
+	dir = encryptShares(t, 2, 5, false)
+	tamperShare(t, dir, 0)
+	tamperShare(t, dir, 3)
+
+	stdout, stderr, err = runShamir(t, "decrypt", "-i", dir, "-t", "2")
+	require.NoError(t, err)
+	assert.Equal(t, testSecret+"\n", stdout)
+	assert.Contains(t, stderr, "skip keys of holder 0 from "+dir+": not consistent with keys of other holders")
+	assert.Contains(t, stderr, "skip keys of holder 3 from "+dir+": not consistent with keys of other holders")
+	assert.NotContains(t, stderr, "combinations of keys failed")
+}
+
+func TestDecryptRewindOutput(t *testing.T) {
+	dir := encryptShares(t, 2, 3, false)
+	tamperShare(t, dir, 0)
+
+	// 第一个组合解密到输出后校验失败，清空输出后用下一个组合还原
+	output := filepath.Join(t.TempDir(), "secret.txt")
+	_, stderr, err := runShamir(t, "decrypt", "-i", dir, "-t", "2", "-o", output)
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "restored the secret by holders 1,2")
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, testSecret, string(data))
+}
+
+func TestDecryptNoCombination(t *testing.T) {
+	dir := encryptShares(t, 2, 3, false)
+	tamperShare(t, dir, 0)
+	tamperShare(t, dir, 1)
+
+	output := filepath.Join(t.TempDir(), "secret.txt")
+	stdout, _, err := runShamir(t, "decrypt", "-i", dir, "-t", "2", "-o", output)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no 2 of the 3 usable key pairs can restore the secret")
+	assert.Empty(t, stdout)
+	assert.NoFileExists(t, output)
+}
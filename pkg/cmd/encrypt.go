@@ -4,38 +4,49 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"math/big"
 	"os"
 	"path/filepath"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
 	"shamir/pkg/utils/code"
 	"shamir/pkg/utils/compute"
+	"shamir/pkg/utils/graceful"
 	"shamir/pkg/utils/log"
 	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/progress"
+	"shamir/pkg/utils/secure"
 	"shamir/pkg/utils/shamir"
 )
 
 const (
 	// 非流式情况下支持 1MB 数据加密
-	stringLimit           = 1 * compute.UnitM
-	keyNumberLimit        = 1000
-	defaultFilePermission = 0644
-)
-
-var (
-	// 秘密分隔的大小，应减去额外的前缀开销
-	fastSplitLen   = compute.GetSecretMaxLen() - 1
-	noFastSplitLen = compute.GetSecretMaxLenNoFast() - 1
+	stringLimit    = 1 * compute.UnitM
+	keyNumberLimit = 1000
 )
 
 type EncryptCmdConf struct {
-	fast              bool
-	outputPath, input string
-	t, n              int
+	fast       bool
+	outputPath string
+	// inputs 多个输入或目录打包为 tar 后拆分
+	inputs []string
+	t, n   int
 
 	format string
+
+	// tx 输出到文件夹时，密钥和签名文件全部写入成功后才一起出现在输出目录中
+	tx *secure.Transaction
+	// createdDirs 本次加密创建的输出目录和持有人目录，失败时删除
+	createdDirs []string
+
+	layout   layoutConf
+	manifest manifestConf
+	secret   secretConf
+	progress progressConf
+	ssss     ssssConf
+	seal     sealConf
+	sign     signConf
 }
 
 func NewEncryptCommand() *cobra.Command {
@@ -50,24 +61,52 @@ You can use it to encrypt a string or a file.
 It will be encrypted as n keys which contains (x, y) and one necessary key.
 Any t keys can restore the secret.`
 	cmd.Example = `shamir encrypt -n 2 -t 2 -o . -i secret.txt
+shamir encrypt -n 3 -t 2 -o ./keys -i /etc/pki -i ./token.txt
 shamir encrypt -n 2 -t 2 -o . < secret.txt
-shamir encrypt -n 2 -t 2 "this is a secret.同时支持中文"
+shamir encrypt -n 2 -t 2
+shamir encrypt -n 2 -t 2 -o . --secret-fd 3 3< secret.txt
+SECRET="this is a secret.同时支持中文" shamir encrypt -n 2 -t 2 --secret-env SECRET
+shamir encrypt -n 5 -t 3 --compat ssss
+shamir encrypt -n 2 -t 2 -o . -i secret.txt --recipient 0=age1... --recipient "1=ssh-ed25519 AAAA..."
+shamir encrypt -n 2 -t 2 -o . -i secret.txt --protect-shares
+shamir encrypt -n 2 -t 2 -o . -i secret.txt --sign dealer.key
+shamir encrypt -n 3 -t 2 -o ./keys -i secret.txt --layout per-holder --pack
+shamir encrypt -n 3 -t 2 -o ./keys -i secret.txt --label 0=alice --label 1=bob --label 2=carol
+shamir encrypt -n 2 -t 2 -o . -i secret.txt --pgp-keyring pubring.asc --pgp-recipient 0=alice@example.com --pgp-recipient 1=bob@example.com
 `
 	// 设置全局flag
 	cmd.Flags().BoolVarP(&conf.fast, "fast", "f", true, "Use exist prime to encrypt secret, it will be fast")
 	cmd.Flags().StringVarP(&conf.outputPath, "output-path", "o", "", "Output the keys to path")
-	cmd.Flags().StringVarP(&conf.input, "input", "i", "", "Read secret from file, if set input file, "+
-		"get secret from file first. (must use with -o)")
+	cmd.Flags().StringArrayVarP(&conf.inputs, "input", "i", nil, "Read secret from file, if set input file, "+
+		"get secret from file first. Can be repeated or be a directory, the files are split as one tar archive "+
+		"(must use with -o)")
 	cmd.Flags().IntVarP(&conf.t, "threshold", "t", 0, "The key's threshold, use t keys can decrypt the secret")
 	cmd.Flags().IntVarP(&conf.n, "number", "n", 0, "The key's number, this secret will encrypt as n keys")
 	cmd.Flags().StringVar(&conf.format, "format", Table, "Output result use [table|yaml|json|csv] "+
 		"When use --output, this will not work")
+	conf.layout.addFlags(cmd)
+	conf.manifest.addEncryptFlags(cmd)
+	conf.secret.addFlags(cmd)
+	conf.progress.addFlags(cmd)
+	conf.ssss.addFlags(cmd, true)
+	conf.seal.addEncryptFlags(cmd)
+	conf.sign.addEncryptFlags(cmd)
 
 	cmd.RunE = conf.RunE
 	return cmd
 }
 
 func (enc *EncryptCmdConf) RunE(cmd *cobra.Command, args []string) error {
+	if err := enc.ssss.check(); err != nil {
+		return err
+	}
+	if enc.ssss.compat == CompatSsss {
+		if enc.layout.layout != LayoutFlat {
+			return fmt.Errorf("can not use --layout %s with --compat ssss", enc.layout.layout)
+		}
+		return enc.runSsss(cmd, args)
+	}
+
 	if err := enc.check(cmd, args); err != nil {
 		return err
 	}
@@ -77,35 +116,55 @@ func (enc *EncryptCmdConf) RunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	defer closeClosers([]io.Closer{input})
+
+	return enc.split(cmd, input)
+}
+
+// split 拆分从 input 读取的秘密，输出到文件夹或在终端中显示密钥
+func (enc *EncryptCmdConf) split(cmd *cobra.Command, input io.Reader) error {
+	// 中断时返回错误，由 taskIndicator 删除已经创建的密钥文件
+	ctx := graceful.NewSignalContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer ctx.Stop()
 	keys, necessary, taskIndicator, err := enc.getOutput()
 	if err != nil {
 		return err
 	}
 	defer taskIndicator.Fail()
 
-	kesDecoders := getKeyDecoders(keys)
-	nes := code.NewKeyDecoder(necessary)
-	secretReader := code.NewSecretEncoder(input, getSplitLen(enc.fast))
-	for {
-		subSecret, e := secretReader.Read()
-		if e != nil {
-			return e
-		}
-		if subSecret != nil {
-			e = enc.encrypt(kesDecoders, nes, subSecret)
-			if e != nil {
-				return e
-			}
-		} else {
-			e = enc.encrypt(kesDecoders, nes, secretReader.GetHash())
-			if e != nil {
-				return e
-			}
-			break
-		}
+	keyWriters := getKeyDecoders(keys)
+	reader := enc.manifest.secretReader(input)
+	reporter := enc.progress.newReporter(cmd, "encrypt", enc.outputPath != "", inputSize(input)...)
+	if reporter != nil {
+		reader = &progressReader{reader: reader, reporter: reporter}
+		keyWriters[0] = &progressKeyWriter{KeyWriter: keyWriters[0], reporter: reporter}
+		reporter.Start()
+		defer reporter.Finish(false)
+	}
+
+	splitWriter, err := shamir.NewSplitWriter(enc.t, enc.n, enc.fast, keyWriters, necessary)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(splitWriter, ctx.Reader(reader)); err != nil {
+		return err
+	}
+	if err = splitWriter.Close(); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Cause()
+	}
+	if reporter != nil {
+		reporter.Finish(true)
 	}
 	if enc.outputPath != "" {
+		// 关闭密钥文件后签名，签名和密钥文件在同一个事务中提交
 		taskIndicator.Success()
+		if err = enc.finishOutput(); err != nil {
+			enc.rollbackOutput()
+			return err
+		}
+		enc.layout.cleanup(enc.outputPath, enc.n)
 		return nil
 	}
 
@@ -145,28 +204,61 @@ func (enc *EncryptCmdConf) RunE(cmd *cobra.Command, args []string) error {
 
 // private
 
-func (enc *EncryptCmdConf) check(cmd *cobra.Command, args []string) error {
-	// terminal 输入秘密
-	if enc.input == "" && IsTerminalInput() {
-		// 输入参数校验
-		if err := ExactArgs(1)(cmd, args); err != nil {
-			return err
-		}
+// inputSize 输入是普通文件时返回文件大小作为进度的总量
+func inputSize(input io.Reader) []progress.Option {
+	file, ok := input.(*os.File)
+	if !ok {
+		return nil
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return []progress.Option{progress.WithTotalBytes(info.Size())}
+}
 
-		if len(args[0]) > stringLimit {
-			return fmt.Errorf("invalid string, secret length should be less than %dMB, encrypt big secret please use -i", stringLimit/compute.UnitM)
-		}
-	} else if enc.outputPath == "" {
-		return fmt.Errorf("please use -o, when input secret whitout terminal arguments")
+func (enc *EncryptCmdConf) check(cmd *cobra.Command, args []string) error {
+	if err := enc.checkSecretInput(cmd, args, true); err != nil {
+		return err
 	}
+	return enc.checkSplit()
+}
 
-	if enc.input != "" && !path.IsExist(enc.input) {
-		return fmt.Errorf("invalid input file path %q, not exist", enc.input)
+// checkSplit 检查与秘密来源无关的拆分参数
+func (enc *EncryptCmdConf) checkSplit() error {
+	if err := enc.progress.check(); err != nil {
+		return err
 	}
 
 	if err := checkTN(enc.t, enc.n); err != nil {
 		return err
 	}
+	if err := enc.layout.check(enc.outputPath, enc.n); err != nil {
+		return err
+	}
+	if len(enc.manifest.labelFlags) != 0 && enc.outputPath == "" {
+		return fmt.Errorf("please use -o, when use --label")
+	}
+	if err := enc.manifest.parseLabels(enc.n); err != nil {
+		return err
+	}
+
+	if err := enc.seal.parseRecipients(enc.n); err != nil {
+		return err
+	}
+	if (enc.seal.isSealed() || enc.seal.protectShares || enc.seal.passphraseFile != "") && enc.outputPath == "" {
+		return fmt.Errorf("please use -o, when use --recipient, --pgp-recipient or --protect-shares")
+	}
+	if err := enc.seal.parsePassphrases(enc.n); err != nil {
+		return err
+	}
+
+	if enc.sign.signKey != "" && enc.outputPath == "" {
+		return fmt.Errorf("please use -o, when use --sign")
+	}
+	if err := enc.sign.loadPrivateKey(); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -187,35 +279,6 @@ func checkTN(t, n int) error {
 	return nil
 }
 
-func getSplitLen(fast bool) int {
-	if fast {
-		return fastSplitLen
-	}
-
-	return noFastSplitLen
-}
-
-func (enc *EncryptCmdConf) getInput(cmd *cobra.Command, args []string) (io.ReadCloser, error) {
-	var input io.ReadCloser
-	if enc.input == "" {
-		// 优先从标准输入拿
-		if !IsTerminalInput() {
-			return io.NopCloser(cmd.InOrStdin()), nil
-		}
-
-		input = io.NopCloser(bytes.NewBufferString(args[0]))
-		return input, nil
-	}
-
-	enc.input = filepath.Clean(enc.input)
-	input, err := os.OpenFile(enc.input, os.O_RDONLY, 0)
-	if err != nil {
-		return nil, fmt.Errorf("open input secret file failed: %w", err)
-	}
-
-	return input, nil
-}
-
 func (enc *EncryptCmdConf) getOutput() ([]*keyReadWriter, io.ReadWriter, *TaskIndicator, error) {
 	var keys = make([]*keyReadWriter, 0, enc.n)
 	var necessary io.ReadWriteCloser
@@ -231,49 +294,122 @@ func (enc *EncryptCmdConf) getOutput() ([]*keyReadWriter, io.ReadWriter, *TaskIn
 
 	// 输出到指定文件夹下
 	enc.outputPath = filepath.Clean(enc.outputPath)
-	err := os.MkdirAll(enc.outputPath, 0750)
-	if err != nil {
+	enc.tx = secure.NewTransaction()
+	if err := enc.mkdir(enc.outputPath, 0750); err != nil {
 		return nil, nil, nil, err
 	}
 
-	err = path.CheckNoKey(enc.outputPath)
+	err := path.CheckNoKey(enc.outputPath)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	if manifestFile := filepath.Join(enc.outputPath, path.ManifestFileName); path.IsExist(manifestFile) {
+		return nil, nil, nil, fmt.Errorf("manifest file %s is exist", manifestFile)
+	}
 
 	var opened []io.Closer
-	var paths []string
+	fail := func() {
+		closeClosers(opened)
+		enc.rollbackOutput()
+	}
+	necessaryWriters := make([]io.Writer, 0, enc.n)
 	for i := 0; i < enc.n; i++ {
-		xKeyFileName := filepath.Join(enc.outputPath, getXKeyFileName(i))
-		xKeyFile, err := os.OpenFile(xKeyFileName, os.O_CREATE|os.O_WRONLY, defaultFilePermission)
+		dir := enc.layout.holderDir(enc.outputPath, i)
+		if err = enc.mkdir(dir, 0700); err != nil {
+			fail()
+			return nil, nil, nil, err
+		}
+
+		xKeyFileName := filepath.Join(dir, getXKeyFileName(i))
+		xKeyFile, err := enc.tx.Create(xKeyFileName)
 		if err != nil {
-			rollback(opened, paths)
+			fail()
 			return nil, nil, nil, fmt.Errorf("create x key file %s failed: %w", xKeyFileName, err)
 		}
-		opened = append(opened, xKeyFile)
-		paths = append(paths, xKeyFileName)
+		xKeyWriter, err := enc.seal.sealWriter(i, xKeyFile)
+		if err != nil {
+			fail()
+			return nil, nil, nil, fmt.Errorf("seal x key file %s failed: %w", xKeyFileName, err)
+		}
+		opened = append(opened, xKeyWriter)
 
-		yKeyFileName := filepath.Join(enc.outputPath, getYKeyFileName(i))
-		yKeyFile, err := os.OpenFile(yKeyFileName, os.O_CREATE|os.O_WRONLY, defaultFilePermission)
+		yKeyFileName := filepath.Join(dir, getYKeyFileName(i))
+		yKeyFile, err := enc.tx.Create(yKeyFileName)
+		if err != nil {
+			fail()
+			return nil, nil, nil, fmt.Errorf("create y key file %s failed: %w", yKeyFileName, err)
+		}
+		yKeyWriter, err := enc.seal.sealWriter(i, yKeyFile)
 		if err != nil {
-			rollback(opened, paths)
-			return nil, nil, nil, fmt.Errorf("create y key file %s failed: %w", xKeyFileName, err)
+			fail()
+			return nil, nil, nil, fmt.Errorf("seal y key file %s failed: %w", yKeyFileName, err)
 		}
-		opened = append(opened, yKeyFile)
-		paths = append(paths, yKeyFileName)
-		keys = append(keys, NewKeyReadWriter(xKeyFile, yKeyFile))
+		opened = append(opened, yKeyWriter)
+		keys = append(keys, NewKeyReadWriter(NewWriteOnly(xKeyWriter), NewWriteOnly(yKeyWriter)))
+
+		// 平铺时只有一份必须密钥，按持有人分开时每个持有人一份副本
+		if i != 0 && !enc.layout.perHolder() {
+			continue
+		}
+		necessaryKeyFileName := filepath.Join(dir, path.NecessaryFileName)
+		necessaryFile, err := enc.tx.Create(necessaryKeyFileName)
+		if err != nil {
+			fail()
+			return nil, nil, nil, fmt.Errorf("create necessary key file %s failed: %w", necessaryKeyFileName, err)
+		}
+		opened = append(opened, necessaryFile)
+		necessaryWriters = append(necessaryWriters, necessaryFile)
 	}
 
-	necessaryKeyFileName := filepath.Join(enc.outputPath, path.NecessaryFileName)
-	necessary, err = os.OpenFile(necessaryKeyFileName, os.O_CREATE|os.O_WRONLY, defaultFilePermission)
+	return keys, NewWriteOnly(io.MultiWriter(necessaryWriters...)), NewTaskIndicator(func() { closeClosers(opened) }, fail), nil
+}
+
+// mkdir 创建不存在的目录，记录下来用于失败时删除
+func (enc *EncryptCmdConf) mkdir(dir string, perm os.FileMode) error {
+	if path.IsExist(dir) {
+		return nil
+	}
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return err
+	}
+	enc.createdDirs = append(enc.createdDirs, dir)
+	return nil
+}
+
+// finishOutput 写入清单并签名，写入持有人的说明，打包后提交所有文件。签名覆盖清单，需在清单之后
+func (enc *EncryptCmdConf) finishOutput() error {
+	err := enc.manifest.write(enc.tx, enc.layout.holderDir, enc.outputPath, enc.t, enc.n, enc.fast)
 	if err != nil {
-		rollback(opened, paths)
-		return nil, nil, nil, fmt.Errorf("create necessary key file %s failed: %w", necessaryKeyFileName, err)
+		return err
+	}
+	if err = enc.sign.signShares(enc.tx, enc.layout.holderDir, enc.outputPath, enc.n); err != nil {
+		return err
+	}
+	if !enc.layout.perHolder() {
+		return enc.tx.Commit()
 	}
-	opened = append(opened, necessary)
-	paths = append(paths, necessaryKeyFileName)
 
-	return keys, necessary, NewTaskIndicator(func() { closeClosers(opened) }, func() { rollback(opened, paths) }), nil
+	for i := 0; i < enc.n; i++ {
+		note := holderNote{holder: i, t: enc.t, n: enc.n, label: enc.manifest.labels[i],
+			signed: enc.sign.privateKey != nil, openFlags: enc.seal.openFlags(i)}
+		if err := enc.layout.writeReadme(enc.tx, enc.outputPath, note); err != nil {
+			return err
+		}
+	}
+	if enc.layout.pack {
+		if err := enc.layout.packHolders(enc.tx, enc.outputPath, enc.n); err != nil {
+			return err
+		}
+	}
+	return enc.tx.Commit()
+}
+
+// rollbackOutput 删除本次写入的临时文件和创建的目录，目录不为空时保留
+func (enc *EncryptCmdConf) rollbackOutput() {
+	enc.tx.Rollback()
+	for i := len(enc.createdDirs) - 1; i >= 0; i-- {
+		_ = os.Remove(enc.createdDirs[i])
+	}
 }
 
 func getXKeyFileName(id int) string {
@@ -283,11 +419,6 @@ func getYKeyFileName(id int) string {
 	return fmt.Sprintf("%s%d", path.YKeyFilePrefix, id)
 }
 
-func rollback(opened []io.Closer, paths []string) {
-	closeClosers(opened)
-	deleteFiles(paths)
-}
-
 func closeClosers(opened []io.Closer) {
 	for _, file := range opened {
 		err := file.Close()
@@ -297,40 +428,11 @@ func closeClosers(opened []io.Closer) {
 	}
 }
 
-func deleteFiles(paths []string) {
-	for _, file := range paths {
-		err := os.Remove(file)
-		if err != nil {
-			log.Error(err)
-		}
-	}
-}
-
-func getKeyDecoders(keys []*keyReadWriter) []*xyKeyDecoder {
-	decoders := make([]*xyKeyDecoder, 0, len(keys))
+func getKeyDecoders(keys []*keyReadWriter) []shamir.KeyWriter {
+	decoders := make([]shamir.KeyWriter, 0, len(keys))
 	for _, key := range keys {
 		decoders = append(decoders, key.ToXYKeyDecoder())
 	}
 
 	return decoders
 }
-
-func (enc *EncryptCmdConf) encrypt(keys []*xyKeyDecoder, necessary *code.KeyDecoder, secret *big.Int) error {
-	subKeys, subPrime, e := shamir.Encrypt(secret, enc.t, enc.n, enc.fast)
-	if e != nil {
-		return e
-	}
-	for i, key := range subKeys {
-		e = keys[i].decoder(&key)
-		if e != nil {
-			return e
-		}
-	}
-
-	e = necessary.Write(subPrime)
-	if e != nil {
-		return e
-	}
-
-	return nil
-}
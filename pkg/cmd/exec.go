@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"shamir/pkg/utils/secure"
+)
+
+// 秘密交给子进程的方式
+const (
+	ExecViaStdin = "stdin"
+	ExecViaEnv   = "env"
+	ExecViaMemfd = "memfd"
+
+	defaultExecEnv = "SHAMIR_SECRET"
+	// execFileEnv memfd 方式下子进程中秘密文件路径的环境变量
+	execFileEnv = "SHAMIR_SECRET_FILE"
+	// execFilePlaceholder memfd 方式下命令参数中替换为秘密文件路径的占位符
+	execFilePlaceholder = "{}"
+	// execFile memfd 作为子进程的第一个额外文件，描述符为3
+	execFile = "/dev/fd/3"
+)
+
+// execConf 还原出秘密后运行 -- 之后的命令而不是输出秘密，秘密只通过标准输入、环境变量或匿名内存文件交给子进程，
+// 子进程退出后清零秘密，并以子进程的退出码退出
+type execConf struct {
+	enabled bool
+	via     string
+	env     string
+	command []string
+
+	secret *execSecret
+}
+
+func (e *execConf) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&e.enabled, "exec", false, "Run the command given after -- with the secret instead of "+
+		"printing it, the secret is wiped after the command exits and its exit code is returned")
+	cmd.Flags().StringVar(&e.via, "exec-via", ExecViaStdin, "How to pass the secret to the command [stdin|env|memfd], "+
+		"memfd passes an anonymous memory file as fd 3, its path replaces {} in the command and is set in $"+execFileEnv+
+		", the copy of the secret passed by env can not be wiped")
+	cmd.Flags().StringVar(&e.env, "exec-env", defaultExecEnv, "The environment variable of the secret when use --exec-via env")
+}
+
+// parseArgs 使用 --exec 时 -- 之后的参数是要运行的命令，否则不接受参数
+func (e *execConf) parseArgs(cmd *cobra.Command, args []string) error {
+	if !e.enabled {
+		return NoArgs(cmd, args)
+	}
+	if len(args) == 0 || cmd.ArgsLenAtDash() != 0 {
+		_ = cmd.Usage()
+		return fmt.Errorf("please give the command after --, such as: --exec -- cmd args")
+	}
+
+	e.command = args
+	return nil
+}
+
+func (e *execConf) check(output string) error {
+	if !e.enabled {
+		return nil
+	}
+	if output != "" {
+		return fmt.Errorf("can not use -o with --exec, the secret is passed to the command")
+	}
+
+	switch e.via {
+	case ExecViaStdin, ExecViaMemfd:
+		return nil
+	case ExecViaEnv:
+		if e.env == "" || strings.ContainsAny(e.env, "=\x00") {
+			return fmt.Errorf("invalid environment variable name %q", e.env)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid exec via %q, should be one of [%s|%s|%s]", e.via, ExecViaStdin, ExecViaEnv, ExecViaMemfd)
+	}
+}
+
+// open 创建暂存秘密的输出，失败或运行结束后清零
+func (e *execConf) open() (io.WriteCloser, *TaskIndicator, error) {
+	secret, err := newExecSecret(e.via == ExecViaMemfd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e.secret = secret
+	return secret, NewTaskIndicator(secret.Destroy, secret.Destroy), nil
+}
+
+// run 运行命令并等待退出，期间收到的信号转发给子进程
+func (e *execConf) run(cmd *cobra.Command) error {
+	args := e.command
+	child := exec.Command(args[0], args[1:]...)
+	child.Stdin = cmd.InOrStdin()
+	child.Stdout = cmd.OutOrStdout()
+	child.Stderr = cmd.ErrOrStderr()
+	child.Env = os.Environ()
+
+	switch e.via {
+	case ExecViaStdin:
+		child.Stdin = bytes.NewReader(e.secret.buffer.Bytes())
+	case ExecViaEnv:
+		// 环境变量只能以字符串传递，无法清零，只适合较短的秘密
+		child.Env = append(child.Env, e.env+"="+string(e.secret.buffer.Bytes()))
+	case ExecViaMemfd:
+		// 只读地重新打开，子进程无法修改秘密，读取位置也与写入时无关
+		file, err := os.Open(fmt.Sprintf("/proc/self/fd/%d", e.secret.file.Fd()))
+		if err != nil {
+			return fmt.Errorf("open memory file failed: %w", err)
+		}
+		defer file.Close()
+
+		child.ExtraFiles = []*os.File{file}
+		child.Env = append(child.Env, execFileEnv+"="+execFile)
+		child.Args = make([]string, 0, len(args))
+		for _, arg := range args {
+			child.Args = append(child.Args, strings.ReplaceAll(arg, execFilePlaceholder, execFile))
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("run command %q failed: %w", args[0], err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- child.Wait()
+	}()
+
+	for {
+		select {
+		case s := <-signals:
+			_ = child.Process.Signal(s)
+		case err := <-done:
+			return newExecExitError(args[0], err)
+		}
+	}
+}
+
+// execExitError 子进程非0退出，被信号结束时按照 shell 的惯例退出码为 128+信号值
+type execExitError struct {
+	name string
+	code int
+}
+
+func newExecExitError(name string, err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		if err != nil {
+			return fmt.Errorf("run command %q failed: %w", name, err)
+		}
+		return nil
+	}
+
+	code := exitErr.ExitCode()
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		code = 128 + int(status.Signal())
+	}
+	return &execExitError{name: name, code: code}
+}
+
+func (e *execExitError) Error() string {
+	return fmt.Sprintf("command %q exited with code %d", e.name, e.code)
+}
+
+func (e *execExitError) ExitCode() int {
+	return e.code
+}
+
+// execSecret 暂存还原出的秘密，memfd 方式写入匿名内存文件，不占用进程内存，其他方式写入扩容时清零的缓冲
+type execSecret struct {
+	buffer *secure.Buffer
+	file   *os.File
+}
+
+func newExecSecret(memfd bool) (*execSecret, error) {
+	if !memfd {
+		return &execSecret{buffer: &secure.Buffer{}}, nil
+	}
+
+	fd, err := unix.MemfdCreate("shamir-secret", unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("create memory file failed: %w", err)
+	}
+	return &execSecret{file: os.NewFile(uintptr(fd), "memfd:shamir-secret")}, nil
+}
+
+func (s *execSecret) Write(p []byte) (int, error) {
+	if s.file != nil {
+		return s.file.Write(p)
+	}
+	return s.buffer.Write(p)
+}
+
+// Close 秘密需要保留到子进程退出，由 Destroy 清零
+func (s *execSecret) Close() error {
+	return nil
+}
+
+// Rewind 清零已写入的秘密，从头重新写入
+func (s *execSecret) Rewind() error {
+	if s.file == nil {
+		s.buffer.Reset()
+		return nil
+	}
+	if err := s.wipeFile(); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(0, io.SeekStart)
+	return err
+}
+
+// Destroy 清零秘密，可以重复调用
+func (s *execSecret) Destroy() {
+	if s.buffer != nil {
+		s.buffer.Reset()
+	}
+	if s.file == nil {
+		return
+	}
+
+	_ = s.wipeFile()
+	_ = s.file.Close()
+	s.file = nil
+}
+
+// wipeFile 清零匿名内存文件的内容后截断
+func (s *execSecret) wipeFile() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	zero := make([]byte, 32*1024)
+	for offset := int64(0); offset < info.Size(); offset += int64(len(zero)) {
+		if _, err = s.file.WriteAt(zero, offset); err != nil {
+			return err
+		}
+	}
+	return s.file.Truncate(0)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptExecExitCode(t *testing.T) {
+	dir := encryptShares(t, 2, 3, true)
+
+	_, _, err := runShamir(t, "decrypt", "-i", dir, "--exec", "--", "sh", "-c", "exit 3")
+	var exitErr interface{ ExitCode() int }
+	require.True(t, errors.As(err, &exitErr), "error should have exit code: %v", err)
+	assert.Equal(t, 3, exitErr.ExitCode())
+
+	_, _, err = runShamir(t, "decrypt", "-i", dir, "--exec", "--", "sh", "-c", `test "$(cat)" = "$0"`, testSecret)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+
+	"shamir/pkg/utils/secure"
+)
+
+// 生成的秘密的类型
+const (
+	GenerateTypePassword = "password"
+	GenerateTypeHex      = "hex"
+	GenerateTypeBase64   = "base64"
+	GenerateTypeEd25519  = "ed25519-key"
+	GenerateTypeAge      = "age-identity"
+
+	defaultGenerateBytes = 32
+	// minGenerateBytes 输出的指纹可以用来验证对秘密的猜测，秘密需要足够的熵
+	minGenerateBytes = 16
+	maxGenerateBytes = 1 << 16
+	// passwordAlphabet 口令使用的字符，不包含引号、空白等在 shell 和配置文件中需要转义的字符
+	passwordAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#%+-=@^_~"
+)
+
+type GenerateCmdConf struct {
+	// 复用加密时拆分和输出的逻辑，包括布局、清单、份额加密和签名
+	encrypt EncryptCmdConf
+	bytes   int
+	typ     string
+}
+
+func NewGenerateCommand() *cobra.Command {
+	cmd := &cobra.Command{}
+	// 秘密只在内存中，拆分很快，不显示进度
+	conf := &GenerateCmdConf{encrypt: EncryptCmdConf{progress: progressConf{mode: ProgressNone}}}
+	cmd.Use = "generate"
+	cmd.Short = "Generate a random secret and split it, without ever displaying the secret"
+	cmd.Long =
+		`Generate a random secret and split it, without ever displaying the secret
+
+The secret is generated from crypto/rand and split into n keys in the output path directly,
+so the secret is born split and no single person ever sees it.
+Only the public part is printed: the public key of ed25519-key and age-identity,
+or the SHA-256 fingerprint of password, hex and base64, which can identify the secret after restored.
+
+Types of the secret:
+  password      printable password of --bytes characters
+  hex           --bytes random bytes in hex
+  base64        --bytes random bytes in base64
+  ed25519-key   ed25519 private key in PKCS#8 PEM, can be used by --sign after restored
+  age-identity  age X25519 identity, can be used by --identity after restored
+
+Shares should be handed to holders without being seen together, use --layout per-holder with
+--recipient, --pgp-recipient or --protect-shares to seal every share for its holder.`
+	cmd.Example = `shamir generate --bytes 32 -t 3 -n 5 -o ./keys
+shamir generate --type password --bytes 24 -t 2 -n 3 -o ./keys --layout per-holder --pack
+shamir generate --type ed25519-key -t 2 -n 3 -o ./keys --recipient 0=age1... --recipient 1=age1... --recipient 2=age1...
+shamir generate --type age-identity -t 2 -n 3 -o ./keys --label 0=alice --label 1=bob --label 2=carol`
+	cmd.Args = NoArgs
+	enc := &conf.encrypt
+	cmd.Flags().IntVar(&conf.bytes, "bytes", defaultGenerateBytes, "The size of the random secret in bytes, "+
+		"the length of password. not work with ed25519-key and age-identity")
+	cmd.Flags().StringVar(&conf.typ, "type", GenerateTypeHex, "The type of the secret "+
+		"[password|hex|base64|ed25519-key|age-identity]")
+	cmd.Flags().BoolVarP(&enc.fast, "fast", "f", true, "Use exist prime to encrypt secret, it will be fast")
+	cmd.Flags().StringVarP(&enc.outputPath, "output-path", "o", "", "Output the keys to path")
+	cmd.Flags().IntVarP(&enc.t, "threshold", "t", 0, "The key's threshold, use t keys can decrypt the secret")
+	cmd.Flags().IntVarP(&enc.n, "number", "n", 0, "The key's number, this secret will encrypt as n keys")
+	enc.layout.addFlags(cmd)
+	enc.manifest.addEncryptFlags(cmd)
+	enc.seal.addEncryptFlags(cmd)
+	enc.sign.addEncryptFlags(cmd)
+
+	cmd.RunE = conf.RunE
+	return cmd
+}
+
+func (g *GenerateCmdConf) RunE(cmd *cobra.Command, _ []string) error {
+	if err := g.check(cmd); err != nil {
+		return err
+	}
+
+	secret, public, err := g.generate()
+	if err != nil {
+		return fmt.Errorf("generate secret failed: %w", err)
+	}
+	input := secure.NewWipeReader(secret)
+	defer closeClosers([]io.Closer{input})
+
+	if err = g.encrypt.split(cmd, input); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), public)
+	return err
+}
+
+func (g *GenerateCmdConf) check(cmd *cobra.Command) error {
+	switch g.typ {
+	case GenerateTypePassword, GenerateTypeHex, GenerateTypeBase64:
+		if g.bytes < minGenerateBytes || g.bytes > maxGenerateBytes {
+			return fmt.Errorf("invalid bytes %d, should be in [%d, %d]", g.bytes, minGenerateBytes, maxGenerateBytes)
+		}
+	case GenerateTypeEd25519, GenerateTypeAge:
+		if cmd.Flags().Changed("bytes") {
+			return fmt.Errorf("can not use --bytes with --type %s", g.typ)
+		}
+	default:
+		return fmt.Errorf("invalid type %q, should be one of [%s|%s|%s|%s|%s]", g.typ, GenerateTypePassword,
+			GenerateTypeHex, GenerateTypeBase64, GenerateTypeEd25519, GenerateTypeAge)
+	}
+
+	// 在终端中显示全部密钥等于显示了秘密
+	if g.encrypt.outputPath == "" {
+		return fmt.Errorf("please use -o, the keys of generated secret can only output to path")
+	}
+	return g.encrypt.checkSplit()
+}
+
+// generate 生成秘密，返回秘密和要输出的公开信息，秘密由调用者清零
+func (g *GenerateCmdConf) generate() ([]byte, string, error) {
+	switch g.typ {
+	case GenerateTypePassword:
+		secret, err := randomPassword(g.bytes)
+		if err != nil {
+			return nil, "", err
+		}
+		return secret, secretFingerprint(secret), nil
+	case GenerateTypeHex, GenerateTypeBase64:
+		data := make([]byte, g.bytes)
+		defer secure.Wipe(data)
+		if _, err := rand.Read(data); err != nil {
+			return nil, "", err
+		}
+
+		var secret []byte
+		if g.typ == GenerateTypeHex {
+			secret = make([]byte, hex.EncodedLen(len(data)))
+			hex.Encode(secret, data)
+		} else {
+			secret = make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+			base64.StdEncoding.Encode(secret, data)
+		}
+		return secret, secretFingerprint(secret), nil
+	case GenerateTypeEd25519:
+		return generateEd25519()
+	default:
+		return generateAgeIdentity()
+	}
+}
+
+// randomPassword 从 passwordAlphabet 中均匀地随机选取 length 个字符
+func randomPassword(length int) ([]byte, error) {
+	size := big.NewInt(int64(len(passwordAlphabet)))
+	password := make([]byte, 0, length)
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			secure.Wipe(password)
+			return nil, err
+		}
+		password = append(password, passwordAlphabet[n.Int64()])
+		secure.WipeInt(n)
+	}
+	return password, nil
+}
+
+// secretFingerprint 秘密的 SHA-256 指纹，格式与 ssh-keygen -l 相同
+func secretFingerprint(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return "fingerprint: SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// generateEd25519 生成 PKCS#8 PEM 格式的 ed25519 私钥，公开信息为 ssh-ed25519 格式的公钥，可用于 --trust
+func generateEd25519() ([]byte, string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+	defer secure.Wipe(privateKey)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, "", err
+	}
+	defer secure.Wipe(der)
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	public := bytes.TrimSpace(ssh.MarshalAuthorizedKey(sshKey))
+	return secret, "public key: " + string(public), nil
+}
+
+// generateAgeIdentity 生成与 age-keygen 格式相同的 age 身份文件，公开信息为 age1 开头的公钥，可用于 --recipient
+func generateAgeIdentity() ([]byte, string, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, "", err
+	}
+
+	recipient := identity.Recipient().String()
+	var buffer secure.Buffer
+	_, _ = fmt.Fprintf(&buffer, "# created: %s\n", time.Now().Format(time.RFC3339))
+	_, _ = fmt.Fprintf(&buffer, "# public key: %s\n", recipient)
+	// age 只提供字符串形式的私钥，无法清零
+	_, _ = fmt.Fprintf(&buffer, "%s\n", identity)
+	secret := append([]byte(nil), buffer.Bytes()...)
+	buffer.Reset()
+	return secret, "public key: " + recipient, nil
+}
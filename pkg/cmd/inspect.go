@@ -0,0 +1,549 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/manifest"
+	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/seal"
+	"shamir/pkg/utils/sign"
+	"shamir/pkg/utils/source"
+	"shamir/pkg/utils/ssss"
+)
+
+// 输入文件的类型
+const (
+	inspectXKey      = "x-key"
+	inspectYKey      = "y-key"
+	inspectNecessary = "necessary-key"
+	inspectSignature = "signature"
+	inspectManifest  = "manifest"
+	inspectSsss      = "ssss-share"
+	// inspectUnknown 改过名的文件，无法区分是x还是y密钥
+	inspectUnknown = "unknown"
+)
+
+type InspectCmdConf struct {
+	format string
+	seal   sealConf
+	sign   signConf
+
+	files []*source.File
+	// necessary 每个位置中的必须密钥，位置中没有时使用输入中唯一的必须密钥
+	necessary map[string]*source.File
+	// manifests 每个位置中的清单，清单无效时为nil
+	manifests map[string]*manifest.Manifest
+	// manifestErrors 位置中无效清单的错误
+	manifestErrors map[string]error
+}
+
+// inspectFile 一个输入文件的信息，MinBits/MaxBits 为各段密钥的最小和最大比特数。
+// HashChunk 密钥与必须密钥的段数相同、必须密钥与清单一致时认为包含hash值的一段，没有可比较的对象时为nil
+type inspectFile struct {
+	File      string `json:"file" yaml:"file"`
+	Type      string `json:"type" yaml:"type"`
+	Holder    string `json:"holder,omitempty" yaml:"holder,omitempty"`
+	Sealed    string `json:"sealed,omitempty" yaml:"sealed,omitempty"`
+	Chunks    int    `json:"chunks,omitempty" yaml:"chunks,omitempty"`
+	MinBits   int    `json:"min_bits,omitempty" yaml:"min_bits,omitempty"`
+	MaxBits   int    `json:"max_bits,omitempty" yaml:"max_bits,omitempty"`
+	HashChunk *bool  `json:"hash_chunk,omitempty" yaml:"hash_chunk,omitempty"`
+	Necessary string `json:"necessary_key,omitempty" yaml:"necessary_key,omitempty"`
+	Signature string `json:"signature,omitempty" yaml:"signature,omitempty"`
+	Manifest  string `json:"manifest,omitempty" yaml:"manifest,omitempty"`
+	Note      string `json:"note,omitempty" yaml:"note,omitempty"`
+}
+
+func NewInspectCommand() *cobra.Command {
+	cmd := &cobra.Command{}
+	conf := &InspectCmdConf{}
+	cmd.Use = "inspect <file|dir>"
+	cmd.Short = "Show metadata and health of key files, without restoring the secret"
+	cmd.Long =
+		`Show metadata and health of key files, without restoring the secret
+
+For every key file found in the input, shows the number of chunks (the "_" separated segments),
+the bit lengths of the chunks, whether the last chunk for the hash of the secret is present
+(key files have as many chunks as the necessary key, the necessary key matches manifest.json),
+and whether the chunks fit the primes of the necessary key in the same location.
+Sealed shares show how they are sealed, and are opened with --identity, --pgp-secret-key or passphrases.
+Shares are checked with the dealer's signature (trusted with --trust) and the fingerprints in manifest.json.
+The commitment to the secret in manifest.json can only be checked by "shamir verify" or "shamir decrypt".`
+	cmd.Example = `shamir inspect ./keys/
+shamir inspect ./keys/shamir_x-key_1
+shamir inspect ./holder-1.tar.gz --identity ~/.ssh/id_ed25519 --trust dealer.pub
+shamir inspect ./keys/ --format json`
+	cmd.Args = ExactArgs(1)
+	cmd.Flags().StringVar(&conf.format, "format", Table, "The output format, [table|yaml|json]")
+	conf.seal.addDecryptFlags(cmd)
+	conf.sign.addDecryptFlags(cmd)
+
+	cmd.RunE = conf.RunE
+	return cmd
+}
+
+func (i *InspectCmdConf) RunE(cmd *cobra.Command, args []string) error {
+	switch i.format {
+	case Table, Yaml, Json:
+	default:
+		return fmt.Errorf("invalid format %q, should be one of [%s|%s|%s]", i.format, Table, Yaml, Json)
+	}
+	if err := i.sign.loadTrusted(); err != nil {
+		return err
+	}
+	if err := i.seal.parseIdentities(); err != nil {
+		return err
+	}
+
+	var err error
+	inputs, only := inspectInputs(args[0])
+	i.files, err = source.Collect(inputs)
+	if err != nil {
+		return err
+	}
+	if len(i.files) == 0 {
+		return fmt.Errorf("no key files found in %s", args[0])
+	}
+	i.locate()
+
+	results := make([]*inspectFile, 0, len(i.files))
+	for _, file := range i.files {
+		if only != "" && file.Source() != only {
+			continue
+		}
+		results = append(results, i.inspect(file))
+	}
+	return i.render(cmd, results)
+}
+
+// inspectInputs 输入是单个密钥文件时，同时读取同一目录中的其他密钥文件和清单，用于找到另一半密钥、
+// 必须密钥、签名和清单，但只显示该文件，返回的 only 为该文件的路径
+func inspectInputs(input string) ([]string, string) {
+	file := filepath.Clean(input)
+	stat, err := os.Stat(file)
+	if err != nil || !stat.Mode().IsRegular() || source.IsArchive(file) {
+		return []string{input}, ""
+	}
+
+	inputs := []string{file}
+	dir := filepath.Dir(file)
+	if siblings, e := path.GetAllKeyFile(dir); e == nil {
+		inputs = append(inputs, siblings...)
+	}
+	if m := filepath.Join(dir, path.ManifestFileName); path.IsExist(m) {
+		inputs = append(inputs, m)
+	}
+	return inputs, file
+}
+
+// locate 找到每个位置中的必须密钥和清单
+func (i *InspectCmdConf) locate() {
+	i.necessary = make(map[string]*source.File)
+	i.manifests = make(map[string]*manifest.Manifest)
+	i.manifestErrors = make(map[string]error)
+	for _, file := range i.files {
+		switch file.Name {
+		case path.NecessaryFileName:
+			i.necessary[file.Dir] = file
+		case path.ManifestFileName:
+			reader, err := file.Open()
+			if err != nil {
+				i.manifestErrors[file.Dir] = err
+				continue
+			}
+			i.manifests[file.Dir], err = manifest.Load(reader)
+			closeClosers([]io.Closer{reader})
+			if err != nil {
+				i.manifestErrors[file.Dir] = err
+			}
+		}
+	}
+}
+
+// necessaryOf 返回位置 dir 对应的必须密钥，没有时为nil
+func (i *InspectCmdConf) necessaryOf(dir string) *source.File {
+	if necessary, ok := i.necessary[dir]; ok {
+		return necessary
+	}
+	// 输入中的必须密钥内容不一致时无法确定使用哪一个
+	necessary, _ := findUnique(i.files, path.NecessaryFileName)
+	return necessary
+}
+
+// manifestOf 返回位置 dir 对应的清单文件，与 necessaryOf 相同，位置中没有时使用输入中唯一的清单
+func (i *InspectCmdConf) manifestOf(dir string) *source.File {
+	if file := i.find(dir, path.ManifestFileName); file != nil {
+		return file
+	}
+	file, _ := findUnique(i.files, path.ManifestFileName)
+	return file
+}
+
+// find 返回位置 dir 中名为 name 的文件，没有时为nil
+func (i *InspectCmdConf) find(dir, name string) *source.File {
+	for _, file := range i.files {
+		if file.Dir == dir && file.Name == name {
+			return file
+		}
+	}
+	return nil
+}
+
+func (i *InspectCmdConf) inspect(file *source.File) *inspectFile {
+	result := &inspectFile{File: file.Source()}
+	switch {
+	case strings.HasPrefix(file.Name, path.XKeyFilePrefix):
+		result.Type, result.Holder = inspectXKey, strings.TrimPrefix(file.Name, path.XKeyFilePrefix)
+		i.inspectKey(file, result)
+		i.inspectPair(file, result)
+	case strings.HasPrefix(file.Name, path.YKeyFilePrefix):
+		result.Type, result.Holder = inspectYKey, strings.TrimPrefix(file.Name, path.YKeyFilePrefix)
+		i.inspectKey(file, result)
+		i.inspectPair(file, result)
+	case file.Name == path.NecessaryFileName:
+		result.Type = inspectNecessary
+		i.inspectNecessary(file, result)
+	case strings.HasPrefix(file.Name, path.SignatureFilePrefix):
+		result.Type, result.Holder = inspectSignature, strings.TrimPrefix(file.Name, path.SignatureFilePrefix)
+		i.inspectSignature(file, result)
+	case file.Name == path.ManifestFileName:
+		result.Type = inspectManifest
+		i.inspectManifest(file, result)
+	case strings.HasPrefix(file.Name, path.SsssShareFilePrefix):
+		result.Type = inspectSsss
+		inspectSsssShare(file, result)
+	default:
+		result.Type = inspectUnknown
+		i.inspectKey(file, result)
+	}
+	return result
+}
+
+// inspectKey 逐段读取x或y密钥，与同一位置的必须密钥逐段比较
+func (i *InspectCmdConf) inspectKey(file *source.File, result *inspectFile) {
+	var err error
+	if result.Sealed, err = sealFormat(file); err != nil {
+		result.Note = err.Error()
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		result.Note = err.Error()
+		return
+	}
+	defer closeClosers([]io.Closer{f})
+	reader, err := i.seal.openReader("holder "+result.Holder, f)
+	if err != nil {
+		result.Note = err.Error()
+		return
+	}
+
+	var primes *code.KeyEncoder
+	necessaryChunks := 0
+	necessary := i.necessaryOf(file.Dir)
+	switch {
+	case result.Type == inspectUnknown:
+	case necessary == nil:
+		result.Necessary = "necessary key not found"
+	default:
+		n, e := necessary.Open()
+		if e != nil {
+			result.Necessary = e.Error()
+		} else {
+			defer closeClosers([]io.Closer{n})
+			primes = code.NewKeyEncoder(n)
+			necessaryChunks, err = countKeyChunks(necessary)
+			if err != nil {
+				result.Necessary, primes = "invalid necessary key", nil
+			}
+		}
+	}
+
+	keys := code.NewKeyEncoder(reader)
+	for {
+		key, isHash, e := keys.Read()
+		if e != nil {
+			result.Note = e.Error()
+			if result.Type == inspectUnknown {
+				result.Note = "not a key file of shamir"
+			}
+			return
+		}
+		result.Chunks++
+		addBits(result, key.BitLen())
+
+		if primes != nil && result.Chunks <= necessaryChunks {
+			prime, _, pe := primes.Read()
+			if pe != nil {
+				result.Necessary, primes = "invalid necessary key", nil
+			} else if pe = checkKeyPart(result.Type, key, prime); pe != nil {
+				result.Necessary, primes = fmt.Sprintf("part %d: %v", result.Chunks-1, pe), nil
+			}
+		}
+		if isHash {
+			break
+		}
+	}
+	if necessaryChunks > 0 {
+		hashChunk := result.Chunks == necessaryChunks
+		result.HashChunk = &hashChunk
+	}
+	if result.Type == inspectUnknown {
+		result.Note = "not named as a key file of shamir, can not tell x or y key"
+	}
+	if primes != nil {
+		result.Necessary = "match"
+		if result.Chunks != necessaryChunks {
+			result.Necessary = fmt.Sprintf("key has %d parts but necessary key has %d", result.Chunks, necessaryChunks)
+		}
+	}
+}
+
+// checkKeyPart 检查一段密钥的取值与必须密钥中对应的素数是否匹配
+func checkKeyPart(typ string, key, prime *big.Int) error {
+	if prime.Sign() <= 0 {
+		return fmt.Errorf("invalid prime")
+	}
+	if typ == inspectXKey {
+		if new(big.Int).Mod(key, prime).Sign() == 0 {
+			return fmt.Errorf("x key is zero")
+		}
+		return nil
+	}
+	if key.Sign() < 0 || key.Cmp(prime) >= 0 {
+		return fmt.Errorf("y key out of range")
+	}
+	return nil
+}
+
+// inspectPair 找到同一位置中的另一半密钥文件，检查密钥对的签名以及是否在清单中，
+// 签名和指纹都基于密钥文件的原始内容，被加密的份额不需要解密
+func (i *InspectCmdConf) inspectPair(file *source.File, result *inspectFile) {
+	xFile, yFile := file, i.find(file.Dir, path.YKeyFilePrefix+result.Holder)
+	if result.Type == inspectYKey {
+		xFile, yFile = i.find(file.Dir, path.XKeyFilePrefix+result.Holder), file
+	}
+	if xFile == nil || yFile == nil {
+		addNote(result, "the other key file of the pair not found")
+		return
+	}
+
+	x, err := hashSource(xFile)
+	if err != nil {
+		addNote(result, err.Error())
+		return
+	}
+	y, err := hashSource(yFile)
+	if err != nil {
+		addNote(result, err.Error())
+		return
+	}
+
+	if m := i.manifests[file.Dir]; m != nil {
+		result.Manifest = "not listed"
+		if share := m.Share(manifest.Fingerprint(x, y)); share != nil {
+			result.Manifest = fmt.Sprintf("listed as holder %d", share.Holder)
+			if share.Label != "" {
+				result.Manifest += fmt.Sprintf(" (%s)", share.Label)
+			}
+		}
+	}
+
+	result.Signature = i.signatureStatus(file.Dir, result.Holder, x, y)
+}
+
+// signatureStatus 密钥对的签名状态，没有 --trust 时只校验签名本身并显示签名者
+func (i *InspectCmdConf) signatureStatus(dir, holder string, x, y []byte) string {
+	signatureFile := i.find(dir, path.SignatureFilePrefix+holder)
+	if signatureFile == nil {
+		return string(sign.Unsigned)
+	}
+	data, err := readSource(signatureFile)
+	if err != nil {
+		return err.Error()
+	}
+	signature, err := parseSignature(data, signatureFile.Source())
+	if err != nil {
+		return string(sign.Invalid)
+	}
+	necessaryFile := i.necessaryOf(dir)
+	if necessaryFile == nil {
+		return "unknown, necessary key not found"
+	}
+	necessary, err := hashSource(necessaryFile)
+	if err != nil {
+		return err.Error()
+	}
+	var manifestSum []byte
+	if manifestFile := i.manifestOf(dir); manifestFile != nil {
+		if manifestSum, err = hashSource(manifestFile); err != nil {
+			return err.Error()
+		}
+	}
+
+	envelope := &sign.Envelope{Holder: holder, X: x, Y: y, Necessary: necessary, Manifest: manifestSum}
+	status := sign.Check(i.sign.trusted, envelope, signature)
+	switch {
+	case status == sign.Valid && i.sign.trusted == nil:
+		return "signed by " + sign.Fingerprint(signature.PublicKey)
+	case status == sign.Valid:
+		return "valid, trusted dealer"
+	case status == sign.Untrusted:
+		return "untrusted, signed by " + sign.Fingerprint(signature.PublicKey)
+	default:
+		return string(status)
+	}
+}
+
+// inspectNecessary 逐段读取必须密钥中的素数，与同一位置的清单比较
+func (i *InspectCmdConf) inspectNecessary(file *source.File, result *inspectFile) {
+	f, err := file.Open()
+	if err != nil {
+		result.Note = err.Error()
+		return
+	}
+	defer closeClosers([]io.Closer{f})
+
+	primes := code.NewKeyEncoder(f)
+	for {
+		prime, isHash, e := primes.Read()
+		if e != nil {
+			result.Note = e.Error()
+			return
+		}
+		result.Chunks++
+		addBits(result, prime.BitLen())
+		if isHash {
+			break
+		}
+	}
+	if m := i.manifests[file.Dir]; m != nil {
+		sum, e := hashSource(file)
+		if e != nil {
+			result.Note = e.Error()
+			return
+		}
+		result.Manifest = "match"
+		if m.CheckNecessary(sum) != nil {
+			result.Manifest = "not match"
+		}
+		// 与清单一致时必须密钥完整，包括最后hash值的一段
+		hashChunk := result.Manifest == "match"
+		result.HashChunk = &hashChunk
+	}
+}
+
+func (i *InspectCmdConf) inspectSignature(file *source.File, result *inspectFile) {
+	data, err := readSource(file)
+	if err != nil {
+		result.Note = err.Error()
+		return
+	}
+	signature, err := parseSignature(data, file.Source())
+	if err != nil {
+		result.Signature, result.Note = string(sign.Invalid), err.Error()
+		return
+	}
+
+	result.Signature = "signed by " + sign.Fingerprint(signature.PublicKey)
+	if i.sign.trusted != nil {
+		if i.sign.trusted.Equal(signature.PublicKey) {
+			result.Signature += ", trusted dealer"
+		} else {
+			result.Signature += ", untrusted"
+		}
+	}
+	if signature.Holder != result.Holder {
+		result.Note = fmt.Sprintf("signature is for holder %s", signature.Holder)
+	}
+}
+
+func (i *InspectCmdConf) inspectManifest(file *source.File, result *inspectFile) {
+	if err := i.manifestErrors[file.Dir]; err != nil {
+		result.Manifest, result.Note = "invalid", err.Error()
+		return
+	}
+	m := i.manifests[file.Dir]
+	result.Manifest = "valid"
+	result.Note = fmt.Sprintf("%d of %d shares, chunk size %d, created by %s at %s, commitment %s",
+		m.Threshold, m.Number, m.ChunkSize, m.Tool, m.CreatedAt.Format(time.RFC3339), m.Commitment.Algorithm)
+}
+
+func inspectSsssShare(file *source.File, result *inspectFile) {
+	data, err := readSource(file)
+	if err != nil {
+		result.Note = err.Error()
+		return
+	}
+	share, err := ssss.ParseShare(string(data))
+	if err != nil {
+		result.Note = err.Error()
+		return
+	}
+
+	result.Holder = strconv.Itoa(share.Index)
+	result.Chunks = 1
+	result.MinBits, result.MaxBits = share.Degree, share.Degree
+	if share.Token != "" {
+		result.Note = "token " + share.Token
+	}
+}
+
+// sealFormat 返回份额加密的格式，未加密时为空
+func sealFormat(file *source.File) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer closeClosers([]io.Closer{f})
+	return seal.Format(f)
+}
+
+func addNote(result *inspectFile, note string) {
+	if result.Note != "" {
+		result.Note += "; "
+	}
+	result.Note += note
+}
+
+func addBits(result *inspectFile, bits int) {
+	if result.MinBits == 0 || bits < result.MinBits {
+		result.MinBits = bits
+	}
+	if bits > result.MaxBits {
+		result.MaxBits = bits
+	}
+}
+
+func (i *InspectCmdConf) render(cmd *cobra.Command, results []*inspectFile) error {
+	header := []string{"FILE", "TYPE", "HOLDER", "SEALED", "CHUNKS", "BITS", "HASH CHUNK", "NECESSARY KEY",
+		"SIGNATURE", "MANIFEST", "NOTE"}
+	data := make([][]string, 0, len(results))
+	for _, result := range results {
+		chunks, bits, hash := "", "", ""
+		if result.Chunks > 0 {
+			chunks = strconv.Itoa(result.Chunks)
+			bits = strconv.Itoa(result.MinBits)
+			if result.MaxBits != result.MinBits {
+				bits = fmt.Sprintf("%d-%d", result.MinBits, result.MaxBits)
+			}
+			if result.HashChunk != nil {
+				hash = strconv.FormatBool(*result.HashChunk)
+			}
+		}
+		data = append(data, []string{result.File, result.Type, result.Holder, result.Sealed, chunks, bits, hash,
+			result.Necessary, result.Signature, result.Manifest, result.Note})
+	}
+	return RenderData(i.format, header, data, results, cmd.OutOrStdout())
+}
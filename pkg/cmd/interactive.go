@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/shamir"
+)
+
+// checkInteractive 交互式收集份额前的参数检查，避免输入完份额后才发现参数错误
+func (d *DecryptCmdConf) checkInteractive() error {
+	if d.ssss.compat != CompatNone {
+		return fmt.Errorf("can not use --interactive with --compat %s", d.ssss.compat)
+	}
+	if len(d.inputPaths) != 0 || len(d.xKeys) != 0 || len(d.yKeys) != 0 || d.necessary != "" {
+		return fmt.Errorf("can not use --interactive with -i, -x, -y or -n")
+	}
+	if d.t < shamir.MinThreshold {
+		return fmt.Errorf("invalid threshold, please use -t correctly when use --interactive")
+	}
+	if d.output != "" && path.IsExist(d.output) {
+		return fmt.Errorf("output file %q is exist", d.output)
+	}
+	if !IsTerminalInput() {
+		return fmt.Errorf("--interactive must run in a terminal")
+	}
+
+	return nil
+}
+
+// collectShares 在终端中关闭回显，依次输入必须密钥和份额，每输入一个份额都会校验，
+// 收集到 t 个有效份额后才开始解密
+func (d *DecryptCmdConf) collectShares(cmd *cobra.Command) error {
+	if err := d.checkInteractive(); err != nil {
+		return err
+	}
+
+	var primes []*big.Int
+	for primes == nil {
+		necessary, err := ReadHidden("necessary key: ")
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		if primes, ok = code.EncodeKeys(string(necessary)); !ok {
+			primes = nil
+			_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "invalid necessary key, please input again")
+			continue
+		}
+		d.necessary = string(necessary)
+	}
+
+	// 第一段x密钥，重复的份额无法参与解密
+	seen := make(map[string]struct{}, d.t)
+	for len(d.xKeys) < d.t {
+		xKey, err := ReadHidden(fmt.Sprintf("x key of share %d: ", len(d.xKeys)+1))
+		if err != nil {
+			return err
+		}
+		yKey, err := ReadHidden(fmt.Sprintf("y key of share %d: ", len(d.xKeys)+1))
+		if err != nil {
+			return err
+		}
+
+		firstX, err := checkShareKeys(string(xKey), string(yKey), len(primes))
+		if err == nil {
+			if _, ok := seen[firstX]; ok {
+				err = fmt.Errorf("share already collected")
+			}
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "invalid share: %v, please input again\n", err)
+			continue
+		}
+
+		seen[firstX] = struct{}{}
+		d.xKeys = append(d.xKeys, string(xKey))
+		d.yKeys = append(d.yKeys, string(yKey))
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%d of %d shares collected\n", len(d.xKeys), d.t)
+	}
+
+	return nil
+}
+
+// checkShareKeys 校验一个份额的x、y密钥能否解析，段数是否与必须密钥一致，返回第一段x密钥
+func checkShareKeys(xKey, yKey string, chunks int) (string, error) {
+	xKeys, ok := code.EncodeKeys(xKey)
+	if !ok {
+		return "", fmt.Errorf("invalid x key")
+	}
+	yKeys, ok := code.EncodeKeys(yKey)
+	if !ok {
+		return "", fmt.Errorf("invalid y key")
+	}
+	if len(xKeys) != len(yKeys) {
+		return "", fmt.Errorf("x key not match y key")
+	}
+	if len(xKeys) != chunks {
+		return "", fmt.Errorf("keys not match necessary key, has %d parts but need %d", len(xKeys), chunks)
+	}
+
+	return xKeys[0].String(), nil
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/secure"
+	"shamir/pkg/version"
+)
+
+// 密钥文件的布局
+const (
+	LayoutFlat      = "flat"
+	LayoutPerHolder = "per-holder"
+
+	holderDirPrefix = "holder-"
+	holderReadme    = "README.txt"
+	holderPackExt   = ".tar.gz"
+)
+
+// layoutConf 密钥文件在输出目录中的布局，per-holder 为每个持有人写入单独的文件夹，
+// 包含持有人的份额、必须密钥的副本和说明，可以直接交给持有人
+type layoutConf struct {
+	layout string
+	pack   bool
+}
+
+func (l *layoutConf) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&l.layout, "layout", LayoutFlat, "The layout of key files in output path [flat|per-holder], "+
+		"per-holder writes holder-<id>/ with the holder's share, a copy of the necessary key and a README")
+	cmd.Flags().BoolVar(&l.pack, "pack", false, "Pack the folder of each holder into holder-<id>.tar.gz for handoff, "+
+		"only work with --layout per-holder")
+}
+
+func (l *layoutConf) check(outputPath string, n int) error {
+	switch l.layout {
+	case LayoutFlat:
+		if l.pack {
+			return fmt.Errorf("please use --layout %s, when use --pack", LayoutPerHolder)
+		}
+		return nil
+	case LayoutPerHolder:
+	default:
+		return fmt.Errorf("invalid layout %q, should be one of [%s|%s]", l.layout, LayoutFlat, LayoutPerHolder)
+	}
+
+	if outputPath == "" {
+		return fmt.Errorf("please use -o, when use --layout %s", LayoutPerHolder)
+	}
+	for i := 0; i < n; i++ {
+		for _, name := range []string{l.holderDir(outputPath, i), l.holderDir(outputPath, i) + holderPackExt} {
+			if path.IsExist(name) {
+				return fmt.Errorf("invalid output path %q, %s is exist", outputPath, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (l *layoutConf) perHolder() bool {
+	return l.layout == LayoutPerHolder
+}
+
+// holderDir 持有人的密钥文件所在的目录
+func (l *layoutConf) holderDir(outputPath string, holder int) string {
+	if !l.perHolder() {
+		return outputPath
+	}
+	return filepath.Join(outputPath, fmt.Sprintf("%s%d", holderDirPrefix, holder))
+}
+
+// holderNote 写入持有人文件夹的说明
+type holderNote struct {
+	holder, t, n int
+	label        string
+	signed       bool
+	// openFlags 份额被加密时，解密需要的参数
+	openFlags string
+}
+
+func (l *layoutConf) writeReadme(tx *secure.Transaction, outputPath string, note holderNote) error {
+	holder := fmt.Sprintf("%d", note.holder)
+	var builder strings.Builder
+	if note.label != "" {
+		fmt.Fprintf(&builder, "shamir share of holder %s (%s)\n\n", holder, note.label)
+	} else {
+		fmt.Fprintf(&builder, "shamir share of holder %s\n\n", holder)
+	}
+	fmt.Fprintf(&builder, "This folder holds one share of a secret split by shamir %s on %s.\n",
+		version.Version, time.Now().Format("2006-01-02"))
+	fmt.Fprintf(&builder, "Any %d of the %d holders can restore the secret together, "+
+		"fewer shares reveal nothing about it.\n\n", note.t, note.n)
+
+	builder.WriteString("Files:\n")
+	fmt.Fprintf(&builder, "  %s, %s\n      your share, always keep the two files together\n",
+		path.XKeyFilePrefix+holder, path.YKeyFilePrefix+holder)
+	fmt.Fprintf(&builder, "  %s\n      the necessary key, the same copy is given to every holder\n", path.NecessaryFileName)
+	if note.signed {
+		fmt.Fprintf(&builder, "  %s\n      the dealer's signature of your share\n", path.SignatureFilePrefix+holder)
+	}
+	fmt.Fprintf(&builder, "  %s\n      the record of this split, threshold, holders and fingerprints of every share\n",
+		path.ManifestFileName)
+	if note.openFlags != "" {
+		fmt.Fprintf(&builder, "\nYour share is encrypted for you, use %s when restoring the secret.\n", note.openFlags)
+	}
+
+	fmt.Fprintf(&builder, "\nKeep this folder private and never hand it to another holder.\n")
+	fmt.Fprintf(&builder, "To restore the secret, bring the folders or %s archives of %d holders together and run "+
+		"such as:\n\n", holderDirPrefix+"<id>"+holderPackExt, note.t)
+	// 示例从本持有人开始依次选取 t 个持有人
+	inputs := make([]string, 0, note.t)
+	for i := 0; i < note.t; i++ {
+		inputs = append(inputs, fmt.Sprintf("-i %s%d", holderDirPrefix, (note.holder+i)%note.n))
+	}
+	fmt.Fprintf(&builder, "    shamir decrypt %s -t %d -o secret\n", strings.Join(inputs, " "), note.t)
+
+	name := filepath.Join(l.holderDir(outputPath, note.holder), holderReadme)
+	if err := tx.WriteFile(name, []byte(builder.String())); err != nil {
+		return fmt.Errorf("write readme file %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// packHolders 将每个持有人文件夹中的文件打包为 holder-<id>.tar.gz，打包后的文件从事务中删除
+func (l *layoutConf) packHolders(tx *secure.Transaction, outputPath string, n int) error {
+	for i := 0; i < n; i++ {
+		dir := l.holderDir(outputPath, i)
+		var names []string
+		for _, name := range tx.Names() {
+			if filepath.Dir(name) == dir {
+				names = append(names, name)
+			}
+		}
+
+		archive := dir + holderPackExt
+		if err := packFiles(tx, archive, filepath.Base(dir), names); err != nil {
+			return fmt.Errorf("pack %s failed: %w", archive, err)
+		}
+		for _, name := range names {
+			tx.Remove(name)
+		}
+	}
+	return nil
+}
+
+// cleanup 打包后删除空的持有人文件夹
+func (l *layoutConf) cleanup(outputPath string, n int) {
+	if !l.pack {
+		return
+	}
+	for i := 0; i < n; i++ {
+		_ = os.Remove(l.holderDir(outputPath, i))
+	}
+}
+
+// packFiles 将事务中的文件写入 archive，归档中的文件位于 dir 目录下
+func packFiles(tx *secure.Transaction, archive, dir string, names []string) error {
+	file, err := tx.Create(archive)
+	if err != nil {
+		return err
+	}
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	now := time.Now()
+	err = tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0700, ModTime: now})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err = packFile(tarWriter, tx.Path(name), dir+"/"+filepath.Base(name), now); err != nil {
+			return err
+		}
+	}
+
+	if err = tarWriter.Close(); err != nil {
+		return err
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func packFile(tarWriter *tar.Writer, source, member string, modTime time.Time) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     member,
+		Mode:     secure.FilePermission,
+		Size:     info.Size(),
+		ModTime:  modTime,
+	}
+	if err = tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tarWriter, f)
+	return err
+}
@@ -8,6 +8,7 @@ import (
 
 	"shamir/pkg/utils/config"
 	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/secure"
 	"shamir/pkg/version"
 )
 
@@ -52,9 +53,24 @@ For help with any of those, simply call them with --help.`
 	// encrypt command
 	cmd.AddCommand(NewEncryptCommand())
 
+	// generate command
+	cmd.AddCommand(NewGenerateCommand())
+
 	// decrypt command
 	cmd.AddCommand(NewDecryptCommand())
 
+	// serve command
+	cmd.AddCommand(NewServeCommand())
+
+	// agent command
+	cmd.AddCommand(NewAgentCommand())
+
+	// verify command
+	cmd.AddCommand(NewVerifyCommand())
+
+	// inspect command
+	cmd.AddCommand(NewInspectCommand())
+
 	cmd.InitDefaultHelpCmd()
 	cmd.InitDefaultHelpFlag()
 	cmd.InitDefaultVersionFlag()
@@ -69,6 +85,10 @@ func (m *MainCmdConf) PreRun(_ *cobra.Command, _ []string) error {
 	}
 
 	m.initLog()
+	// 秘密不能出现在 core dump 中，失败时只提示，不影响使用
+	if err = secure.DisableCoreDump(); err != nil {
+		log.Warnf("disable core dump failed: %v", err)
+	}
 	return nil
 }
 
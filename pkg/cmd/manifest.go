@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/manifest"
+	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/secure"
+	"shamir/pkg/utils/shamir"
+	"shamir/pkg/utils/sign"
+	"shamir/pkg/utils/source"
+	"shamir/pkg/version"
+)
+
+// manifestConf 加密时在份额旁写入清单 manifest.json，解密时使用输入中的清单确定门限值、识别份额并校验还原出的秘密
+type manifestConf struct {
+	labelFlags []string
+	labels     map[int]string
+	// content 加密时秘密的内容类型，多个输入或目录时为 tar
+	content string
+
+	// digest 加密时读取的秘密或解密时还原出的秘密的 SHA-256 值
+	digest hash.Hash
+	// loaded 解密时输入中的清单，没有时为nil，sum 是清单原始内容的 SHA-256 值，用于校验签名
+	loaded *manifest.Manifest
+	sum    []byte
+}
+
+func (m *manifestConf) addEncryptFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&m.labelFlags, "label", []string{}, "The label of holder written in manifest.json, "+
+		"format is holder=label, such as 0=alice. Can be used repeatedly (must use with -o)")
+}
+
+// parseLabels 解析 holder=label 格式的参数
+func (m *manifestConf) parseLabels(n int) error {
+	m.labels = make(map[int]string, len(m.labelFlags))
+	for _, flag := range m.labelFlags {
+		holder, label, err := parseHolder(flag, n)
+		if err != nil {
+			return err
+		}
+		if label == "" {
+			return fmt.Errorf("invalid label of holder %d, can not be empty", holder)
+		}
+		m.labels[holder] = label
+	}
+	return nil
+}
+
+// secretReader 加密时计算读取的秘密的 SHA-256 值，用于清单中对秘密的承诺
+func (m *manifestConf) secretReader(reader io.Reader) io.Reader {
+	m.digest = sha256.New()
+	return io.TeeReader(reader, m.digest)
+}
+
+// write 在事务 tx 中写入清单，按持有人分开时每个持有人的目录中一份
+func (m *manifestConf) write(tx *secure.Transaction, dir func(outputPath string, holder int) string,
+	outputPath string, t, n int, fast bool) error {
+	necessary, err := sign.HashFile(tx.Path(filepath.Join(dir(outputPath, 0), path.NecessaryFileName)))
+	if err != nil {
+		return err
+	}
+	commitment, err := manifest.NewCommitment(m.digest.Sum(nil))
+	if err != nil {
+		return fmt.Errorf("create commitment of secret failed: %w", err)
+	}
+
+	result := &manifest.Manifest{
+		Version:    manifest.Version,
+		Tool:       "shamir " + version.Version,
+		FastPrime:  fast,
+		Threshold:  t,
+		Number:     n,
+		ChunkSize:  shamir.SplitLen(fast),
+		Content:    m.content,
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+		Necessary:  hex.EncodeToString(necessary),
+		Shares:     make([]manifest.Share, 0, n),
+		Commitment: commitment,
+	}
+	for i := 0; i < n; i++ {
+		x, e := sign.HashFile(tx.Path(filepath.Join(dir(outputPath, i), getXKeyFileName(i))))
+		if e != nil {
+			return e
+		}
+		y, e := sign.HashFile(tx.Path(filepath.Join(dir(outputPath, i), getYKeyFileName(i))))
+		if e != nil {
+			return e
+		}
+		result.Shares = append(result.Shares, manifest.NewShare(i, m.labels[i], x, y))
+	}
+
+	data, err := result.Marshal()
+	if err != nil {
+		return err
+	}
+	written := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		name := filepath.Join(dir(outputPath, i), path.ManifestFileName)
+		if written[name] {
+			continue
+		}
+		written[name] = true
+		if err = tx.WriteFile(name, data); err != nil {
+			return fmt.Errorf("write manifest file %s failed: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// load 找到输入中的清单，多份清单的内容必须一致
+func (m *manifestConf) load(cmd *cobra.Command, files []*source.File) error {
+	file, err := findUnique(files, path.ManifestFileName)
+	if err != nil || file == nil {
+		return err
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("open manifest file %s failed: %w", file.Source(), err)
+	}
+	defer closeClosers([]io.Closer{reader})
+	m.loaded, err = manifest.Load(reader)
+	if err != nil {
+		return fmt.Errorf("load manifest file %s failed: %w", file.Source(), err)
+	}
+
+	infof(cmd, "use manifest %s, %d of %d shares created by %s at %s", file.Source(), m.loaded.Threshold,
+		m.loaded.Number, m.loaded.Tool, m.loaded.CreatedAt.Format(time.RFC3339))
+	if m.sum, err = hashSource(file); err != nil {
+		return err
+	}
+	m.digest = sha256.New()
+	return nil
+}
+
+// checkNecessary 必须密钥需与清单一致
+func (m *manifestConf) checkNecessary(file *source.File) error {
+	if m.loaded == nil {
+		return nil
+	}
+	sum, err := hashSource(file)
+	if err != nil {
+		return err
+	}
+	if err = m.loaded.CheckNecessary(sum); err != nil {
+		return fmt.Errorf("%w: %s", err, file.Source())
+	}
+	return nil
+}
+
+// checkShare 份额的指纹需在清单中，同时记录持有人的标签
+func (m *manifestConf) checkShare(share *shareFiles) error {
+	if m.loaded == nil {
+		return nil
+	}
+	x, err := hashSource(share.x)
+	if err != nil {
+		return err
+	}
+	y, err := hashSource(share.y)
+	if err != nil {
+		return err
+	}
+
+	found := m.loaded.Share(manifest.Fingerprint(x, y))
+	if found == nil {
+		return fmt.Errorf("share not listed in manifest")
+	}
+	share.label = found.Label
+	share.fingerprint = found.Fingerprint
+	return nil
+}
+
+// secretWriter 解密时计算还原出的秘密的 SHA-256 值，没有清单时直接返回 writer。
+// 秘密边写入 writer 边计算，承诺在写完后由 verify 校验，输出到标准输出时秘密已经输出
+func (m *manifestConf) secretWriter(writer io.Writer) io.Writer {
+	if m.loaded == nil {
+		return writer
+	}
+	return io.MultiWriter(writer, m.digest)
+}
+
+// reset 丢弃已经计算的秘密的 SHA-256 值，输出重新写入时调用
+func (m *manifestConf) reset() {
+	if m.loaded != nil {
+		m.digest.Reset()
+	}
+}
+
+// verify 校验还原出的秘密与清单中的承诺是否一致
+func (m *manifestConf) verify() error {
+	if m.loaded == nil {
+		return nil
+	}
+	return m.loaded.Commitment.Verify(m.digest.Sum(nil))
+}
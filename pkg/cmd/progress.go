@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/progress"
+	"shamir/pkg/utils/shamir"
+)
+
+// 进度的输出方式
+const (
+	ProgressAuto = "auto"
+	ProgressJson = "json"
+	ProgressNone = "none"
+)
+
+// progressConf 大文件加密、解密时在标准错误输出进度，auto 只在标准错误是终端并且秘密不输出到终端时显示
+type progressConf struct {
+	mode string
+}
+
+func (p *progressConf) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&p.mode, "progress", ProgressAuto, "Report progress to stderr use [auto|json|none], "+
+		"auto shows bytes, chunks, rate and ETA when stderr is a terminal and output is a file")
+}
+
+func (p *progressConf) check() error {
+	switch p.mode {
+	case ProgressAuto, ProgressJson, ProgressNone:
+		return nil
+	default:
+		return fmt.Errorf("invalid progress %q, should be one of [%s|%s|%s]", p.mode, ProgressAuto, ProgressJson, ProgressNone)
+	}
+}
+
+// newReporter 不需要输出进度时返回nil，toFile 为false时秘密或密钥会输出到终端，auto 时不显示进度
+func (p *progressConf) newReporter(cmd *cobra.Command, operation string, toFile bool, opts ...progress.Option) *progress.Reporter {
+	switch {
+	case p.mode == ProgressJson:
+		return progress.NewReporter(operation, progress.JSON, cmd.ErrOrStderr(), opts...)
+	case p.mode == ProgressAuto && toFile && isTerminalStderr():
+		return progress.NewReporter(operation, progress.Text, cmd.ErrOrStderr(), opts...)
+	default:
+		return nil
+	}
+}
+
+func isTerminalStderr() bool {
+	_, err := unix.IoctlGetTermios(unix.Stderr, unix.TCGETS)
+	return err == nil
+}
+
+// progressReader 统计读取的秘密字节数
+type progressReader struct {
+	reader   io.Reader
+	reporter *progress.Reporter
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.reporter.AddBytes(n)
+	return n, err
+}
+
+// progressWriter 统计写出的秘密字节数
+type progressWriter struct {
+	writer   io.Writer
+	reporter *progress.Reporter
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.reporter.AddBytes(n)
+	return n, err
+}
+
+// progressKeyWriter 每加密一段，第一个持有人写入一个密钥对
+type progressKeyWriter struct {
+	shamir.KeyWriter
+	reporter *progress.Reporter
+}
+
+func (w *progressKeyWriter) Write(key code.Key) error {
+	if err := w.KeyWriter.Write(key); err != nil {
+		return err
+	}
+	w.reporter.AddChunk()
+	return nil
+}
+
+// progressKeyReader 每解密一段，第一个持有人读取一个密钥对
+type progressKeyReader struct {
+	shamir.KeyReader
+	reporter *progress.Reporter
+}
+
+func (r *progressKeyReader) Read() (code.Key, bool, error) {
+	key, isHash, err := r.KeyReader.Read()
+	if err == nil {
+		r.reporter.AddChunk()
+	}
+	return key, isHash, err
+}
@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/seal"
+	"shamir/pkg/utils/secure"
+	"shamir/pkg/utils/source"
+)
+
+const holderSplit = "="
+
+// sealConf 份额加密相关参数，加密时将每个持有人的份额加密给其公钥，解密时使用私钥解开
+type sealConf struct {
+	recipientFlags    []string
+	pgpRecipientFlags []string
+	pgpKeyring        string
+	identityFiles     []string
+	pgpSecretKeys     []string
+	pgpPassphraseFile string
+	protectShares     bool
+	passphraseFile    string
+
+	recipients    map[int][]age.Recipient
+	pgpRecipients map[int][]*openpgp.Entity
+	passphrases   map[int][]byte
+	opener        *seal.Opener
+	// unsealed 已经解密的份额文件，按文件来源保存在锁定的内存中，尝试多个组合时不需要重复解密，
+	// 口令加密的份额每次解密都要重新运行 scrypt
+	unsealed map[string]*secure.LockedBuffer
+}
+
+func (s *sealConf) addEncryptFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&s.recipientFlags, "recipient", []string{}, "Encrypt the share of holder to public key, "+
+		"format is holder=pubkey, holder is the id of key file. Support age X25519 and ssh-ed25519 public key. "+
+		"Can be used repeatedly (must use with -o)")
+	cmd.Flags().StringVar(&s.pgpKeyring, "pgp-keyring", "", "The OpenPGP public keyring file used by --pgp-recipient")
+	cmd.Flags().StringArrayVar(&s.pgpRecipientFlags, "pgp-recipient", []string{}, "Encrypt the share of holder "+
+		"as ASCII-armored OpenPGP message, format is holder=name, name is the user id, email, key id or fingerprint "+
+		"in --pgp-keyring. Can be used repeatedly (must use with -o)")
+	cmd.Flags().BoolVar(&s.protectShares, "protect-shares", false, "Protect every holder's share with passphrase, "+
+		"will prompt passphrase for each holder (must use with -o)")
+	cmd.Flags().StringVar(&s.passphraseFile, "passphrase-file", "", "The file contains passphrases of holders "+
+		"used by --protect-shares, line i is the passphrase of holder i")
+}
+
+func (s *sealConf) addDecryptFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&s.identityFiles, "identity", []string{}, "The identity file to decrypt sealed shares, "+
+		"support age identity file and ssh ed25519 private key. Can be used repeatedly")
+	cmd.Flags().StringArrayVar(&s.pgpSecretKeys, "pgp-secret-key", []string{}, "The OpenPGP secret key file to "+
+		"decrypt OpenPGP sealed shares. Can be used repeatedly")
+	cmd.Flags().StringVar(&s.pgpPassphraseFile, "pgp-passphrase-file", "", "The file contains passphrase of "+
+		"the OpenPGP secret keys")
+	cmd.Flags().StringVar(&s.passphraseFile, "passphrase-file", "", "The file contains passphrases of "+
+		"protected shares, one passphrase per line. Will prompt passphrase when not set")
+}
+
+// parseRecipients 解析 holder=pubkey 格式的参数，holder 必须在 [0, n) 区间，且每个持有人都需要指定公钥
+func (s *sealConf) parseRecipients(n int) error {
+	if len(s.recipientFlags) == 0 && len(s.pgpRecipientFlags) == 0 {
+		return nil
+	}
+
+	s.recipients = make(map[int][]age.Recipient, n)
+	for _, flag := range s.recipientFlags {
+		holder, publicKey, err := parseHolder(flag, n)
+		if err != nil {
+			return err
+		}
+
+		recipient, err := seal.ParseRecipient(publicKey)
+		if err != nil {
+			return fmt.Errorf("invalid recipient of holder %d: %w", holder, err)
+		}
+		s.recipients[holder] = append(s.recipients[holder], recipient)
+	}
+
+	if err := s.parsePGPRecipients(n); err != nil {
+		return err
+	}
+	if s.protectShares || s.passphraseFile != "" {
+		return fmt.Errorf("--protect-shares can not use with --recipient or --pgp-recipient")
+	}
+
+	for i := 0; i < n; i++ {
+		_, ok := s.recipients[i]
+		_, pgpOk := s.pgpRecipients[i]
+		if !ok && !pgpOk {
+			return fmt.Errorf("holder %d has no recipient, every holder's share should be encrypted", i)
+		}
+		if ok && pgpOk {
+			return fmt.Errorf("holder %d can not use both --recipient and --pgp-recipient", i)
+		}
+	}
+
+	return nil
+}
+
+func (s *sealConf) parsePGPRecipients(n int) error {
+	s.pgpRecipients = make(map[int][]*openpgp.Entity, n)
+	if len(s.pgpRecipientFlags) == 0 {
+		return nil
+	}
+	if s.pgpKeyring == "" {
+		return fmt.Errorf("please use --pgp-keyring, when use --pgp-recipient")
+	}
+
+	keyring, err := seal.ReadKeyringFile(s.pgpKeyring)
+	if err != nil {
+		return err
+	}
+
+	for _, flag := range s.pgpRecipientFlags {
+		holder, name, err := parseHolder(flag, n)
+		if err != nil {
+			return err
+		}
+
+		entity, err := seal.FindEntity(keyring, name)
+		if err != nil {
+			return fmt.Errorf("invalid pgp recipient of holder %d: %w", holder, err)
+		}
+		s.pgpRecipients[holder] = append(s.pgpRecipients[holder], entity)
+	}
+
+	return nil
+}
+
+// parsePassphrases 获取每个持有人的口令，优先从口令文件中读取，否则在终端中依次提示输入
+func (s *sealConf) parsePassphrases(n int) error {
+	if !s.protectShares && s.passphraseFile == "" {
+		return nil
+	}
+
+	s.passphrases = make(map[int][]byte, n)
+	if s.passphraseFile != "" {
+		lines, err := readPassphraseFile(s.passphraseFile)
+		if err != nil {
+			return err
+		}
+		if len(lines) < n {
+			return fmt.Errorf("passphrase file has %d passphrases, less than key number %d", len(lines), n)
+		}
+		for i := 0; i < n; i++ {
+			s.passphrases[i] = lines[i]
+		}
+		return nil
+	}
+
+	for i := 0; i < n; i++ {
+		passphrase, err := ReadHiddenConfirm(fmt.Sprintf("Enter passphrase for holder %d: ", i),
+			fmt.Sprintf("Confirm passphrase for holder %d: ", i))
+		if err != nil {
+			return fmt.Errorf("get passphrase of holder %d failed: %w", i, err)
+		}
+		if len(passphrase) == 0 {
+			return fmt.Errorf("passphrase of holder %d can not be empty", i)
+		}
+		s.passphrases[i] = passphrase
+	}
+
+	return nil
+}
+
+func readPassphraseFile(file string) ([][]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase file failed: %w", err)
+	}
+
+	var result [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		result = append(result, line)
+	}
+	return result, nil
+}
+
+// parseHolder 解析 holder=value 格式的参数
+func parseHolder(flag string, n int) (int, string, error) {
+	holderStr, value, ok := strings.Cut(flag, holderSplit)
+	if !ok {
+		return 0, "", fmt.Errorf("invalid recipient %q, should be holder=pubkey", flag)
+	}
+
+	holder, err := strconv.Atoi(strings.TrimSpace(holderStr))
+	if err != nil || holder < 0 || holder >= n {
+		return 0, "", fmt.Errorf("invalid recipient holder %q, should be in [0, %d)", holderStr, n)
+	}
+
+	return holder, value, nil
+}
+
+func (s *sealConf) parseIdentities() error {
+	var identities []age.Identity
+	for _, file := range s.identityFiles {
+		fileIdentities, err := seal.ParseIdentityFile(file)
+		if err != nil {
+			return err
+		}
+		identities = append(identities, fileIdentities...)
+	}
+
+	var keyring openpgp.EntityList
+	for _, file := range s.pgpSecretKeys {
+		fileKeyring, err := seal.ReadKeyringFile(file)
+		if err != nil {
+			return err
+		}
+		keyring = append(keyring, fileKeyring...)
+	}
+
+	if s.pgpPassphraseFile != "" {
+		passphrase, err := os.ReadFile(filepath.Clean(s.pgpPassphraseFile))
+		if err != nil {
+			return fmt.Errorf("read pgp passphrase file failed: %w", err)
+		}
+		if err = seal.DecryptKeyring(keyring, bytes.TrimRight(passphrase, "\r\n")); err != nil {
+			return err
+		}
+	}
+
+	opts := []seal.OpenerOption{seal.WithAgeIdentities(identities...), seal.WithPGPKeyring(keyring)}
+	if s.passphraseFile != "" {
+		passphrases, err := readPassphraseFile(s.passphraseFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, seal.WithPassphrases(passphrases...))
+	} else {
+		opts = append(opts, seal.WithPassphrasePrompt(newPassphrasePrompt()))
+	}
+
+	s.opener = seal.NewOpener(opts...)
+	return nil
+}
+
+// newPassphrasePrompt 在终端提示输入份额的口令，同一个持有人的份额只提示一次
+func newPassphrasePrompt() seal.PassphraseFunc {
+	cache := make(map[string][]byte)
+	return func(name string) ([]byte, error) {
+		if passphrase, ok := cache[name]; ok {
+			return passphrase, nil
+		}
+
+		passphrase, err := ReadHidden(fmt.Sprintf("Enter passphrase for %s: ", name))
+		if err != nil {
+			return nil, err
+		}
+		cache[name] = passphrase
+		return passphrase, nil
+	}
+}
+
+func (s *sealConf) isSealed() bool {
+	return len(s.recipients) != 0 || len(s.pgpRecipients) != 0 || len(s.passphrases) != 0
+}
+
+// openFlags 持有人的份额被加密时，解密需要使用的参数
+func (s *sealConf) openFlags(holder int) string {
+	if _, ok := s.recipients[holder]; ok {
+		return "--identity with your age identity or ssh private key"
+	}
+	if _, ok := s.pgpRecipients[holder]; ok {
+		return "--pgp-secret-key with your OpenPGP secret key"
+	}
+	if _, ok := s.passphrases[holder]; ok {
+		return "your passphrase"
+	}
+	return ""
+}
+
+// sealWriter 若指定了持有人的公钥，写入持有人份额文件的数据将被加密
+func (s *sealConf) sealWriter(holder int, file io.WriteCloser) (io.WriteCloser, error) {
+	if recipients, ok := s.recipients[holder]; ok {
+		return seal.NewAgeWriter(file, recipients...)
+	}
+	if entities, ok := s.pgpRecipients[holder]; ok {
+		return seal.NewPGPWriter(file, entities...)
+	}
+	if passphrase, ok := s.passphrases[holder]; ok {
+		return seal.NewPassphraseWriter(file, passphrase)
+	}
+
+	return file, nil
+}
+
+// openReader 若份额被加密，使用私钥或口令解密，name 为份额持有人的名称
+func (s *sealConf) openReader(name string, file io.Reader) (io.Reader, error) {
+	if s.opener == nil {
+		s.opener = seal.NewOpener()
+	}
+	return s.opener.OpenNamed(name, file)
+}
+
+// openFile 打开份额文件，加密的份额只在第一次打开时解密，之后从锁定的内存中读取，name 为份额持有人的名称
+func (s *sealConf) openFile(name string, file *source.File) (io.ReadCloser, error) {
+	if unsealed, ok := s.unsealed[file.Source()]; ok {
+		return io.NopCloser(bytes.NewReader(unsealed.Bytes())), nil
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	reader, sealed, err := seal.PeekSealed(f)
+	if err != nil {
+		closeClosers([]io.Closer{f})
+		return nil, err
+	}
+	if !sealed {
+		return struct {
+			io.Reader
+			io.Closer
+		}{reader, f}, nil
+	}
+	defer closeClosers([]io.Closer{f})
+
+	opened, err := s.openReader(name, reader)
+	if err != nil {
+		return nil, err
+	}
+	buffer := &secure.Buffer{}
+	defer buffer.Reset()
+	if _, err = io.Copy(buffer, opened); err != nil {
+		return nil, err
+	}
+	unsealed, err := secure.LockBytes(buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if s.unsealed == nil {
+		s.unsealed = make(map[string]*secure.LockedBuffer)
+	}
+	s.unsealed[file.Source()] = unsealed
+	return io.NopCloser(bytes.NewReader(unsealed.Bytes())), nil
+}
+
+// destroy 清零解密后保存的份额
+func (s *sealConf) destroy() {
+	for _, unsealed := range s.unsealed {
+		unsealed.Destroy()
+	}
+	s.unsealed = nil
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/compute"
+	"shamir/pkg/utils/manifest"
+	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/secure"
+	"shamir/pkg/utils/tree"
+)
+
+// secretConf 秘密的输入方式，命令行参数中的秘密会留在 shell 历史和 /proc/<pid>/cmdline 中，
+// 所以在终端中默认使用关闭回显的提示输入，也可以从文件描述符或环境变量读取
+type secretConf struct {
+	fd        int
+	env       string
+	allowArgv bool
+}
+
+func (s *secretConf) addFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&s.fd, "secret-fd", -1, "Read secret from the file descriptor, "+
+		"e.g. --secret-fd 3 3<secret.txt (must use with -o)")
+	cmd.Flags().StringVar(&s.env, "secret-env", "", "Read secret from the environment variable")
+	cmd.Flags().BoolVar(&s.allowArgv, "allow-argv", false, "Allow the secret as command line argument in a terminal, "+
+		"it will be left in shell history and process list")
+}
+
+// checkSecretInput 校验秘密的输入方式，-i、--secret-fd、--secret-env 和命令行参数只能使用一种。
+// 流式读取的输入(文件、文件描述符、标准输入)可能很大，needOutput 为true时要求使用 -o
+func (enc *EncryptCmdConf) checkSecretInput(cmd *cobra.Command, args []string, needOutput bool) error {
+	if err := MaximumNArgs(1)(cmd, args); err != nil {
+		return err
+	}
+
+	inputs := 0
+	for _, used := range []bool{len(enc.inputs) != 0, enc.secret.fd >= 0, enc.secret.env != "", len(args) != 0} {
+		if used {
+			inputs++
+		}
+	}
+	if inputs > 1 {
+		return fmt.Errorf("only one of -i, --secret-fd, --secret-env and argument can be used to input secret")
+	}
+
+	switch {
+	case len(enc.inputs) != 0:
+		for _, input := range enc.inputs {
+			if !path.IsExist(input) {
+				return fmt.Errorf("invalid input file path %q, not exist", input)
+			}
+		}
+	case enc.secret.fd >= 0:
+	case enc.secret.env != "":
+		value, ok := os.LookupEnv(enc.secret.env)
+		if !ok {
+			return fmt.Errorf("environment variable %q not set", enc.secret.env)
+		}
+		return checkSecretLen(len(value))
+	case len(args) != 0:
+		if IsTerminalInput() && !enc.secret.allowArgv {
+			return fmt.Errorf("secret in command line argument will be left in shell history and process list, " +
+				"run without argument to input it with echo disabled, or use --secret-fd, --secret-env, " +
+				"or use --allow-argv if you know the risk")
+		}
+		return checkSecretLen(len(args[0]))
+	case IsTerminalInput():
+		// 终端中关闭回显提示输入
+		return nil
+	}
+
+	if needOutput && enc.outputPath == "" {
+		return fmt.Errorf("please use -o, when input secret from file, file descriptor or standard input")
+	}
+	return nil
+}
+
+// openInputs 单个文件直接读取，多个输入或目录打包为 tar 流，并在清单中记录
+func (enc *EncryptCmdConf) openInputs() (io.ReadCloser, error) {
+	if len(enc.inputs) == 1 {
+		name := filepath.Clean(enc.inputs[0])
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			input, err := os.OpenFile(name, os.O_RDONLY, 0)
+			if err != nil {
+				return nil, fmt.Errorf("open input secret file failed: %w", err)
+			}
+			return input, nil
+		}
+	}
+
+	input, err := tree.NewReader(enc.inputs)
+	if err != nil {
+		return nil, fmt.Errorf("open input files failed: %w", err)
+	}
+	enc.manifest.content = manifest.ContentTar
+	return input, nil
+}
+
+func checkSecretLen(length int) error {
+	if length > stringLimit {
+		return fmt.Errorf("invalid string, secret length should be less than %dMB, encrypt big secret please use -i",
+			stringLimit/compute.UnitM)
+	}
+	return nil
+}
+
+// getInput 按 -i、--secret-fd、--secret-env、命令行参数、标准输入的顺序获取秘密，
+// 都没有且在终端中时提示输入两次秘密
+func (enc *EncryptCmdConf) getInput(cmd *cobra.Command, args []string) (io.ReadCloser, error) {
+	switch {
+	case len(enc.inputs) != 0:
+		return enc.openInputs()
+	case enc.secret.fd >= 0:
+		input := os.NewFile(uintptr(enc.secret.fd), fmt.Sprintf("fd%d", enc.secret.fd))
+		if input == nil {
+			return nil, fmt.Errorf("invalid secret file descriptor %d", enc.secret.fd)
+		}
+		return input, nil
+	case enc.secret.env != "":
+		return io.NopCloser(bytes.NewBufferString(os.Getenv(enc.secret.env))), nil
+	case len(args) != 0:
+		return io.NopCloser(bytes.NewBufferString(args[0])), nil
+	case !IsTerminalInput():
+		return io.NopCloser(cmd.InOrStdin()), nil
+	}
+
+	secret, err := ReadHiddenConfirm("secret: ", "confirm secret: ")
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret can not be empty")
+	}
+	if err = checkSecretLen(len(secret)); err != nil {
+		secure.Wipe(secret)
+		return nil, err
+	}
+	// 加密后关闭输入时清零
+	return secure.NewWipeReader(secret), nil
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/rpc"
+	"shamir/pkg/server"
+	"shamir/pkg/utils/graceful"
+	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/recovery"
+	taskgroup "shamir/pkg/utils/task-group"
+)
+
+const (
+	defaultListen      = "127.0.0.1:8420"
+	defaultSessionTTL  = 30 * time.Minute
+	defaultMaxSessions = 100
+	janitorInterval    = 10 * time.Second
+)
+
+type ServeCmdConf struct {
+	listen          string
+	tlsCert, tlsKey string
+	sessionTTL      time.Duration
+	maxSessions     int
+	createTokenFile string
+	grpc            bool
+}
+
+func NewServeCommand() *cobra.Command {
+	cmd := &cobra.Command{}
+	conf := &ServeCmdConf{}
+	cmd.Use = "serve"
+	cmd.Short = "Run a server to collect shares for recovery ceremonies"
+	cmd.Long =
+		`Run a server to collect shares for recovery ceremonies
+
+The organizer opens a recovery session with the necessary key and threshold, and gets a session id, a token and a submit token.
+The organizer hands the submit token to custodians, with it custodians in different places submit their shares
+to the session over the JSON API, everyone can see the progress.
+When t valid shares have arrived, the organizer uses the token to restore the secret, then the session is wiped.
+Sessions not released in time will be wiped too.
+
+  POST   /v1/sessions              {"threshold": 2, "necessary_key": "..."}
+  GET    /v1/sessions/{id}
+  POST   /v1/sessions/{id}/shares  {"key_x": "...", "key_y": "..."} Authorization: Bearer <submit_token>
+  POST   /v1/sessions/{id}/release Authorization: Bearer <token>
+  DELETE /v1/sessions/{id}         Authorization: Bearer <token>
+
+With --grpc, serve the Shamir gRPC service defined in pkg/rpc/shamir.proto instead.
+Split and Combine stream the secret chunk by chunk, Verify and Inspect check keys without returning the secret.
+The service keeps nothing, every call is independent.`
+	cmd.Example = `shamir serve
+shamir serve --listen 0.0.0.0:8420 --tls-cert server.crt --tls-key server.key --create-token-file token.txt
+curl -s -X POST localhost:8420/v1/sessions -d '{"threshold": 2, "necessary_key": "..."}'
+curl -s -X POST localhost:8420/v1/sessions/<id>/shares -H "Authorization: Bearer <submit_token>" -d '{"key_x": "...", "key_y": "..."}'
+curl -s -X POST localhost:8420/v1/sessions/<id>/release -H "Authorization: Bearer <token>"
+shamir serve --grpc --listen 127.0.0.1:8421
+`
+	cmd.Args = NoArgs
+	cmd.Flags().StringVar(&conf.listen, "listen", defaultListen, "The address to listen on")
+	cmd.Flags().StringVar(&conf.tlsCert, "tls-cert", "", "The TLS certificate file, serve HTTPS when set")
+	cmd.Flags().StringVar(&conf.tlsKey, "tls-key", "", "The TLS private key file")
+	cmd.Flags().DurationVar(&conf.sessionTTL, "session-ttl", defaultSessionTTL, "Sessions not released in time will be wiped")
+	cmd.Flags().IntVar(&conf.maxSessions, "max-sessions", defaultMaxSessions, "The max number of open sessions")
+	cmd.Flags().StringVar(&conf.createTokenFile, "create-token-file", "", "Only requests with the token in file "+
+		"as bearer token can open sessions")
+	cmd.Flags().BoolVar(&conf.grpc, "grpc", false, "Serve the Split/Combine/Verify/Inspect gRPC service instead of recovery sessions")
+
+	cmd.RunE = conf.RunE
+	return cmd
+}
+
+func (s *ServeCmdConf) RunE(cmd *cobra.Command, _ []string) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+
+	// 先监听端口，端口被占用等错误可以直接返回
+	listener, err := net.Listen("tcp", s.listen)
+	if err != nil {
+		return fmt.Errorf("listen on %s failed: %w", s.listen, err)
+	}
+	if s.tlsCert == "" && !isLoopback(listener.Addr()) {
+		warnf(cmd, "serving without TLS on %s, shares will be sent in plain text", listener.Addr())
+	}
+
+	tg := taskgroup.NewTaskGroup()
+	stop := tg.StopAll
+	if s.grpc {
+		if err = s.addGRPCServer(tg, listener); err != nil {
+			_ = listener.Close()
+			return err
+		}
+	} else {
+		manager, err := s.addHTTPServer(tg, listener)
+		if err != nil {
+			_ = listener.Close()
+			return err
+		}
+		stop = func() {
+			tg.StopAll()
+			manager.Close()
+		}
+	}
+
+	g := graceful.NewGraceFul(graceful.WithSignalHandlers(map[os.Signal]graceful.Handler{
+		syscall.SIGINT:  stop,
+		syscall.SIGTERM: stop,
+	}))
+
+	tg.StartAll()
+	if s.grpc {
+		infof(cmd, "serving gRPC service %s on %s", rpc.Shamir_ServiceDesc.ServiceName, listener.Addr())
+	} else {
+		infof(cmd, "serving recovery sessions on %s", listener.Addr())
+	}
+	// 任务启动失败或异常退出时停止所有任务，以非零退出码退出
+	select {
+	case <-g.Done():
+	case err = <-tg.Errors():
+		stop()
+		return err
+	}
+	log.Info("server stopped")
+	return nil
+}
+
+func (s *ServeCmdConf) addHTTPServer(tg *taskgroup.TaskGroup, listener net.Listener) (*recovery.Manager, error) {
+	var opts []server.HTTPOption
+	if s.tlsCert != "" {
+		opts = append(opts, server.WithTLS(s.tlsCert, s.tlsKey))
+	}
+	if s.createTokenFile != "" {
+		token, err := os.ReadFile(s.createTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read create token file failed: %w", err)
+		}
+		opts = append(opts, server.WithCreateToken(strings.TrimSpace(string(token))))
+	}
+
+	manager := recovery.NewManager(recovery.WithTTL(s.sessionTTL), recovery.WithMaxSessions(s.maxSessions))
+	tg.Add(server.NewHTTPServer(listener, manager, opts...))
+	tg.Add(server.NewJanitor(manager, janitorInterval))
+	return manager, nil
+}
+
+func (s *ServeCmdConf) addGRPCServer(tg *taskgroup.TaskGroup, listener net.Listener) error {
+	var opts []server.GRPCOption
+	if s.tlsCert != "" {
+		opts = append(opts, server.WithGRPCTLS(s.tlsCert, s.tlsKey))
+	}
+
+	grpcServer, err := server.NewGRPCServer(listener, opts...)
+	if err != nil {
+		return err
+	}
+	tg.Add(grpcServer)
+	return nil
+}
+
+func (s *ServeCmdConf) check() error {
+	if (s.tlsCert == "") != (s.tlsKey == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be used together")
+	}
+	// 在开始监听前加载证书，证书有误时直接返回
+	if s.tlsCert != "" {
+		if _, err := tls.LoadX509KeyPair(s.tlsCert, s.tlsKey); err != nil {
+			return fmt.Errorf("load tls certificate failed: %w", err)
+		}
+	}
+	if s.grpc && s.createTokenFile != "" {
+		return fmt.Errorf("--create-token-file can not be used with --grpc")
+	}
+	if s.sessionTTL <= 0 {
+		return fmt.Errorf("invalid session ttl %s", s.sessionTTL)
+	}
+	if s.maxSessions <= 0 {
+		return fmt.Errorf("invalid max sessions %d", s.maxSessions)
+	}
+	return nil
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
@@ -0,0 +1,472 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/shamir"
+	"shamir/pkg/utils/source"
+)
+
+// maxCombinations 逐个尝试的密钥对组合数上限，每个组合都要完整解密一遍
+const maxCombinations = 1024
+
+// shareFiles 输入中一个持有人的密钥对文件
+type shareFiles struct {
+	holder string
+	x      *source.File
+	y      *source.File
+	// signature 同一位置中该持有人的签名文件，可能为nil
+	signature *source.File
+	// label 清单中持有人的标签
+	label string
+	// fingerprint 份额在清单中的指纹，没有清单时为空
+	fingerprint string
+	// first 第一段密钥对，检查密钥文件时读取，用于在尝试组合前排除不一致的密钥对
+	first code.Key
+}
+
+// String 返回持有人及密钥对的来源，用于提示用户
+func (s *shareFiles) String() string {
+	if s.label != "" {
+		return fmt.Sprintf("holder %s (%s) from %s", s.holder, s.label, s.x.Dir)
+	}
+	return fmt.Sprintf("holder %s from %s", s.holder, s.x.Dir)
+}
+
+// shareCheck 输入中一个密钥对的检查结果，err 不为nil时该密钥对不能参与解密
+type shareCheck struct {
+	share *shareFiles
+	err   error
+}
+
+// loadShareFiles 从所有输入中收集密钥对，有问题或x密钥重复的密钥对会被跳过并给出警告，
+// 返回的密钥对按输入顺序和持有人排序
+func (d *DecryptCmdConf) loadShareFiles(cmd *cobra.Command) ([]*shareFiles, error) {
+	checks, err := d.checkShares(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var shares []*shareFiles
+	for _, check := range checks {
+		if check.err != nil {
+			warnf(cmd, "skip keys of %s: %v", check.share, check.err)
+			continue
+		}
+		shares = append(shares, check.share)
+	}
+
+	if len(shares) < d.t {
+		return nil, fmt.Errorf("only %d usable key pairs found, can not less than threshold %d", len(shares), d.t)
+	}
+	return shares, nil
+}
+
+// checkShares 从所有输入中收集密钥对，逐个检查能否读取以及与清单、必须密钥是否一致，
+// 返回每个密钥对的检查结果，x密钥与前面的密钥对重复时也视为有问题
+func (d *DecryptCmdConf) checkShares(cmd *cobra.Command) ([]shareCheck, error) {
+	files, err := source.Collect(d.inputPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有指定门限值时使用清单中的门限值
+	if err = d.manifest.load(cmd, files); err != nil {
+		return nil, err
+	}
+	if m := d.manifest.loaded; m != nil {
+		if d.t == 0 {
+			d.t = m.Threshold
+		} else if d.t != m.Threshold {
+			warnf(cmd, "threshold %d is different from %d in manifest", d.t, m.Threshold)
+		}
+	}
+
+	d.necessaryFile, err = findNecessary(files)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.manifest.checkNecessary(d.necessaryFile); err != nil {
+		return nil, err
+	}
+	chunks, err := countKeyChunks(d.necessaryFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid necessary key file %s: %w", d.necessaryFile.Source(), err)
+	}
+
+	var checks []shareCheck
+	// 第一段x密钥 -> 密钥对，重复的x密钥无法参与解密
+	seen := make(map[string]*shareFiles)
+	for _, share := range pairShareFiles(cmd, files) {
+		if e := d.manifest.checkShare(share); e != nil {
+			checks = append(checks, shareCheck{share: share, err: e})
+			continue
+		}
+		first, e := d.checkShareFiles(share, chunks)
+		if e != nil {
+			checks = append(checks, shareCheck{share: share, err: e})
+			continue
+		}
+		firstX := code.DecodeKey(first.X)
+		if other, ok := seen[firstX]; ok {
+			checks = append(checks, shareCheck{share: share, err: fmt.Errorf("same x key as %s", other)})
+			continue
+		}
+
+		share.first = first
+		seen[firstX] = share
+		checks = append(checks, shareCheck{share: share})
+	}
+	return checks, nil
+}
+
+// findNecessary 找到输入中的必须密钥，多份必须密钥的内容必须一致
+func findNecessary(files []*source.File) (*source.File, error) {
+	necessary, err := findUnique(files, path.NecessaryFileName)
+	if err != nil {
+		return nil, err
+	}
+	if necessary == nil {
+		return nil, fmt.Errorf("necessary key not exist")
+	}
+	return necessary, nil
+}
+
+// findUnique 找到输入中名为 name 的文件，如按持有人分开的必须密钥和清单，多份文件的内容必须一致，不存在时返回nil
+func findUnique(files []*source.File, name string) (*source.File, error) {
+	var found *source.File
+	var sum []byte
+	for _, file := range files {
+		if file.Name != name {
+			continue
+		}
+
+		fileSum, err := hashSource(file)
+		if err != nil {
+			return nil, err
+		}
+		if found == nil {
+			found, sum = file, fileSum
+			continue
+		}
+		if !bytes.Equal(sum, fileSum) {
+			return nil, fmt.Errorf("%s is different from %s, keys must come from the same encryption",
+				file.Source(), found.Source())
+		}
+	}
+	return found, nil
+}
+
+// pairShareFiles 将同一位置中后缀相同的x、y密钥文件组成密钥对，缺少另一半的文件会给出警告
+func pairShareFiles(cmd *cobra.Command, files []*source.File) []*shareFiles {
+	type location struct {
+		dir, holder string
+	}
+	xFiles := make(map[location]*source.File)
+	yFiles := make(map[location]*source.File)
+	signatures := make(map[location]*source.File)
+	for _, file := range files {
+		switch {
+		case strings.HasPrefix(file.Name, path.XKeyFilePrefix):
+			xFiles[location{file.Dir, strings.TrimPrefix(file.Name, path.XKeyFilePrefix)}] = file
+		case strings.HasPrefix(file.Name, path.YKeyFilePrefix):
+			yFiles[location{file.Dir, strings.TrimPrefix(file.Name, path.YKeyFilePrefix)}] = file
+		case strings.HasPrefix(file.Name, path.SignatureFilePrefix):
+			signatures[location{file.Dir, strings.TrimPrefix(file.Name, path.SignatureFilePrefix)}] = file
+		}
+	}
+
+	var shares []*shareFiles
+	for _, file := range files {
+		switch {
+		case strings.HasPrefix(file.Name, path.XKeyFilePrefix):
+			loc := location{file.Dir, strings.TrimPrefix(file.Name, path.XKeyFilePrefix)}
+			y, ok := yFiles[loc]
+			if !ok {
+				warnf(cmd, "skip %s: y key file not found", file.Source())
+				continue
+			}
+			shares = append(shares, &shareFiles{holder: loc.holder, x: file, y: y, signature: signatures[loc]})
+		case strings.HasPrefix(file.Name, path.YKeyFilePrefix):
+			if _, ok := xFiles[location{file.Dir, strings.TrimPrefix(file.Name, path.YKeyFilePrefix)}]; !ok {
+				warnf(cmd, "skip %s: x key file not found", file.Source())
+			}
+		}
+	}
+
+	// 按位置出现的顺序和持有人序号排序，保证每次选取密钥对的顺序一致
+	dirs := make(map[string]int)
+	for _, file := range files {
+		if _, ok := dirs[file.Dir]; !ok {
+			dirs[file.Dir] = len(dirs)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].x.Dir != shares[j].x.Dir {
+			return dirs[shares[i].x.Dir] < dirs[shares[j].x.Dir]
+		}
+		return path.LessHolder(shares[i].holder, shares[j].holder)
+	})
+	return shares
+}
+
+// checkShareFiles 校验签名并完整读取一遍密钥对，要求x、y密钥段数与必须密钥一致，返回第一段密钥对
+func (d *DecryptCmdConf) checkShareFiles(share *shareFiles, chunks int) (code.Key, error) {
+	var first code.Key
+	if err := d.sign.verifyShare(share, d.necessaryFile, d.manifest.sum); err != nil {
+		return first, err
+	}
+
+	key, opened, err := d.openShareFiles(share)
+	if err != nil {
+		return first, err
+	}
+	defer closeClosers(opened)
+
+	encoder := key.ToXYKeyEncoder()
+	for count := 1; ; count++ {
+		k, isHash, e := encoder.Read()
+		if e != nil {
+			return first, e
+		}
+		if count == 1 {
+			first = k
+		}
+
+		if !isHash {
+			continue
+		}
+		if count != chunks {
+			return first, fmt.Errorf("keys not match necessary key, has %d parts but need %d", count, chunks)
+		}
+		return first, nil
+	}
+}
+
+// openShareFiles 打开持有人的x、y密钥文件，若密钥文件被加密则解密读取
+func (d *DecryptCmdConf) openShareFiles(share *shareFiles) (*keyReadWriter, []io.Closer, error) {
+	holder := "holder " + share.holder
+
+	xKeyReader, err := d.seal.openFile(holder, share.x)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open x key file %s failed: %w", share.x.Source(), err)
+	}
+	yKeyReader, err := d.seal.openFile(holder, share.y)
+	if err != nil {
+		closeClosers([]io.Closer{xKeyReader})
+		return nil, nil, fmt.Errorf("open y key file %s failed: %w", share.y.Source(), err)
+	}
+
+	return NewKeyReadWriter(NewReadOnly(xKeyReader), NewReadOnly(yKeyReader)), []io.Closer{xKeyReader, yKeyReader}, nil
+}
+
+// openShares 打开一组密钥对和必须密钥，返回的 closer 需要由调用者关闭
+func (d *DecryptCmdConf) openShares(shares []*shareFiles) ([]*keyReadWriter, io.Reader, []io.Closer, error) {
+	var opened []io.Closer
+	keys := make([]*keyReadWriter, 0, len(shares))
+	for _, share := range shares {
+		key, closers, err := d.openShareFiles(share)
+		if err != nil {
+			closeClosers(opened)
+			return nil, nil, nil, err
+		}
+		opened = append(opened, closers...)
+		keys = append(keys, key)
+	}
+
+	necessary, err := d.necessaryFile.Open()
+	if err != nil {
+		closeClosers(opened)
+		return nil, nil, nil, fmt.Errorf("open necessary key file %s failed: %w", d.necessaryFile.Source(), err)
+	}
+	opened = append(opened, necessary)
+
+	return keys, necessary, opened, nil
+}
+
+// findShares 依次尝试 t 个密钥对的组合，用秘密中的hash值校验，返回第一个能还原出秘密的组合。
+// 只有 t 个可用密钥对时直接返回，由正式解密时校验。尝试前先用第一段密钥排除不一致的密钥对，最多尝试 maxCombinations 个组合，
+// 输出可以重写时直接解密到输出，失败时清空输出再尝试下一个组合
+func (d *DecryptCmdConf) findShares(cmd *cobra.Command, shares []*shareFiles) ([]*shareFiles, error) {
+	if len(shares) == d.t {
+		return shares, nil
+	}
+	shares, err := d.dropInconsistent(cmd, shares)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := 0
+	index := make([]int, d.t)
+	for i := range index {
+		index[i] = i
+	}
+	for {
+		chosen := make([]*shareFiles, 0, d.t)
+		for _, i := range index {
+			chosen = append(chosen, shares[i])
+		}
+
+		err := d.tryShares(cmd, chosen)
+		if err == nil {
+			if failed > 0 {
+				warnf(cmd, "%d combinations of keys failed, restored the secret by holders %s",
+					failed, holdersOf(chosen))
+			}
+			return chosen, nil
+		}
+		// 被中断时不再尝试其他组合
+		if d.interrupt.Err() != nil {
+			return nil, d.interrupt.Cause()
+		}
+
+		failed++
+		log.Debugf("keys of holders %s can not restore the secret: %v", holdersOf(chosen), err)
+		if err = d.rewindOutput(); err != nil {
+			return nil, err
+		}
+		if failed == maxCombinations {
+			return nil, fmt.Errorf("%d combinations of %d usable key pairs can not restore the secret, "+
+				"please decrypt with less key pairs at once", failed, len(shares))
+		}
+		if !shamir.NextCombination(index, len(shares)) {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("no %d of the %d usable key pairs can restore the secret", d.t, len(shares))
+}
+
+// dropInconsistent 密钥对多于门限值时，用第一段密钥排除与其他密钥对不在同一个多项式上的密钥对，
+// 只多出一个密钥对时无法判断，由 findShares 逐个组合校验
+func (d *DecryptCmdConf) dropInconsistent(cmd *cobra.Command, shares []*shareFiles) ([]*shareFiles, error) {
+	prime, err := readFirstKey(d.necessaryFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid necessary key file %s: %w", d.necessaryFile.Source(), err)
+	}
+	keys := make([]code.Key, 0, len(shares))
+	for _, share := range shares {
+		keys = append(keys, share.first)
+	}
+	consistent, err := shamir.ConsistentKeys(keys, d.t, prime, maxCombinations)
+	if err != nil {
+		return nil, err
+	}
+	if len(consistent) == len(shares) {
+		return shares, nil
+	}
+
+	result := make([]*shareFiles, 0, len(consistent))
+	for i, share := range shares {
+		if len(result) < len(consistent) && consistent[len(result)] == i {
+			result = append(result, share)
+			continue
+		}
+		warnf(cmd, "skip keys of %s: not consistent with keys of other holders", share)
+	}
+	return result, nil
+}
+
+// tryShares 用一组密钥对完整解密一遍。输出可以重写时解密到输出并显示进度，成功后不需要再解密；
+// 否则丢弃结果只做hash校验，避免把错误的秘密写到标准输出或目录中
+func (d *DecryptCmdConf) tryShares(cmd *cobra.Command, shares []*shareFiles) error {
+	keys, necessary, opened, err := d.openShares(shares)
+	if err != nil {
+		return err
+	}
+	defer closeClosers(opened)
+
+	if d.rewind == nil {
+		return d.combine(keys, necessary, io.Discard, nil)
+	}
+	reporter, err := d.newReporter(cmd)
+	if err != nil {
+		return err
+	}
+	if reporter != nil {
+		reporter.Start()
+	}
+	err = d.combine(keys, necessary, d.manifest.secretWriter(d.rewind), reporter)
+	if reporter != nil {
+		reporter.Finish(err == nil)
+	}
+	d.restored = err == nil
+	return err
+}
+
+// rewindOutput 组合还原失败后清空输出和已经计算的秘密的hash值
+func (d *DecryptCmdConf) rewindOutput() error {
+	if d.rewind == nil {
+		return nil
+	}
+	d.manifest.reset()
+	if err := d.rewind.Rewind(); err != nil {
+		return fmt.Errorf("rewind output failed: %w", err)
+	}
+	return nil
+}
+
+// countKeyChunks 返回密钥文件中密钥的段数，最后一段是hash值的密钥
+func countKeyChunks(file *source.File) (int, error) {
+	f, err := file.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer closeClosers([]io.Closer{f})
+
+	encoder := code.NewKeyEncoder(f)
+	for count := 1; ; count++ {
+		_, isHash, e := encoder.Read()
+		if e != nil {
+			return 0, e
+		}
+		if isHash {
+			return count, nil
+		}
+	}
+}
+
+// readFirstKey 返回密钥文件中的第一段密钥
+func readFirstKey(file *source.File) (*big.Int, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeClosers([]io.Closer{f})
+
+	key, _, err := code.NewKeyEncoder(f).Read()
+	return key, err
+}
+
+func holdersOf(shares []*shareFiles) string {
+	holders := make([]string, 0, len(shares))
+	for _, share := range shares {
+		holders = append(holders, share.holder)
+	}
+	return strings.Join(holders, ",")
+}
+
+// readSource 读取输入文件的全部内容
+func readSource(file *source.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeClosers([]io.Closer{reader})
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read file %s failed: %w", file.Source(), err)
+	}
+	return data, nil
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/secure"
+	"shamir/pkg/utils/sign"
+	"shamir/pkg/utils/source"
+)
+
+// signConf 发牌人签名相关参数，加密时对每个份额签名，解密时只接受受信任发牌人签名的份额
+type signConf struct {
+	signKey  string
+	trustKey string
+
+	privateKey ed25519.PrivateKey
+	trusted    ed25519.PublicKey
+}
+
+func (s *signConf) addEncryptFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.signKey, "sign", "", "Sign every share with the dealer's ed25519 private key, "+
+		"the signature covers the share file, the necessary key and manifest.json (must use with -o)")
+}
+
+func (s *signConf) addDecryptFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.trustKey, "trust", "", "Only accept shares signed by the dealer's ed25519 public key")
+}
+
+func (s *signConf) loadPrivateKey() error {
+	if s.signKey == "" {
+		return nil
+	}
+
+	key, err := sign.LoadPrivateKey(s.signKey)
+	if err != nil {
+		return err
+	}
+	s.privateKey = key
+	return nil
+}
+
+func (s *signConf) loadTrusted() error {
+	if s.trustKey == "" {
+		return nil
+	}
+
+	key, err := sign.LoadPublicKey(s.trustKey)
+	if err != nil {
+		return err
+	}
+	s.trusted = key
+	return nil
+}
+
+// signShares 对输出目录下的 n 个份额签名，签名写入份额所在目录 dir 的 shamir_signature_<id> 文件，
+// 份额、清单和签名文件在事务 tx 中，提交前从临时文件读取份额和清单，需在写入清单后调用
+func (s *signConf) signShares(tx *secure.Transaction, dir func(outputPath string, holder int) string,
+	outputPath string, n int) error {
+	if s.privateKey == nil {
+		return nil
+	}
+
+	necessary, err := sign.HashFile(tx.Path(filepath.Join(dir(outputPath, 0), path.NecessaryFileName)))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		holder := fmt.Sprintf("%d", i)
+		envelope, e := loadEnvelope(tx, dir(outputPath, i), holder, necessary)
+		if e != nil {
+			return e
+		}
+
+		data, e := sign.Sign(s.privateKey, envelope).MarshalText()
+		if e != nil {
+			return e
+		}
+		signatureFileName := filepath.Join(dir(outputPath, i), path.SignatureFilePrefix+holder)
+		e = tx.WriteFile(signatureFileName, data)
+		if e != nil {
+			return fmt.Errorf("write signature file %s failed: %w", signatureFileName, e)
+		}
+	}
+
+	return nil
+}
+
+// verifyShare 校验密钥对是否由受信任的发牌人签名，签名文件需与密钥对在同一位置，
+// manifest 是输入中清单的 SHA-256 值，没有清单时为nil
+func (s *signConf) verifyShare(share *shareFiles, necessaryFile *source.File, manifest []byte) error {
+	if s.trusted == nil {
+		return nil
+	}
+
+	necessary, err := hashSource(necessaryFile)
+	if err != nil {
+		return err
+	}
+	x, err := hashSource(share.x)
+	if err != nil {
+		return err
+	}
+	y, err := hashSource(share.y)
+	if err != nil {
+		return err
+	}
+	envelope := &sign.Envelope{
+		Holder:    share.holder,
+		X:         x,
+		Y:         y,
+		Necessary: necessary,
+		Manifest:  manifest,
+	}
+
+	var signature *sign.Signature
+	if share.signature != nil {
+		data, e := readSource(share.signature)
+		if e != nil {
+			return e
+		}
+		signature, e = parseSignature(data, share.signature.Source())
+		if e != nil {
+			return e
+		}
+	}
+	return sign.Verify(s.trusted, envelope, signature)
+}
+
+func loadEnvelope(tx *secure.Transaction, dir, holder string, necessary []byte) (*sign.Envelope, error) {
+	x, err := sign.HashFile(tx.Path(filepath.Join(dir, path.XKeyFilePrefix+holder)))
+	if err != nil {
+		return nil, err
+	}
+	y, err := sign.HashFile(tx.Path(filepath.Join(dir, path.YKeyFilePrefix+holder)))
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := sign.HashFile(tx.Path(filepath.Join(dir, path.ManifestFileName)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &sign.Envelope{
+		Holder:    holder,
+		X:         x,
+		Y:         y,
+		Necessary: necessary,
+		Manifest:  manifest,
+	}, nil
+}
+
+func parseSignature(data []byte, name string) (*sign.Signature, error) {
+	signature := &sign.Signature{}
+	if err := signature.UnmarshalText(data); err != nil {
+		return nil, fmt.Errorf("parse signature file %s failed: %w", name, err)
+	}
+	return signature, nil
+}
+
+func hashSource(file *source.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeClosers([]io.Closer{reader})
+
+	sum, err := sign.HashReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("hash file %s failed: %w", file.Source(), err)
+	}
+	return sum, nil
+}
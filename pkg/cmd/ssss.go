@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/path"
+	"shamir/pkg/utils/secure"
+	"shamir/pkg/utils/shamir"
+	"shamir/pkg/utils/source"
+	"shamir/pkg/utils/ssss"
+)
+
+// 兼容模式
+const (
+	CompatNone = ""
+	CompatSsss = "ssss"
+)
+
+// ssssConf ssss 兼容模式下的参数，与 ssss-split / ssss-combine 的参数对应
+type ssssConf struct {
+	compat      string
+	security    int
+	token       string
+	hex         bool
+	noDiffusion bool
+}
+
+func (s *ssssConf) addFlags(cmd *cobra.Command, split bool) {
+	cmd.Flags().StringVar(&s.compat, "compat", CompatNone, "Use compatible scheme [ssss], "+
+		"ssss is compatible with ssss-split and ssss-combine")
+	cmd.Flags().BoolVar(&s.hex, "hex", false, "Secret is in hex format, the same as ssss -x. Only work with --compat ssss")
+	cmd.Flags().BoolVar(&s.noDiffusion, "no-diffusion", false, "Disable the diffusion layer, the same as ssss -D. "+
+		"Only work with --compat ssss")
+	if !split {
+		return
+	}
+	cmd.Flags().IntVar(&s.security, "security", 0, "Security level in bits, the same as ssss-split -s, "+
+		"default is the length of secret. Only work with --compat ssss")
+	cmd.Flags().StringVar(&s.token, "token", "", "Add token to shares, the same as ssss-split -w. Only work with --compat ssss")
+}
+
+func (s *ssssConf) check() error {
+	switch s.compat {
+	case CompatNone, CompatSsss:
+		return nil
+	default:
+		return fmt.Errorf("invalid compat scheme %q, only support [%s]", s.compat, CompatSsss)
+	}
+}
+
+func (s *ssssConf) options() []ssss.Option {
+	var opts []ssss.Option
+	if s.security != 0 {
+		opts = append(opts, ssss.WithSecurity(s.security))
+	}
+	if s.token != "" {
+		opts = append(opts, ssss.WithToken(s.token))
+	}
+	if s.noDiffusion {
+		opts = append(opts, ssss.WithoutDiffusion())
+	}
+	return opts
+}
+
+func (s *ssssConf) decodeSecret(secret []byte) ([]byte, error) {
+	if !s.hex {
+		return secret, nil
+	}
+
+	secretStr := strings.TrimSpace(string(secret))
+	// ssss 会在奇数长度的hex左侧补0
+	if len(secretStr)%2 != 0 {
+		secretStr = "0" + secretStr
+	}
+	result, err := hex.DecodeString(secretStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex secret: %w", err)
+	}
+	return result, nil
+}
+
+func (s *ssssConf) encodeSecret(secret []byte) []byte {
+	if s.hex {
+		return []byte(hex.EncodeToString(secret))
+	}
+
+	// ascii 模式下去掉左侧补的0
+	return bytes.TrimLeft(secret, "\x00")
+}
+
+// runSsss 使用 ssss 兼容方案拆分秘密
+func (enc *EncryptCmdConf) runSsss(cmd *cobra.Command, args []string) error {
+	if err := checkTN(enc.t, enc.n); err != nil {
+		return err
+	}
+	if err := enc.checkSecretInput(cmd, args, false); err != nil {
+		return err
+	}
+
+	input, err := enc.getInput(cmd, args)
+	if err != nil {
+		return err
+	}
+	defer closeClosers([]io.Closer{input})
+
+	secret, err := io.ReadAll(io.LimitReader(input, 2*ssss.MaxSecretLen+2))
+	defer secure.Wipe(secret)
+	if err != nil {
+		return fmt.Errorf("read secret failed: %w", err)
+	}
+	// 从标准输入或文件读取时，去掉末尾的换行
+	if len(args) == 0 {
+		secret = bytes.TrimRight(secret, "\r\n")
+	}
+	secret, err = enc.ssss.decodeSecret(secret)
+	if err != nil {
+		return err
+	}
+	defer secure.Wipe(secret)
+	if len(secret) > ssss.MaxSecretLen {
+		return fmt.Errorf("invalid secret, ssss secret length should be less than %d bytes", ssss.MaxSecretLen)
+	}
+
+	shares, err := ssss.Split(secret, enc.t, enc.n, enc.ssss.options()...)
+	if err != nil {
+		return err
+	}
+
+	if enc.outputPath == "" {
+		for _, share := range shares {
+			if _, err = fmt.Fprintln(cmd.OutOrStdout(), share.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return writeSsssShares(enc.outputPath, shares)
+}
+
+func writeSsssShares(outputPath string, shares []*ssss.Share) error {
+	outputPath = filepath.Clean(outputPath)
+	err := os.MkdirAll(outputPath, 0750)
+	if err != nil {
+		return err
+	}
+
+	err = path.CheckNoKey(outputPath)
+	if err != nil {
+		return err
+	}
+
+	tx := secure.NewTransaction()
+	defer tx.Rollback()
+	for _, share := range shares {
+		shareFileName := filepath.Join(outputPath, fmt.Sprintf("%s%d", path.SsssShareFilePrefix, share.Index))
+		if err = tx.WriteFile(shareFileName, []byte(share.String()+"\n")); err != nil {
+			return fmt.Errorf("write ssss share file %s failed: %w", shareFileName, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// runSsss 使用 ssss 兼容方案合并份额
+func (d *DecryptCmdConf) runSsss(cmd *cobra.Command) error {
+	if d.output != "" && path.IsExist(d.output) {
+		return fmt.Errorf("output file %q is exist", d.output)
+	}
+
+	lines, err := d.getSsssShares(cmd)
+	if err != nil {
+		return err
+	}
+	// ssss 的份额没有校验值，份额个数与门限值不一致时合并出的是错误的秘密而不会报错，与 ssss-combine 一样必须指定 -t
+	if d.t < shamir.MinThreshold {
+		return fmt.Errorf("invalid threshold, please use -t with --compat ssss, the same as ssss-combine -t")
+	}
+	if len(lines) < d.t {
+		return fmt.Errorf("invalid ssss shares, got %d shares less than threshold %d", len(lines), d.t)
+	}
+	if len(lines) > d.t {
+		warnf(cmd, "got %d ssss shares, use the first %d of them", len(lines), d.t)
+		lines = lines[:d.t]
+	}
+
+	shares := make([]*ssss.Share, 0, len(lines))
+	for _, line := range lines {
+		share, e := ssss.ParseShare(line)
+		if e != nil {
+			return e
+		}
+		shares = append(shares, share)
+	}
+
+	plain, err := ssss.Combine(shares, d.ssss.options()...)
+	if err != nil {
+		return err
+	}
+	defer secure.Wipe(plain)
+	secret := d.ssss.encodeSecret(plain)
+	defer secure.Wipe(secret)
+
+	if d.output == "" && !d.exec.enabled {
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(secret))
+		return err
+	}
+
+	output, taskIndicator, err := d.getOutput(cmd)
+	if err != nil {
+		return err
+	}
+	defer taskIndicator.Fail()
+	if _, err = output.Write(secret); err != nil {
+		return fmt.Errorf("write secret failed: %w", err)
+	}
+	if d.exec.enabled {
+		return d.exec.run(cmd)
+	}
+
+	taskIndicator.Success()
+	return d.tx.Commit()
+}
+
+// getSsssShares 依次从 -y 参数、-i 指定的文件或文件夹、标准输入中获取份额，每行一个份额
+func (d *DecryptCmdConf) getSsssShares(cmd *cobra.Command) ([]string, error) {
+	if len(d.yKeys) != 0 {
+		return d.yKeys, nil
+	}
+
+	if len(d.inputPaths) == 0 {
+		return readLines(cmd.InOrStdin())
+	}
+
+	files, err := source.Collect(d.inputPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, file := range files {
+		// 目录和归档中只读取 ssss 份额文件，直接指定的文件不限制文件名
+		if strings.HasPrefix(file.Name, path.KeyFilePrefix) && !strings.HasPrefix(file.Name, path.SsssShareFilePrefix) {
+			continue
+		}
+
+		data, e := readSource(file)
+		if e != nil {
+			return nil, fmt.Errorf("read ssss share file %s failed: %w", file.Source(), e)
+		}
+		fileLines, e := readLines(bytes.NewReader(data))
+		if e != nil {
+			return nil, e
+		}
+		lines = append(lines, fileLines...)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("can not found ssss share in input path")
+	}
+
+	return lines, nil
+}
+
+func readLines(reader io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read ssss shares failed: %w", err)
+	}
+
+	return lines, nil
+}
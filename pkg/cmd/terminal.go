@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/sys/unix"
+
+	"shamir/pkg/utils/secure"
+)
+
+const terminalDevice = "/dev/tty"
+
+// 非规范模式下需要自行处理的控制字符
+const (
+	keyEOF       = 0x04
+	keyBackspace = 0x08
+	keyDelete    = 0x7f
+)
+
+// ReadHidden 从终端读取一行输入，输入时关闭回显
+// 使用 /dev/tty 而不是标准输入，这样标准输入被重定向时也可以正常提示。
+// 规范模式下一行最多 4095 个字符，粘贴较长的密钥会被截断，所以使用非规范模式逐字节读取
+func ReadHidden(prompt string) ([]byte, error) {
+	tty, err := os.OpenFile(terminalDevice, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open terminal failed: %w", err)
+	}
+	defer tty.Close()
+
+	fd := int(tty.Fd())
+	oldState, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, fmt.Errorf("get terminal state failed: %w", err)
+	}
+
+	newState := *oldState
+	newState.Lflag &^= unix.ECHO | unix.ICANON
+	newState.Lflag |= unix.ISIG
+	newState.Iflag |= unix.ICRNL
+	newState.Cc[unix.VMIN] = 1
+	newState.Cc[unix.VTIME] = 0
+	if err = unix.IoctlSetTermios(fd, unix.TCSETS, &newState); err != nil {
+		return nil, fmt.Errorf("disable terminal echo failed: %w", err)
+	}
+	defer func() {
+		_ = unix.IoctlSetTermios(fd, unix.TCSETS, oldState)
+	}()
+
+	if _, err = fmt.Fprint(tty, prompt); err != nil {
+		return nil, err
+	}
+	line, err := readLine(&ttyReader{tty: tty})
+	// 关闭回显后用户输入的换行不会显示，手动换行
+	_, _ = fmt.Fprintln(tty)
+	if err != nil {
+		return nil, fmt.Errorf("read from terminal failed: %w", err)
+	}
+
+	return line, nil
+}
+
+// ttyReader 逐字节读取终端，不使用 bufio 避免输入留在无法清零的缓冲中
+type ttyReader struct {
+	tty *os.File
+	b   [1]byte
+}
+
+func (r *ttyReader) ReadByte() (byte, error) {
+	n, err := r.tty.Read(r.b[:])
+	if n == 1 {
+		c := r.b[0]
+		r.b[0] = 0
+		return c, nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return 0, err
+}
+
+// readLine 读取一行输入，处理退格键，行首输入 Ctrl-D 时返回 io.EOF。
+// 扩容和出错时清零已经读取的数据
+func readLine(reader io.ByteReader) ([]byte, error) {
+	var line []byte
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			secure.Wipe(line)
+			return nil, err
+		}
+
+		switch c {
+		case '\n', '\r':
+			return line, nil
+		case keyBackspace, keyDelete:
+			// 按字符删除，避免截断多字节的口令
+			_, size := utf8.DecodeLastRune(line)
+			secure.Wipe(line[len(line)-size:])
+			line = line[:len(line)-size]
+		case keyEOF:
+			if len(line) == 0 {
+				return nil, io.EOF
+			}
+		default:
+			if len(line) == cap(line) {
+				grown := make([]byte, len(line), 2*cap(line)+64)
+				copy(grown, line)
+				secure.Wipe(line)
+				line = grown
+			}
+			line = append(line, c)
+		}
+	}
+}
+
+// ReadHiddenConfirm 从终端读取两次输入，两次输入一致时返回
+func ReadHiddenConfirm(prompt, confirmPrompt string) ([]byte, error) {
+	input, err := ReadHidden(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	confirm, err := ReadHidden(confirmPrompt)
+	if err != nil {
+		secure.Wipe(input)
+		return nil, err
+	}
+	defer secure.Wipe(confirm)
+
+	if !bytes.Equal(input, confirm) {
+		secure.Wipe(input)
+		return nil, fmt.Errorf("inputs do not match")
+	}
+	return input, nil
+}
@@ -12,6 +12,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/log"
 )
 
 // Table 格式化方式
@@ -82,6 +83,16 @@ func ExactArgs(n int) cobra.PositionalArgs {
 	}
 }
 
+func MaximumNArgs(n int) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MaximumNArgs(n)(cmd, args); err != nil {
+			_ = cmd.Usage()
+			return err
+		}
+		return nil
+	}
+}
+
 type myReadWriteCloser struct {
 	io.ReadWriter
 }
@@ -110,6 +121,36 @@ func NewWriteCloser(w io.Writer) *myWriteCloser {
 	}
 }
 
+type readOnly struct {
+	io.Reader
+}
+
+func (r *readOnly) Write(_ []byte) (int, error) {
+	return 0, fmt.Errorf("write to read only stream")
+}
+
+// NewReadOnly 将只读的数据流转换为 io.ReadWriter，写入时将返回错误
+func NewReadOnly(r io.Reader) io.ReadWriter {
+	return &readOnly{
+		Reader: r,
+	}
+}
+
+type writeOnly struct {
+	io.Writer
+}
+
+func (w *writeOnly) Read(_ []byte) (int, error) {
+	return 0, fmt.Errorf("read from write only stream")
+}
+
+// NewWriteOnly 将只写的数据流转换为 io.ReadWriter，读取时将返回错误
+func NewWriteOnly(w io.Writer) io.ReadWriter {
+	return &writeOnly{
+		Writer: w,
+	}
+}
+
 type keyReadWriter struct {
 	x io.ReadWriter
 	y io.ReadWriter
@@ -122,18 +163,12 @@ func NewKeyReadWriter(x, y io.ReadWriter) *keyReadWriter {
 	}
 }
 
-func (k *keyReadWriter) ToXYKeyDecoder() *xyKeyDecoder {
-	return &xyKeyDecoder{
-		x: code.NewKeyDecoder(k.x),
-		y: code.NewKeyDecoder(k.y),
-	}
+func (k *keyReadWriter) ToXYKeyDecoder() *code.XYKeyDecoder {
+	return code.NewXYKeyDecoder(k.x, k.y)
 }
 
-func (k *keyReadWriter) ToXYKeyEncoder() *xyKeyEncoder {
-	return &xyKeyEncoder{
-		x: code.NewKeyEncoder(k.x),
-		y: code.NewKeyEncoder(k.y),
-	}
+func (k *keyReadWriter) ToXYKeyEncoder() *code.XYKeyEncoder {
+	return code.NewXYKeyEncoder(k.x, k.y)
 }
 
 func (k *keyReadWriter) toString() (string, string, error) {
@@ -149,55 +184,6 @@ func (k *keyReadWriter) toString() (string, string, error) {
 	return string(xData), string(yData), nil
 }
 
-type xyKeyDecoder struct {
-	x *code.KeyDecoder
-	y *code.KeyDecoder
-}
-
-func (xy *xyKeyDecoder) decoder(key *code.Key) error {
-	if key == nil {
-		return fmt.Errorf("invalid nil point of key")
-	}
-	if key.X == nil || key.Y == nil {
-		return fmt.Errorf("invalid nil point of x or y")
-	}
-
-	err := xy.x.Write(key.X)
-	if err != nil {
-		return err
-	}
-	err = xy.y.Write(key.Y)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-type xyKeyEncoder struct {
-	x *code.KeyEncoder
-	y *code.KeyEncoder
-}
-
-func (xy *xyKeyEncoder) encoder() (code.Key, bool, error) {
-	key := code.Key{}
-	x, xOk, xErr := xy.x.Read()
-	y, yOk, yErr := xy.y.Read()
-	if xErr != nil || yErr != nil {
-		if xErr != nil {
-			return key, false, fmt.Errorf("read x key failed: %w", xErr)
-		} else {
-			return key, false, fmt.Errorf("read y key failed: %w", yErr)
-		}
-	}
-
-	if xOk != yOk {
-		return key, false, fmt.Errorf("x key not match y key")
-	}
-
-	key.X, key.Y = x, y
-	return key, xOk, nil
-}
-
 // TaskIndicator 任务指示器，使用 Fail 执行任务失败的函数，使用 Success 执行任务成功的函数，并将失败方法置nil
 type TaskIndicator struct {
 	successDo func()
@@ -229,3 +215,15 @@ func IsTerminalInput() bool {
 	_, err := unix.IoctlGetTermios(unix.Stdin, unix.TCGETS)
 	return err == nil
 }
+
+// infof 记录日志，同时输出到标准错误提示用户
+func infof(cmd *cobra.Command, template string, args ...interface{}) {
+	log.Infof(template, args...)
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), template+"\n", args...)
+}
+
+// warnf 记录警告日志，同时输出到标准错误提醒用户
+func warnf(cmd *cobra.Command, template string, args ...interface{}) {
+	log.Warnf(template, args...)
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: "+template+"\n", args...)
+}
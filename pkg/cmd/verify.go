@@ -0,0 +1,420 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"shamir/pkg/utils/graceful"
+	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/shamir"
+)
+
+// 密钥对的检查状态
+const (
+	verifyStatusOK           = "ok"
+	verifyStatusInconsistent = "inconsistent"
+	verifyStatusUnusable     = "unusable"
+	verifyStatusMissing      = "missing"
+	verifyStatusUntested     = "untested"
+)
+
+type VerifyCmdConf struct {
+	// 复用解密时读取密钥文件的逻辑，包括清单、解封和签名校验
+	decrypt DecryptCmdConf
+	format  string
+}
+
+// verifyShare 一个密钥对的检查结果，Succeeded/Tested 为包含该密钥对的组合中成功还原秘密的个数和测试的个数
+type verifyShare struct {
+	Holder    string `json:"holder" yaml:"holder"`
+	Label     string `json:"label,omitempty" yaml:"label,omitempty"`
+	Source    string `json:"source,omitempty" yaml:"source,omitempty"`
+	Status    string `json:"status" yaml:"status"`
+	Reason    string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Succeeded int    `json:"succeeded" yaml:"succeeded"`
+	Tested    int    `json:"tested" yaml:"tested"`
+
+	open func() (*keyReadWriter, []io.Closer, error)
+}
+
+// verifyCombination 一个 t 个密钥对的组合的测试结果，index 为组合中的密钥对在报告中的序号
+type verifyCombination struct {
+	Holders []string `json:"holders" yaml:"holders"`
+	OK      bool     `json:"ok" yaml:"ok"`
+	Reason  string   `json:"reason,omitempty" yaml:"reason,omitempty"`
+
+	index []int
+}
+
+type verifyReport struct {
+	Threshold    int                  `json:"threshold" yaml:"threshold"`
+	Shares       []*verifyShare       `json:"shares" yaml:"shares"`
+	Combinations []*verifyCombination `json:"combinations" yaml:"combinations"`
+	Succeeded    int                  `json:"succeeded" yaml:"succeeded"`
+}
+
+func NewVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{}
+	// 组合逐个测试，不显示解密进度
+	conf := &VerifyCmdConf{decrypt: DecryptCmdConf{progress: progressConf{mode: ProgressNone}}}
+	cmd.Use = "verify"
+	cmd.Short = "Check that shares can restore the secret, without output the secret"
+	cmd.Long =
+		`Check that shares can restore the secret, without output the secret
+
+Every chunk of the secret is restored in memory and checked with the SHA-256 embedded in the keys,
+then discarded, so recovery drills can prove the shares still work without the secret appearing anywhere.
+When manifest.json is in the inputs, the restored secret is also checked with the commitment in it,
+and holders listed in the manifest but not found in the inputs are reported as missing.
+
+Every key pair is checked first, then every combination of t usable key pairs is tested.
+With more than t key pairs, the report has a matrix of which combinations restore the secret.
+Exits with error when any key pair in the inputs or any combination fails.`
+	cmd.Example = `shamir verify -i ./keys/
+shamir verify -i /media/usb1 -i ./bob.tar.gz -t 2 --identity ~/.ssh/id_ed25519
+shamir verify -i ./keys/ --trust dealer.pub --format json
+shamir verify -n 123456789 -x 455 -y 455 -x 666 -y 666`
+	cmd.Args = NoArgs
+	d := &conf.decrypt
+	cmd.Flags().StringArrayVarP(&d.inputPaths, "input-path", "i", nil, "The path of keys, can be repeated. "+
+		"Accepts directories (searched recursively), key files, .zip/.tar/.tar.gz archives and glob patterns")
+	cmd.Flags().StringVarP(&d.necessary, "necessary", "n", "", "The necessary key")
+	cmd.Flags().IntVarP(&d.t, "threshold", "t", 0, "The key's threshold, test every t keys. "+
+		"default is the threshold in manifest.json, or the count of keys from command line")
+	cmd.Flags().StringSliceVarP(&d.xKeys, "x-key", "x", []string{}, "The key of X")
+	cmd.Flags().StringSliceVarP(&d.yKeys, "y-key", "y", []string{}, "The key of Y")
+	cmd.Flags().StringVar(&conf.format, "format", Table, "The output format, [table|yaml|json]")
+	d.seal.addDecryptFlags(cmd)
+	d.sign.addDecryptFlags(cmd)
+
+	cmd.RunE = conf.RunE
+	return cmd
+}
+
+func (v *VerifyCmdConf) RunE(cmd *cobra.Command, _ []string) error {
+	d := &v.decrypt
+	switch v.format {
+	case Table, Yaml, Json:
+	default:
+		return fmt.Errorf("invalid format %q, should be one of [%s|%s|%s]", v.format, Table, Yaml, Json)
+	}
+	if err := d.check(); err != nil {
+		return err
+	}
+	defer d.seal.destroy()
+
+	d.interrupt = graceful.NewSignalContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer d.interrupt.Stop()
+
+	var shares []*verifyShare
+	var openNecessary func() (io.ReadCloser, error)
+	if len(d.inputPaths) == 0 {
+		if d.t == 0 {
+			d.t = len(d.xKeys)
+		}
+		shares, openNecessary = d.argShares()
+	} else {
+		var err error
+		if shares, err = d.fileShares(cmd); err != nil {
+			return err
+		}
+		openNecessary = d.necessaryFile.Open
+	}
+	if d.t < shamir.MinThreshold {
+		return fmt.Errorf("invalid threshold, please use -t correctly when use input keys by path without manifest")
+	}
+
+	report := &verifyReport{Threshold: d.t, Shares: shares}
+	var usable []int
+	for i, share := range shares {
+		if share.Status == verifyStatusUntested {
+			usable = append(usable, i)
+		}
+	}
+
+	var err error
+	switch {
+	case len(usable) < d.t:
+		err = fmt.Errorf("only %d usable key pairs found, can not less than threshold %d", len(usable), d.t)
+	case countCombinations(len(usable), d.t) > maxCombinations:
+		return fmt.Errorf("%d usable key pairs have more than %d combinations of %d, please verify less key pairs at once",
+			len(usable), maxCombinations, d.t)
+	default:
+		err = d.testCombinations(report, usable, openNecessary)
+	}
+	if d.interrupt.Err() != nil {
+		return d.interrupt.Cause()
+	}
+
+	if e := v.render(cmd, report); e != nil {
+		return e
+	}
+	if err != nil {
+		return err
+	}
+	// 演练时通常只有部分持有人在场，清单中缺少的持有人只报告不算失败
+	failed := 0
+	for _, share := range shares {
+		if share.Status != verifyStatusOK && share.Status != verifyStatusMissing {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d key pairs are not ok", failed, len(shares))
+	}
+	return nil
+}
+
+// argShares 命令行中的密钥对，持有人为密钥对的序号
+func (d *DecryptCmdConf) argShares() ([]*verifyShare, func() (io.ReadCloser, error)) {
+	shares := make([]*verifyShare, 0, len(d.xKeys))
+	for i := range d.xKeys {
+		x, y := d.xKeys[i], d.yKeys[i]
+		shares = append(shares, &verifyShare{
+			Holder: strconv.Itoa(i),
+			Source: "command line",
+			Status: verifyStatusUntested,
+			open: func() (*keyReadWriter, []io.Closer, error) {
+				return NewKeyReadWriter(NewReadWriteCloser(bytes.NewBufferString(x)),
+					NewReadWriteCloser(bytes.NewBufferString(y))), nil, nil
+			},
+		})
+	}
+
+	return shares, func() (io.ReadCloser, error) {
+		return NewReadWriteCloser(bytes.NewBufferString(d.necessary)), nil
+	}
+}
+
+// fileShares 输入中的密钥对，清单中有但输入中没有的持有人记为 missing。
+// 持有人的密钥文件存在但被篡改时已记为 unusable，不再记为 missing
+func (d *DecryptCmdConf) fileShares(cmd *cobra.Command) ([]*verifyShare, error) {
+	checks, err := d.checkShares(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([]*verifyShare, 0, len(checks))
+	found := make(map[string]bool)
+	// present 输入中有密钥文件的持有人序号
+	present := make(map[string]bool)
+	for _, check := range checks {
+		present[check.share.holder] = true
+		share := check.share
+		result := &verifyShare{
+			Holder: share.holder,
+			Label:  share.label,
+			Source: share.x.Dir,
+			Status: verifyStatusUntested,
+			open: func() (*keyReadWriter, []io.Closer, error) {
+				return d.openShareFiles(share)
+			},
+		}
+		if check.err != nil {
+			result.Status, result.Reason = verifyStatusUnusable, check.err.Error()
+		} else {
+			found[share.fingerprint] = true
+		}
+		shares = append(shares, result)
+	}
+
+	if m := d.manifest.loaded; m != nil {
+		for _, share := range m.Shares {
+			if found[share.Fingerprint] || present[strconv.Itoa(share.Holder)] {
+				continue
+			}
+			shares = append(shares, &verifyShare{
+				Holder: strconv.Itoa(share.Holder),
+				Label:  share.Label,
+				Status: verifyStatusMissing,
+				Reason: "not found in inputs",
+			})
+		}
+	}
+	return shares, nil
+}
+
+// testCombinations 逐个测试 usable 中 t 个密钥对的组合，全部组合都失败时返回错误
+func (d *DecryptCmdConf) testCombinations(report *verifyReport, usable []int,
+	openNecessary func() (io.ReadCloser, error)) error {
+	// 清单中的承诺计算较慢，相同的秘密只校验一次
+	commitments := make(map[string]error)
+	index := make([]int, d.t)
+	for i := range index {
+		index[i] = i
+	}
+	for {
+		combination := &verifyCombination{index: make([]int, 0, d.t)}
+		for _, i := range index {
+			combination.index = append(combination.index, usable[i])
+			combination.Holders = append(combination.Holders, report.Shares[usable[i]].Holder)
+		}
+
+		err := d.testCombination(report.Shares, combination.index, openNecessary, commitments)
+		if d.interrupt.Err() != nil {
+			return d.interrupt.Cause()
+		}
+		if err != nil {
+			log.Debugf("keys of holders %v can not restore the secret: %v", combination.Holders, err)
+			combination.Reason = err.Error()
+		} else {
+			combination.OK = true
+			report.Succeeded++
+		}
+		for _, i := range combination.index {
+			report.Shares[i].Tested++
+			if combination.OK {
+				report.Shares[i].Succeeded++
+			}
+		}
+		report.Combinations = append(report.Combinations, combination)
+
+		if !shamir.NextCombination(index, len(usable)) {
+			break
+		}
+	}
+
+	for _, i := range usable {
+		if report.Shares[i].Succeeded > 0 {
+			report.Shares[i].Status = verifyStatusOK
+		} else {
+			report.Shares[i].Status = verifyStatusInconsistent
+		}
+	}
+	if report.Succeeded == 0 {
+		return fmt.Errorf("no %d of the %d usable key pairs can restore the secret", d.t, len(usable))
+	}
+	if failed := len(report.Combinations) - report.Succeeded; failed > 0 {
+		return fmt.Errorf("%d of %d combinations can not restore the secret", failed, len(report.Combinations))
+	}
+	return nil
+}
+
+// testCombination 用一组密钥对完整解密一遍，秘密只写入 SHA-256 用于校验清单中的承诺
+func (d *DecryptCmdConf) testCombination(shares []*verifyShare, index []int,
+	openNecessary func() (io.ReadCloser, error), commitments map[string]error) error {
+	var opened []io.Closer
+	defer func() { closeClosers(opened) }()
+
+	keys := make([]*keyReadWriter, 0, len(index))
+	for _, i := range index {
+		key, closers, err := shares[i].open()
+		opened = append(opened, closers...)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	necessary, err := openNecessary()
+	if err != nil {
+		return fmt.Errorf("open necessary key failed: %w", err)
+	}
+	opened = append(opened, necessary)
+
+	digest := sha256.New()
+	if err = d.combine(keys, necessary, digest, nil); err != nil {
+		return err
+	}
+	if d.manifest.loaded == nil {
+		return nil
+	}
+
+	sum := hex.EncodeToString(digest.Sum(nil))
+	if cached, ok := commitments[sum]; ok {
+		return cached
+	}
+	commitments[sum] = d.manifest.loaded.Commitment.Verify(digest.Sum(nil))
+	return commitments[sum]
+}
+
+// render 输出密钥对的检查结果，多于 t 个密钥对时输出每个组合的测试结果
+func (v *VerifyCmdConf) render(cmd *cobra.Command, report *verifyReport) error {
+	writer := cmd.OutOrStdout()
+	if v.format != Table {
+		return RenderData(v.format, nil, nil, report, writer)
+	}
+
+	header := []string{"HOLDER", "LABEL", "SOURCE", "STATUS", "COMBINATIONS", "REASON"}
+	data := make([][]string, 0, len(report.Shares))
+	for _, share := range report.Shares {
+		combinations := ""
+		if share.Tested > 0 {
+			combinations = fmt.Sprintf("%d/%d", share.Succeeded, share.Tested)
+		}
+		data = append(data, []string{share.Holder, share.Label, share.Source, share.Status, combinations, share.Reason})
+	}
+	if err := RenderData(Table, header, data, report, writer); err != nil {
+		return err
+	}
+	if len(report.Combinations) == 0 {
+		return nil
+	}
+
+	if len(report.Combinations) > 1 {
+		// 矩阵的列为参与测试的密钥对，同一持有人出现在多个位置时用序号区分
+		var columns []int
+		for i, share := range report.Shares {
+			if share.Tested > 0 {
+				columns = append(columns, i)
+			}
+		}
+		header = []string{"#"}
+		for _, i := range columns {
+			header = append(header, verifyColumn(report.Shares, i))
+		}
+		header = append(header, "RESULT")
+
+		data = make([][]string, 0, len(report.Combinations))
+		for n, combination := range report.Combinations {
+			row := []string{strconv.Itoa(n + 1)}
+			for _, i := range columns {
+				cell := ""
+				for _, chosen := range combination.index {
+					if chosen == i {
+						cell = "x"
+					}
+				}
+				row = append(row, cell)
+			}
+			result := "ok"
+			if !combination.OK {
+				result = "failed: " + combination.Reason
+			}
+			data = append(data, append(row, result))
+		}
+		if err := RenderData(Table, header, data, report, writer); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(writer, "%d of %d combinations of %d key pairs restored the secret\n",
+		report.Succeeded, len(report.Combinations), report.Threshold)
+	return err
+}
+
+// verifyColumn 矩阵中密钥对的列名，持有人重复时加上在报告中的序号
+func verifyColumn(shares []*verifyShare, i int) string {
+	for j, share := range shares {
+		if j != i && share.Holder == shares[i].Holder && share.Tested > 0 {
+			return fmt.Sprintf("%s#%d", shares[i].Holder, i+1)
+		}
+	}
+	return shares[i].Holder
+}
+
+// countCombinations 从 n 个中取 k 个的组合数
+func countCombinations(n, k int) int64 {
+	count := new(big.Int).Binomial(int64(n), int64(k))
+	if !count.IsInt64() {
+		return maxCombinations + 1
+	}
+	return count.Int64()
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyTamperedShare(t *testing.T) {
+	dir := encryptShares(t, 2, 3, true)
+	tamperShare(t, dir, 0)
+
+	// 被篡改的持有人记为 unusable，不能再被记为清单中缺少的持有人
+	stdout, _, err := runShamir(t, "verify", "-i", dir, "--format", Json)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 3 key pairs are not ok")
+
+	report := &verifyReport{}
+	require.NoError(t, jsoniter.UnmarshalFromString(stdout, report))
+	require.Len(t, report.Shares, 3)
+	assert.Equal(t, "0", report.Shares[0].Holder)
+	assert.Equal(t, verifyStatusUnusable, report.Shares[0].Status)
+	for _, share := range report.Shares[1:] {
+		assert.Equal(t, verifyStatusOK, share.Status)
+	}
+	assert.Equal(t, 1, report.Succeeded)
+}
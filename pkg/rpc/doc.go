@@ -0,0 +1,6 @@
+// Package rpc shamir.proto 中 Shamir 服务的 gRPC 定义
+// shamir.pb.go 和 shamir_grpc.pb.go 由 protoc-gen-go 和 protoc-gen-go-grpc 生成，修改 shamir.proto 后使用 go generate 重新生成，
+// 服务端使用 RegisterShamirServer 注册服务，客户端使用 NewShamirClient 调用
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative shamir.proto
@@ -0,0 +1,53 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TestServiceDesc gRPC 的服务描述与 shamir.proto 中的定义一致
+func TestServiceDesc(t *testing.T) {
+	service := File_shamir_proto.Services().ByName("Shamir")
+	require.NotNil(t, service)
+	assert.Equal(t, string(service.FullName()), Shamir_ServiceDesc.ServiceName)
+
+	methods := service.Methods()
+	assert.Equal(t, methods.Len(), len(Shamir_ServiceDesc.Methods)+len(Shamir_ServiceDesc.Streams))
+	for _, desc := range Shamir_ServiceDesc.Methods {
+		method := methods.ByName(protoreflect.Name(desc.MethodName))
+		require.NotNil(t, method, desc.MethodName)
+		assert.False(t, method.IsStreamingClient(), desc.MethodName)
+		assert.False(t, method.IsStreamingServer(), desc.MethodName)
+	}
+	for _, desc := range Shamir_ServiceDesc.Streams {
+		method := methods.ByName(protoreflect.Name(desc.StreamName))
+		require.NotNil(t, method, desc.StreamName)
+		assert.Equal(t, method.IsStreamingClient(), desc.ClientStreams, desc.StreamName)
+		assert.Equal(t, method.IsStreamingServer(), desc.ServerStreams, desc.StreamName)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	resp := &SplitResponse{
+		Necessary: "necessary",
+		Keys:      []*Key{{X: "x1", Y: "y1"}, {X: "x2", Y: "y2"}},
+		Hash:      true,
+	}
+	data, err := proto.Marshal(resp)
+	require.NoError(t, err)
+
+	got := &SplitResponse{}
+	require.NoError(t, proto.Unmarshal(data, got))
+	assert.True(t, proto.Equal(resp, got))
+
+	// 未知字段保留，不影响已知字段
+	unknown := append(data, 0xa0, 0x06, 0x01)
+	got = &SplitResponse{}
+	require.NoError(t, proto.Unmarshal(unknown, got))
+	assert.Equal(t, "necessary", got.GetNecessary())
+	assert.Len(t, got.GetKeys(), 2)
+}
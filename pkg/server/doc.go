@@ -0,0 +1,4 @@
+// Package server 提供网络服务
+// HTTPServer 用于多人远程恢复秘密，持有人通过 JSON API 向恢复会话提交份额，
+// 服务实现了 taskgroup.Task，可以交给 taskgroup 管理 /*
+package server
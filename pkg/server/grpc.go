@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"shamir/pkg/rpc"
+	"shamir/pkg/utils/log"
+)
+
+type GRPCOption func(s *GRPCServer)
+
+// WithGRPCTLS 使用证书和私钥提供 TLS 加密的 gRPC 服务
+func WithGRPCTLS(certFile, keyFile string) GRPCOption {
+	return func(s *GRPCServer) {
+		s.certFile, s.keyFile = certFile, keyFile
+	}
+}
+
+// GRPCServer 提供 shamir.proto 中定义的 Shamir 服务
+type GRPCServer struct {
+	listener net.Listener
+	server   *grpc.Server
+	certFile string
+	keyFile  string
+}
+
+func NewGRPCServer(listener net.Listener, opts ...GRPCOption) (*GRPCServer, error) {
+	s := &GRPCServer{
+		listener: listener,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	serverOpts := []grpc.ServerOption{grpc.MaxRecvMsgSize(maxBodySize)}
+	if s.certFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.certFile, s.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls certificate failed: %w", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+
+	s.server = grpc.NewServer(serverOpts...)
+	rpc.RegisterShamirServer(s.server, &shamirService{})
+	return s, nil
+}
+
+func (s *GRPCServer) Name() string {
+	return "grpc-server"
+}
+
+// Start 阻塞式提供服务，直到 Stop 被调用
+func (s *GRPCServer) Start() error {
+	err := s.server.Serve(s.listener)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Stop 等待进行中的请求结束，超时后强制关闭
+func (s *GRPCServer) Stop() {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		log.Errorf("shutdown grpc server timeout, force stop")
+		s.server.Stop()
+	}
+}
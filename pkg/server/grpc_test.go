@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"shamir/pkg/rpc"
+)
+
+func newGRPCClient(t *testing.T) rpc.ShamirClient {
+	listener := bufconn.Listen(1 << 20)
+	s, err := NewGRPCServer(listener)
+	require.NoError(t, err)
+	go func() {
+		_ = s.Start()
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return rpc.NewShamirClient(conn)
+}
+
+// split 分多条消息发送秘密，返回每段的拆分结果
+func split(t *testing.T, client rpc.ShamirClient, secret string, threshold, n int32) []*rpc.SplitResponse {
+	stream, err := client.Split(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&rpc.SplitRequest{Threshold: threshold, Number: n}))
+	for _, part := range strings.SplitAfter(secret, " ") {
+		require.NoError(t, stream.Send(&rpc.SplitRequest{Data: []byte(part)}))
+	}
+	require.NoError(t, stream.CloseSend())
+
+	var chunks []*rpc.SplitResponse
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return chunks
+		}
+		require.NoError(t, err)
+		require.Len(t, resp.Keys, int(n))
+		chunks = append(chunks, resp)
+	}
+}
+
+func combine(client rpc.ShamirClient, chunks []*rpc.SplitResponse, holders ...int) (string, error) {
+	stream, err := client.Combine(context.Background())
+	if err != nil {
+		return "", err
+	}
+	for _, chunk := range chunks {
+		req := &rpc.CombineRequest{Necessary: chunk.Necessary}
+		for _, i := range holders {
+			req.Keys = append(req.Keys, chunk.Keys[i])
+		}
+		if err = stream.Send(req); err != nil {
+			return "", err
+		}
+	}
+	if err = stream.CloseSend(); err != nil {
+		return "", err
+	}
+
+	secret := &bytes.Buffer{}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return secret.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		secret.Write(resp.Data)
+	}
+}
+
+// fullKeys 将每段的密钥连接成命令行使用的完整密钥
+func fullKeys(chunks []*rpc.SplitResponse) (string, []*rpc.Key) {
+	necessary := make([]string, 0, len(chunks))
+	xs := make([][]string, len(chunks[0].Keys))
+	ys := make([][]string, len(chunks[0].Keys))
+	for _, chunk := range chunks {
+		necessary = append(necessary, chunk.Necessary)
+		for i, key := range chunk.Keys {
+			xs[i] = append(xs[i], key.X)
+			ys[i] = append(ys[i], key.Y)
+		}
+	}
+
+	keys := make([]*rpc.Key, 0, len(xs))
+	for i := range xs {
+		keys = append(keys, &rpc.Key{X: strings.Join(xs[i], "_"), Y: strings.Join(ys[i], "_")})
+	}
+	return strings.Join(necessary, "_"), keys
+}
+
+func TestGRPCSplitCombine(t *testing.T) {
+	client := newGRPCClient(t)
+	secret := strings.Repeat("this is a secret.同时可以使用中文。", 20)
+
+	chunks := split(t, client, secret, 2, 3)
+	require.Greater(t, len(chunks), 2)
+	assert.True(t, chunks[len(chunks)-1].Hash)
+
+	result, err := combine(client, chunks, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, secret, result)
+
+	// 不同秘密的密钥混用
+	other := split(t, client, "another secret", 2, 3)
+	mixed := append([]*rpc.SplitResponse{}, chunks...)
+	mixed[len(mixed)-1] = other[len(other)-1]
+	_, err = combine(client, mixed, 0, 1)
+	assert.Equal(t, codes.DataLoss, status.Code(err))
+
+	_, err = combine(client, chunks, 1, 1)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stream, err := client.Split(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&rpc.SplitRequest{Threshold: 3, Number: 2}))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCVerifyInspect(t *testing.T) {
+	client := newGRPCClient(t)
+	necessary, keys := fullKeys(split(t, client, "server secret", 2, 3))
+	_, otherKeys := fullKeys(split(t, client, "server secret", 2, 3))
+
+	verified, err := client.Verify(context.Background(), &rpc.VerifyRequest{Necessary: necessary, Keys: keys[1:]})
+	require.NoError(t, err)
+	assert.True(t, verified.Valid)
+
+	verified, err = client.Verify(context.Background(),
+		&rpc.VerifyRequest{Necessary: necessary, Keys: []*rpc.Key{keys[0], otherKeys[1]}})
+	require.NoError(t, err)
+	assert.False(t, verified.Valid)
+	assert.NotEmpty(t, verified.Reason)
+
+	_, err = client.Verify(context.Background(), &rpc.VerifyRequest{Necessary: necessary, Keys: keys[:1]})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	truncated := &rpc.Key{X: strings.SplitN(keys[2].X, "_", 2)[0], Y: strings.SplitN(keys[2].Y, "_", 2)[0]}
+	inspected, err := client.Inspect(context.Background(),
+		&rpc.InspectRequest{Necessary: necessary, Keys: []*rpc.Key{keys[0], keys[0], truncated}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(strings.Count(necessary, "_")+1), inspected.Chunks)
+	require.Len(t, inspected.Keys, 3)
+	assert.True(t, inspected.Keys[0].Valid)
+	assert.False(t, inspected.Keys[1].Valid)
+	assert.Contains(t, inspected.Keys[1].Reason, "duplicate")
+	assert.False(t, inspected.Keys[2].Valid)
+	assert.Equal(t, int32(1), inspected.Keys[2].Chunks)
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/recovery"
+	"shamir/pkg/utils/shamir"
+)
+
+const (
+	// 请求体的大小上限，份额的大小与秘密相当
+	maxBodySize     = 8 << 20
+	shutdownTimeout = 10 * time.Second
+	sessionsPath    = "/v1/sessions"
+	bearerPrefix    = "Bearer "
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+type HTTPOption func(s *HTTPServer)
+
+// WithTLS 使用证书和私钥提供 HTTPS 服务
+func WithTLS(certFile, keyFile string) HTTPOption {
+	return func(s *HTTPServer) {
+		s.certFile, s.keyFile = certFile, keyFile
+	}
+}
+
+// WithCreateToken 创建会话时需要提供的 token，为空时任何人都可以创建会话
+func WithCreateToken(token string) HTTPOption {
+	return func(s *HTTPServer) {
+		s.createToken = token
+	}
+}
+
+// HTTPServer 恢复会话的 HTTP 服务
+//
+//	POST   /v1/sessions              {"threshold": 2, "necessary_key": "..."} 创建会话，返回会话 id、token 和 submit_token
+//	GET    /v1/sessions/{id}         查看会话进度
+//	POST   /v1/sessions/{id}/shares  {"key_x": "...", "key_y": "..."} 使用 submit_token 提交份额
+//	POST   /v1/sessions/{id}/release 使用 token 还原秘密，返回 base64 编码的秘密
+//	DELETE /v1/sessions/{id}         使用 token 取消会话
+type HTTPServer struct {
+	listener    net.Listener
+	server      *http.Server
+	manager     *recovery.Manager
+	certFile    string
+	keyFile     string
+	createToken string
+}
+
+func NewHTTPServer(listener net.Listener, manager *recovery.Manager, opts ...HTTPOption) *HTTPServer {
+	s := &HTTPServer{
+		listener: listener,
+		manager:  manager,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.server = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+func (s *HTTPServer) Name() string {
+	return "http-server"
+}
+
+// Start 阻塞式提供服务，直到 Stop 被调用
+func (s *HTTPServer) Start() error {
+	var err error
+	if s.certFile != "" {
+		err = s.server.ServeTLS(s.listener, s.certFile, s.keyFile)
+	} else {
+		err = s.server.Serve(s.listener)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *HTTPServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Errorf("shutdown http server failed: %v", err)
+	}
+}
+
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	// 份额和秘密不应被缓存
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.URL.Path == sessionsPath {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		s.create(w, r)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, sessionsPath+"/")
+	if rest == r.URL.Path || rest == "" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	parts := strings.Split(rest, "/")
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.progress(w, parts[0])
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		s.cancel(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "shares" && r.Method == http.MethodPost:
+		s.submit(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "release" && r.Method == http.MethodPost:
+		s.release(w, r, parts[0])
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+type createRequest struct {
+	Threshold int    `json:"threshold"`
+	Necessary string `json:"necessary_key"`
+}
+
+type releaseResponse struct {
+	Secret string `json:"secret"`
+}
+
+func (s *HTTPServer) create(w http.ResponseWriter, r *http.Request) {
+	if s.createToken != "" &&
+		subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(s.createToken)) != 1 {
+		writeError(w, http.StatusUnauthorized, recovery.Unauthorized)
+		return
+	}
+
+	req := &createRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	created, err := s.manager.Create(req.Threshold, req.Necessary)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *HTTPServer) progress(w http.ResponseWriter, id string) {
+	progress, err := s.manager.Progress(id)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *HTTPServer) submit(w http.ResponseWriter, r *http.Request, id string) {
+	req := &code.StrKey{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	progress, err := s.manager.Submit(id, bearerToken(r), req.X, req.Y)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *HTTPServer) release(w http.ResponseWriter, r *http.Request, id string) {
+	secret, err := s.manager.Release(id, bearerToken(r))
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &releaseResponse{Secret: base64.StdEncoding.EncodeToString(secret)})
+	for i := range secret {
+		secret[i] = 0
+	}
+}
+
+func (s *HTTPServer) cancel(w http.ResponseWriter, r *http.Request, id string) {
+	if err := s.manager.Cancel(id, bearerToken(r)); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(auth, bearerPrefix)
+}
+
+func writeManagerError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, recovery.SessionNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, recovery.Unauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, recovery.TooManySessions):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, recovery.DuplicateShare), errors.Is(err, recovery.TooManyShares),
+		errors.Is(err, recovery.NotReady):
+		status = http.StatusConflict
+	case errors.Is(err, shamir.HashCheckFailed), errors.Is(err, recovery.InconsistentShare):
+		status = http.StatusUnprocessableEntity
+	}
+	writeError(w, status, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("write http response failed: %v", err)
+	}
+}
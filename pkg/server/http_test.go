@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/recovery"
+)
+
+// 使用 shamir encrypt -t 2 -n 3 加密 "server secret" 得到的密钥
+const necessary = "AwGeptL1TMEBFSqZfp4BXWGY80D_16VojPatgWtwXcxnxocPse5M8CZAWEv6Z3VKi4xumNW7FQdf"
+
+var keys = []code.StrKey{
+	{X: "8rM7JeDwoNt_37XVNhYz1h3", Y: "gCPpQZuBalj11RA5kekMPPSF4Sp_VuIXwy5QrmMkPvtGyoWgy1UDg7kKua3xlA6Lv2FOQI3ZGxS"},
+	{X: "abo49IGUrrY_1davkF9zC5h", Y: "kkzeI5f57wCZAyxrEfRLLh4LJQ_vuQPxKvGBjVfAiEOxPN3idXy8vVgKnB2peUUa3X9RSZJ6JY"},
+	{X: "9llqrRXdQZG_avaZBGFI5wc", Y: "ve1eQXSBhdsBJgrmCbm9b0cCEpD_rgbtsaRn8OrZO4s70KyyzKP4Kl2isfF1jxZfejxVrDrKqKb"},
+}
+
+func do(t *testing.T, server *httptest.Server, method, path, token string, body, result interface{}) int {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, server.URL+path, reader)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", bearerPrefix+token)
+	}
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if result != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(result))
+	}
+	return resp.StatusCode
+}
+
+func TestHTTPServer(t *testing.T) {
+	s := NewHTTPServer(nil, recovery.NewManager(), WithCreateToken("admin"))
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	req := &createRequest{Threshold: 2, Necessary: necessary}
+	assert.Equal(t, http.StatusUnauthorized, do(t, server, http.MethodPost, sessionsPath, "", req, nil))
+
+	created := &recovery.Created{}
+	require.Equal(t, http.StatusCreated, do(t, server, http.MethodPost, sessionsPath, "admin", req, created))
+	sessionPath := sessionsPath + "/" + created.ID
+
+	progress := &recovery.Progress{}
+	assert.Equal(t, http.StatusUnauthorized, do(t, server, http.MethodPost, sessionPath+"/shares", "", keys[0], nil))
+	submit := created.SubmitToken
+	require.Equal(t, http.StatusOK, do(t, server, http.MethodPost, sessionPath+"/shares", submit, keys[0], progress))
+	assert.Equal(t, 1, progress.Collected)
+	assert.Equal(t, http.StatusConflict, do(t, server, http.MethodPost, sessionPath+"/shares", submit, keys[0], nil))
+	assert.Equal(t, http.StatusConflict, do(t, server, http.MethodPost, sessionPath+"/release", created.Token, nil, nil))
+
+	require.Equal(t, http.StatusOK, do(t, server, http.MethodPost, sessionPath+"/shares", submit, keys[2], progress))
+	require.Equal(t, http.StatusOK, do(t, server, http.MethodGet, sessionPath, "", nil, progress))
+	assert.True(t, progress.Ready)
+
+	assert.Equal(t, http.StatusUnauthorized, do(t, server, http.MethodPost, sessionPath+"/release", "wrong", nil, nil))
+	released := &releaseResponse{}
+	require.Equal(t, http.StatusOK, do(t, server, http.MethodPost, sessionPath+"/release", created.Token, nil, released))
+	secret, err := base64.StdEncoding.DecodeString(released.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, "server secret", string(secret))
+
+	assert.Equal(t, http.StatusNotFound, do(t, server, http.MethodGet, sessionPath, "", nil, nil))
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Expirer 可以清除超时数据的对象，如恢复会话的 recovery.Manager
+type Expirer interface {
+	Expire() int
+}
+
+// Janitor 定期清除超时的恢复会话或其他超时数据
+type Janitor struct {
+	manager  Expirer
+	interval time.Duration
+	stop     chan struct{}
+	once     sync.Once
+}
+
+func NewJanitor(manager Expirer, interval time.Duration) *Janitor {
+	return &Janitor{
+		manager:  manager,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (j *Janitor) Name() string {
+	return "session-janitor"
+}
+
+func (j *Janitor) Start() error {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.manager.Expire()
+		case <-j.stop:
+			return nil
+		}
+	}
+}
+
+func (j *Janitor) Stop() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+}
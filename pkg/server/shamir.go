@@ -0,0 +1,316 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"shamir/pkg/rpc"
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/compute"
+	"shamir/pkg/utils/shamir"
+)
+
+// 与命令行加密的限制相同
+const maxKeysNumber = 1000
+
+var splitLen = compute.GetSecretMaxLen() - 1
+
+// shamirService 实现 rpc.ShamirServer，服务本身不保存任何秘密和密钥
+type shamirService struct {
+	rpc.UnimplementedShamirServer
+}
+
+// Split 与命令行加密相同，逐段加密秘密，最后加密秘密的hash值
+func (s *shamirService) Split(stream rpc.Shamir_SplitServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "empty split request")
+	}
+	if err != nil {
+		return err
+	}
+	threshold, number := int(first.Threshold), int(first.Number)
+	if err = checkThreshold(threshold, number); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go receiveSecret(stream, first.Data, writer)
+
+	encoder := code.NewSecretEncoder(reader, splitLen)
+	for {
+		chunk, err := encoder.Read()
+		if err != nil {
+			return err
+		}
+		last := chunk == nil
+		if last {
+			chunk = encoder.GetHash()
+		}
+
+		keys, prime, err := shamir.Encrypt(chunk, threshold, number, true)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		resp := &rpc.SplitResponse{
+			Necessary: code.DecodeKey(prime),
+			Keys:      make([]*rpc.Key, 0, len(keys)),
+			Hash:      last,
+		}
+		for _, key := range keys {
+			resp.Keys = append(resp.Keys, &rpc.Key{X: code.DecodeKey(key.X), Y: code.DecodeKey(key.Y)})
+		}
+		if err = stream.Send(resp); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// receiveSecret 将客户端发送的秘密写入管道，Split 返回后管道关闭，写入随之结束
+func receiveSecret(stream rpc.Shamir_SplitServer, data []byte, writer *io.PipeWriter) {
+	for {
+		if len(data) > 0 {
+			if _, err := writer.Write(data); err != nil {
+				return
+			}
+		}
+
+		req, err := stream.Recv()
+		if err == io.EOF {
+			_ = writer.Close()
+			return
+		}
+		if err != nil {
+			_ = writer.CloseWithError(err)
+			return
+		}
+		data = req.Data
+	}
+}
+
+// Combine 逐段解密，秘密在校验hash前就已经发送，校验失败时客户端应丢弃收到的数据
+func (s *shamirService) Combine(stream rpc.Shamir_CombineServer) error {
+	decoder := code.NewSecretDecoder(&combineWriter{stream: stream})
+	count := 0
+	for chunk := 0; ; chunk++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			if chunk == 0 {
+				return status.Error(codes.InvalidArgument, "empty combine request")
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		keys, prime, err := parseChunk(req.Necessary, req.Keys)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "chunk %d: %v", chunk, err)
+		}
+		if chunk == 0 {
+			count = len(keys)
+		} else if len(keys) != count {
+			return status.Errorf(codes.InvalidArgument, "chunk %d has %d keys, expected %d", chunk, len(keys), count)
+		}
+
+		secret, err := shamir.Decrypt(keys, prime)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "chunk %d: %v", chunk, err)
+		}
+		// 密钥有误时解密出的数据可能无法写入，同样视为校验失败
+		if err = decoder.Write(secret); err != nil {
+			return status.Error(codes.DataLoss, shamir.HashCheckFailed.Error())
+		}
+	}
+
+	if err := decoder.HashCheck(); err != nil {
+		return status.Error(codes.DataLoss, shamir.HashCheckFailed.Error())
+	}
+	return nil
+}
+
+type combineWriter struct {
+	stream rpc.Shamir_CombineServer
+}
+
+func (w *combineWriter) Write(p []byte) (int, error) {
+	if err := w.stream.Send(&rpc.CombineResponse{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Verify 使用完整的密钥解密并校验hash，解密出的秘密直接丢弃
+func (s *shamirService) Verify(_ context.Context, req *rpc.VerifyRequest) (*rpc.VerifyResponse, error) {
+	primes, keys, err := parseShares(req.Necessary, req.Keys)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if len(keys) < shamir.MinThreshold {
+		return nil, status.Errorf(codes.InvalidArgument, "need at least %d keys", shamir.MinThreshold)
+	}
+
+	if err = verifyShares(primes, keys); err != nil {
+		return &rpc.VerifyResponse{Reason: err.Error()}, nil
+	}
+	return &rpc.VerifyResponse{Valid: true}, nil
+}
+
+func verifyShares(primes []*big.Int, keys []code.CompoundKey) error {
+	decoder := code.NewSecretDecoder(io.Discard)
+	for i, prime := range primes {
+		chunk := make([]code.Key, 0, len(keys))
+		for _, key := range keys {
+			if len(key.X) != len(primes) || len(key.Y) != len(primes) {
+				return fmt.Errorf("keys not match, key has %d parts but necessary key has %d", len(key.X), len(primes))
+			}
+			chunk = append(chunk, code.Key{X: key.X[i], Y: key.Y[i]})
+		}
+		if err := shamir.CheckKeys(chunk, prime); err != nil {
+			return err
+		}
+
+		secret, err := shamir.Decrypt(chunk, prime)
+		if err != nil {
+			return err
+		}
+		if err = decoder.Write(secret); err != nil {
+			return shamir.HashCheckFailed
+		}
+	}
+
+	if err := decoder.HashCheck(); err != nil {
+		return shamir.HashCheckFailed
+	}
+	return nil
+}
+
+// Inspect 检查每个密钥的段数和取值是否与必须密钥匹配
+func (s *shamirService) Inspect(_ context.Context, req *rpc.InspectRequest) (*rpc.InspectResponse, error) {
+	primes, ok := code.EncodeKeys(req.Necessary)
+	if !ok || req.Necessary == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid necessary key")
+	}
+
+	resp := &rpc.InspectResponse{
+		Chunks: int32(len(primes)),
+		Keys:   make([]*rpc.KeyInfo, 0, len(req.Keys)),
+	}
+	seen := make(map[string]int, len(req.Keys))
+	for i, key := range req.Keys {
+		info := &rpc.KeyInfo{}
+		resp.Keys = append(resp.Keys, info)
+
+		xs, xOk := code.EncodeKeys(key.X)
+		ys, yOk := code.EncodeKeys(key.Y)
+		if !xOk || !yOk {
+			info.Reason = "invalid key encoding"
+			continue
+		}
+		info.Chunks = int32(len(xs))
+		if len(xs) != len(ys) {
+			info.Reason = fmt.Sprintf("key x has %d parts but key y has %d", len(xs), len(ys))
+			continue
+		}
+		if len(xs) != len(primes) {
+			info.Reason = fmt.Sprintf("key has %d parts but necessary key has %d", len(xs), len(primes))
+			continue
+		}
+		if err := checkKeyRange(xs, ys, primes); err != nil {
+			info.Reason = err.Error()
+			continue
+		}
+
+		// 每段的x都是随机生成的，只比较第一段即可发现重复的密钥
+		x := new(big.Int).Mod(xs[0], primes[0]).String()
+		if j, ok := seen[x]; ok {
+			info.Reason = fmt.Sprintf("duplicate x key of key %d", j)
+			continue
+		}
+		seen[x] = i
+		info.Valid = true
+	}
+	return resp, nil
+}
+
+func checkKeyRange(xs, ys, primes []*big.Int) error {
+	for i, prime := range primes {
+		if prime.Sign() <= 0 {
+			return fmt.Errorf("invalid necessary key")
+		}
+		if new(big.Int).Mod(xs[i], prime).Sign() == 0 {
+			return fmt.Errorf("part %d: x key is zero", i)
+		}
+		if ys[i].Sign() < 0 || ys[i].Cmp(prime) >= 0 {
+			return fmt.Errorf("part %d: y key out of range", i)
+		}
+	}
+	return nil
+}
+
+func checkThreshold(threshold, number int) error {
+	if threshold < shamir.MinThreshold {
+		return fmt.Errorf("invalid threshold %d, should more than %d", threshold, shamir.MinThreshold)
+	}
+	if threshold > number {
+		return fmt.Errorf("invalid threshold %d, threshold should less than key number %d", threshold, number)
+	}
+	if number > maxKeysNumber {
+		return fmt.Errorf("invalid key number %d, key number should less than %d", number, maxKeysNumber)
+	}
+	return nil
+}
+
+// parseChunk 解析一段必须密钥和对应的密钥
+func parseChunk(necessary string, keys []*rpc.Key) ([]code.Key, *big.Int, error) {
+	prime, ok := code.EncodeKey(necessary)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid necessary key")
+	}
+	if len(keys) < shamir.MinThreshold {
+		return nil, nil, fmt.Errorf("need at least %d keys", shamir.MinThreshold)
+	}
+
+	result := make([]code.Key, 0, len(keys))
+	for i, key := range keys {
+		x, xOk := code.EncodeKey(key.X)
+		y, yOk := code.EncodeKey(key.Y)
+		if !xOk || !yOk {
+			return nil, nil, fmt.Errorf("invalid key %d", i)
+		}
+		result = append(result, code.Key{X: x, Y: y})
+	}
+	if err := shamir.CheckKeys(result, prime); err != nil {
+		return nil, nil, err
+	}
+	return result, prime, nil
+}
+
+// parseShares 解析完整的必须密钥和密钥
+func parseShares(necessary string, keys []*rpc.Key) ([]*big.Int, []code.CompoundKey, error) {
+	primes, ok := code.EncodeKeys(necessary)
+	if !ok || necessary == "" {
+		return nil, nil, fmt.Errorf("invalid necessary key")
+	}
+
+	result := make([]code.CompoundKey, 0, len(keys))
+	for i, key := range keys {
+		xs, xOk := code.EncodeKeys(key.X)
+		ys, yOk := code.EncodeKeys(key.Y)
+		if !xOk || !yOk {
+			return nil, nil, fmt.Errorf("invalid key %d", i)
+		}
+		result = append(result, code.CompoundKey{X: xs, Y: ys})
+	}
+	return primes, result, nil
+}
@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"shamir/pkg/server"
+	"shamir/pkg/utils/recovery"
+)
+
+// 使用 shamir encrypt -t 2 -n 3 加密 "server secret" 得到的密钥
+const necessary = "AwGeptL1TMEBFSqZfp4BXWGY80D_16VojPatgWtwXcxnxocPse5M8CZAWEv6Z3VKi4xumNW7FQdf"
+
+var shares = []*Share{
+	{Comment: "holder 1", Necessary: []byte(necessary), X: []byte("8rM7JeDwoNt_37XVNhYz1h3"), Y: []byte("gCPpQZuBalj11RA5kekMPPSF4Sp_VuIXwy5QrmMkPvtGyoWgy1UDg7kKua3xlA6Lv2FOQI3ZGxS")},
+	{Comment: "holder 2", X: []byte("abo49IGUrrY_1davkF9zC5h"), Y: []byte("kkzeI5f57wCZAyxrEfRLLh4LJQ_vuQPxKvGBjVfAiEOxPN3idXy8vVgKnB2peUUa3X9RSZJ6JY")},
+	{Comment: "holder 3", X: []byte("9llqrRXdQZG_avaZBGFI5wc"), Y: []byte("ve1eQXSBhdsBJgrmCbm9b0cCEpD_rgbtsaRn8OrZO4s70KyyzKP4Kl2isfF1jxZfejxVrDrKqKb")},
+}
+
+func TestKeyring(t *testing.T) {
+	now := time.Now()
+	k := NewKeyring(WithTTL(time.Minute))
+	k.now = func() time.Time { return now }
+
+	_, err := k.Add(&Share{X: []byte("!"), Y: shares[0].Y}, 0)
+	assert.ErrorIs(t, err, InvalidShare)
+	_, err = k.Add(&Share{Necessary: []byte("abc"), X: shares[0].X, Y: shares[0].Y}, 0)
+	assert.ErrorIs(t, err, InvalidShare)
+
+	first, err := k.Add(shares[0], 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, first.Chunks)
+	assert.True(t, first.HasNecessary)
+	second, err := k.Add(shares[1], 3*time.Minute)
+	require.NoError(t, err)
+	// 重复添加替换原来的份额
+	_, err = k.Add(shares[1], 3*time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, k.List(), 2)
+
+	got, err := k.Get(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, shares[0], got[0])
+
+	now = now.Add(50 * time.Second)
+	_, err = k.Refresh(first.ID, 0)
+	require.NoError(t, err)
+	now = now.Add(50 * time.Second)
+	assert.Equal(t, 0, k.Expire())
+	now = now.Add(50 * time.Second)
+	assert.Equal(t, 1, k.Expire())
+	_, err = k.Get(first.ID)
+	assert.ErrorIs(t, err, ShareNotFound)
+
+	n, err := k.Remove(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, k.List())
+}
+
+func TestServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	listener, err := Listen(path)
+	require.NoError(t, err)
+	_, err = Listen(path)
+	assert.Error(t, err)
+
+	keyring := NewKeyring()
+	s := NewServer(listener, keyring)
+	go func() {
+		_ = s.Start()
+	}()
+	defer s.Stop()
+	defer keyring.Close()
+
+	client := NewClient(path)
+	infos := make([]*Info, 0, len(shares))
+	for _, share := range shares {
+		info, err := client.Add(share, 0)
+		require.NoError(t, err)
+		infos = append(infos, info)
+	}
+	listed, err := client.List()
+	require.NoError(t, err)
+	assert.Len(t, listed, 3)
+
+	got, err := client.Get()
+	require.NoError(t, err)
+	assert.Len(t, got, 3)
+	_, err = client.Get("unknown")
+	assert.ErrorIs(t, err, ShareNotFound)
+
+	// 由 agent 提交份额到恢复会话
+	manager := recovery.NewManager()
+	httpServer := httptest.NewServer(server.NewHTTPServer(nil, manager))
+	defer httpServer.Close()
+	created, err := manager.Create(2, necessary)
+	require.NoError(t, err)
+	_, err = client.Contribute(infos[0].ID, httpServer.URL, created.ID, created.Token)
+	assert.Error(t, err)
+	progress, err := client.Contribute(infos[0].ID, httpServer.URL, created.ID, created.SubmitToken)
+	require.NoError(t, err)
+	assert.Equal(t, 1, progress.Collected)
+	_, err = client.Contribute(infos[0].ID, httpServer.URL, created.ID, created.SubmitToken)
+	assert.Error(t, err)
+	progress, err = client.Contribute(infos[2].ID, httpServer.URL, created.ID, created.SubmitToken)
+	require.NoError(t, err)
+	assert.True(t, progress.Ready)
+	secret, err := manager.Release(created.ID, created.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "server secret", string(secret))
+
+	n, err := client.Remove()
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
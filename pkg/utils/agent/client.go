@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"shamir/pkg/utils/recovery"
+	"shamir/pkg/utils/secure"
+)
+
+// SocketEnv 保存 agent socket 路径的环境变量
+const SocketEnv = "SHAMIR_AGENT_SOCK"
+
+// DefaultSocket 默认的 socket 路径，依次使用 $SHAMIR_AGENT_SOCK、$XDG_RUNTIME_DIR 和临时目录
+func DefaultSocket() string {
+	if path := os.Getenv(SocketEnv); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "shamir-agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("shamir-agent-%d", os.Getuid()), "agent.sock")
+}
+
+// Client 连接 agent 的客户端，每个请求使用一个新的连接
+type Client struct {
+	path string
+}
+
+func NewClient(path string) *Client {
+	return &Client{path: path}
+}
+
+// Add 添加份额，ttl 为0时使用 agent 默认的有效期
+func (c *Client) Add(share *Share, ttl time.Duration) (*Info, error) {
+	resp, err := c.do(&Request{Op: OpAdd, Share: share, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Infos) != 1 {
+		return nil, fmt.Errorf("invalid response from agent")
+	}
+	return resp.Infos[0], nil
+}
+
+func (c *Client) List() ([]*Info, error) {
+	resp, err := c.do(&Request{Op: OpList})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Infos, nil
+}
+
+// Get 取出指定 id 的份额，不指定 id 时取出所有份额，份额使用后由调用者清零
+func (c *Client) Get(ids ...string) ([]*Share, error) {
+	resp, err := c.do(&Request{Op: OpGet, IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Shares, nil
+}
+
+// Refresh 重新计算份额的有效期，ttl 为0时使用添加时的有效期
+func (c *Client) Refresh(ttl time.Duration, ids ...string) ([]*Info, error) {
+	resp, err := c.do(&Request{Op: OpRefresh, IDs: ids, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Infos, nil
+}
+
+// Remove 清除指定 id 的份额，不指定 id 时清除所有份额
+func (c *Client) Remove(ids ...string) (int, error) {
+	resp, err := c.do(&Request{Op: OpRemove, IDs: ids})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Removed, nil
+}
+
+// Contribute 由 agent 将份额提交到 shamir serve 上的恢复会话，token 是会话的 submit token
+func (c *Client) Contribute(id, server, session, token string) (*recovery.Progress, error) {
+	resp, err := c.do(&Request{Op: OpContribute, IDs: []string{id}, Server: server, Session: session, Token: token})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Progress, nil
+}
+
+func (c *Client) do(req *Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", c.path, time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("connect to agent %s failed: %w", c.path, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(connTimeout))
+
+	// 请求和响应中可能有份额，编码和解码时的数据使用后清零
+	data, err := marshal(req, sharesSize(req.Share))
+	if err != nil {
+		return nil, fmt.Errorf("send request to agent failed: %w", err)
+	}
+	_, err = conn.Write(data)
+	secure.Wipe(data)
+	if err != nil {
+		return nil, fmt.Errorf("send request to agent failed: %w", err)
+	}
+	// agent 读到请求结束后才开始处理
+	if unixConn, ok := conn.(*net.UnixConn); ok {
+		_ = unixConn.CloseWrite()
+	}
+
+	buffer := &secure.Buffer{}
+	defer buffer.Reset()
+	resp := &Response{}
+	if err = readAll(io.LimitReader(conn, maxRequestSize), buffer); err == nil {
+		err = json.Unmarshal(buffer.Bytes(), resp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read response from agent failed: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, responseError(resp.Error)
+	}
+	return resp, nil
+}
+
+// responseError 还原 agent 返回的错误，便于调用者使用 errors.Is 判断
+func responseError(msg string) error {
+	for _, err := range []error{ShareNotFound, InvalidShare} {
+		if msg == err.Error() {
+			return err
+		}
+		if strings.HasSuffix(msg, ": "+err.Error()) {
+			return errors.Wrap(err, strings.TrimSuffix(msg, ": "+err.Error()))
+		}
+	}
+	return errors.New(msg)
+}
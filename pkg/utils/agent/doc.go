@@ -0,0 +1,5 @@
+// Package agent 在内存中保存持有人的份额，类似 ssh-agent
+// Keyring 将份额保存在锁定的内存中，超时后清零释放；Server 在 Unix socket 上提供服务，
+// 只接受同一用户的进程连接；Client 用于其他命令向 agent 添加、查询和使用份额。
+// 每个连接发送一行 JSON 请求 Request，收到一行 JSON 响应 Response /*
+package agent
@@ -0,0 +1,310 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/secure"
+)
+
+const (
+	defaultTTL = time.Hour
+	idLen      = 8
+	keySplit   = "_"
+)
+
+var (
+	ShareNotFound = errors.New("share not found or expired")
+	InvalidShare  = errors.New("invalid share")
+)
+
+// Share 一个持有人的份额，Necessary 可以为空。密钥使用 []byte 而不是 string，用完后可以用 Wipe 清零
+type Share struct {
+	Comment   string `json:"comment,omitempty"`
+	Necessary []byte `json:"necessary_key,omitempty"`
+	X         []byte `json:"key_x"`
+	Y         []byte `json:"key_y"`
+}
+
+// Wipe 清零份额中的密钥
+func (s *Share) Wipe() {
+	secure.Wipe(s.Necessary)
+	secure.Wipe(s.X)
+	secure.Wipe(s.Y)
+}
+
+// Info 份额的信息，不包含任何密钥
+type Info struct {
+	ID           string    `json:"id" yaml:"id"`
+	Comment      string    `json:"comment" yaml:"comment"`
+	Chunks       int       `json:"chunks" yaml:"chunks"`
+	HasNecessary bool      `json:"has_necessary_key" yaml:"has_necessary_key"`
+	ExpiresAt    time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+type entry struct {
+	id        string
+	comment   string
+	chunks    int
+	necessary *secure.LockedBuffer
+	x, y      *secure.LockedBuffer
+	ttl       time.Duration
+	expiresAt time.Time
+}
+
+func (e *entry) info() *Info {
+	return &Info{
+		ID:           e.id,
+		Comment:      e.comment,
+		Chunks:       e.chunks,
+		HasNecessary: len(e.necessary.Bytes()) > 0,
+		ExpiresAt:    e.expiresAt,
+	}
+}
+
+// share 复制一份锁定内存中的份额，由调用者使用后清零
+func (e *entry) share() *Share {
+	return &Share{
+		Comment:   e.comment,
+		Necessary: cloneBytes(e.necessary.Bytes()),
+		X:         cloneBytes(e.x.Bytes()),
+		Y:         cloneBytes(e.y.Bytes()),
+	}
+}
+
+func (e *entry) destroy() {
+	e.necessary.Destroy()
+	e.x.Destroy()
+	e.y.Destroy()
+}
+
+type Option func(k *Keyring)
+
+// WithTTL 份额默认的有效期，超时后份额被清除
+func WithTTL(ttl time.Duration) Option {
+	return func(k *Keyring) {
+		k.ttl = ttl
+	}
+}
+
+// Keyring 保存所有份额，可以并发使用
+type Keyring struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+func NewKeyring(opts ...Option) *Keyring {
+	k := &Keyring{
+		entries: map[string]*entry{},
+		ttl:     defaultTTL,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
+}
+
+// Add 添加份额，ttl 不大于0时使用默认有效期。份额的 id 由x密钥计算，重复添加同一份额会替换原来的份额。
+// 份额被复制到锁定的内存中，share 由调用者清零
+func (k *Keyring) Add(share *Share, ttl time.Duration) (*Info, error) {
+	xChunks, xErr := countChunks(share.X)
+	yChunks, yErr := countChunks(share.Y)
+	if xErr != nil || yErr != nil || xChunks != yChunks {
+		return nil, InvalidShare
+	}
+	if len(share.Necessary) != 0 {
+		chunks, err := countChunks(share.Necessary)
+		if err != nil || chunks != xChunks {
+			return nil, errors.Wrap(InvalidShare, "not match necessary key")
+		}
+	}
+	if ttl <= 0 {
+		ttl = k.ttl
+	}
+
+	e := &entry{
+		id:      shareID(share.X),
+		comment: share.Comment,
+		chunks:  xChunks,
+		ttl:     ttl,
+	}
+	var err error
+	if e.necessary, err = secure.LockBytes(share.Necessary); err == nil {
+		if e.x, err = secure.LockBytes(share.X); err == nil {
+			e.y, err = secure.LockBytes(share.Y)
+		}
+	}
+	if err != nil {
+		e.destroy()
+		return nil, errors.Wrap(err, "allocate memory failed")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if old, ok := k.entries[e.id]; ok {
+		old.destroy()
+	}
+	e.expiresAt = k.now().Add(ttl)
+	k.entries[e.id] = e
+	log.Infof("agent: share %s added", e.id)
+	return e.info(), nil
+}
+
+// List 返回所有未超时份额的信息，按添加说明和 id 排序
+func (k *Keyring) List() []*Info {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.expire()
+	return k.list()
+}
+
+func (k *Keyring) list() []*Info {
+	infos := make([]*Info, 0, len(k.entries))
+	for _, e := range k.entries {
+		infos = append(infos, e.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Comment != infos[j].Comment {
+			return infos[i].Comment < infos[j].Comment
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// Get 返回指定 id 的份额，不指定 id 时返回所有份额
+func (k *Keyring) Get(ids ...string) ([]*Share, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.expire()
+
+	if len(ids) == 0 {
+		for _, info := range k.list() {
+			ids = append(ids, info.ID)
+		}
+	}
+
+	shares := make([]*Share, 0, len(ids))
+	for _, id := range ids {
+		e, ok := k.entries[id]
+		if !ok {
+			return nil, errors.Wrap(ShareNotFound, id)
+		}
+		shares = append(shares, e.share())
+	}
+	return shares, nil
+}
+
+// Refresh 重新计算份额的有效期，ttl 不大于0时使用添加时的有效期
+func (k *Keyring) Refresh(id string, ttl time.Duration) (*Info, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.expire()
+
+	e, ok := k.entries[id]
+	if !ok {
+		return nil, errors.Wrap(ShareNotFound, id)
+	}
+	if ttl > 0 {
+		e.ttl = ttl
+	}
+	e.expiresAt = k.now().Add(e.ttl)
+	return e.info(), nil
+}
+
+// Remove 清除指定 id 的份额，不指定 id 时清除所有份额，返回清除的数量
+func (k *Keyring) Remove(ids ...string) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if len(ids) == 0 {
+		n := len(k.entries)
+		for id, e := range k.entries {
+			e.destroy()
+			delete(k.entries, id)
+		}
+		return n, nil
+	}
+
+	for _, id := range ids {
+		if _, ok := k.entries[id]; !ok {
+			return 0, errors.Wrap(ShareNotFound, id)
+		}
+	}
+	n := 0
+	for _, id := range ids {
+		if e, ok := k.entries[id]; ok {
+			e.destroy()
+			delete(k.entries, id)
+			n++
+		}
+	}
+	log.Infof("agent: %d shares removed", n)
+	return n, nil
+}
+
+// Expire 清除超时的份额，返回清除的数量
+func (k *Keyring) Expire() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.expire()
+}
+
+// Close 清除所有份额
+func (k *Keyring) Close() {
+	_, _ = k.Remove()
+}
+
+func (k *Keyring) expire() int {
+	now := k.now()
+	n := 0
+	for id, e := range k.entries {
+		if now.After(e.expiresAt) {
+			e.destroy()
+			delete(k.entries, id)
+			n++
+			log.Infof("agent: share %s expired", id)
+		}
+	}
+	return n
+}
+
+// shareID 份额的 id，x密钥单独无法还原任何信息
+func shareID(xKey []byte) string {
+	sum := sha256.Sum256(xKey)
+	return hex.EncodeToString(sum[:idLen])
+}
+
+// countChunks 返回密钥的段数，只检查每一段是否是62进制的数字，不解析成 string 或大整数，避免留下无法清零的副本
+func countChunks(key []byte) (int, error) {
+	parts := bytes.Split(key, []byte(keySplit))
+	for _, part := range parts {
+		if len(part) == 0 {
+			return 0, code.InvalidKey
+		}
+		for _, c := range part {
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+				return 0, code.InvalidKey
+			}
+		}
+	}
+	return len(parts), nil
+}
+
+func cloneBytes(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return append([]byte{}, data...)
+}
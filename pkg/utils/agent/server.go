@@ -0,0 +1,333 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"golang.org/x/sys/unix"
+
+	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/recovery"
+	"shamir/pkg/utils/secure"
+)
+
+const (
+	// 请求的大小上限，份额的大小与秘密相当
+	maxRequestSize = 8 << 20
+	connTimeout    = 30 * time.Second
+	httpTimeout    = 20 * time.Second
+)
+
+const (
+	OpAdd        = "add"
+	OpList       = "list"
+	OpGet        = "get"
+	OpRefresh    = "refresh"
+	OpRemove     = "remove"
+	OpContribute = "contribute"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// Request agent 的请求，每个连接只发送一个请求
+type Request struct {
+	Op    string        `json:"op"`
+	IDs   []string      `json:"ids,omitempty"`
+	Share *Share        `json:"share,omitempty"`
+	TTL   time.Duration `json:"ttl,omitempty"`
+	// Server、Session 和 Token 用于向 shamir serve 的恢复会话提交份额，Token 是会话的 submit token
+	Server  string `json:"server,omitempty"`
+	Session string `json:"session,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+type Response struct {
+	Error    string             `json:"error,omitempty"`
+	Infos    []*Info            `json:"infos,omitempty"`
+	Shares   []*Share           `json:"shares,omitempty"`
+	Removed  int                `json:"removed,omitempty"`
+	Progress *recovery.Progress `json:"progress,omitempty"`
+}
+
+// Listen 在 path 上创建只有当前用户可以访问的 Unix socket，
+// 上级目录不存在时以 0700 权限创建，已有 agent 在使用该 socket 时返回错误
+func Listen(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("create socket directory failed: %w", err)
+		}
+	}
+
+	if _, err := os.Lstat(path); err == nil {
+		if conn, e := net.DialTimeout("unix", path, time.Second); e == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("agent is already running on %s", path)
+		}
+		// 上次异常退出留下的 socket
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket failed: %w", err)
+		}
+	}
+
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
+
+// Server 在 Unix socket 上提供 agent 服务，实现了 taskgroup.Task
+type Server struct {
+	listener net.Listener
+	keyring  *Keyring
+	client   *http.Client
+	wg       sync.WaitGroup
+}
+
+func NewServer(listener net.Listener, keyring *Keyring) *Server {
+	return &Server{
+		listener: listener,
+		keyring:  keyring,
+		client:   &http.Client{Timeout: httpTimeout},
+	}
+}
+
+func (s *Server) Name() string {
+	return "agent"
+}
+
+// Start 阻塞式提供服务，直到 Stop 被调用
+func (s *Server) Start() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+		}()
+	}
+}
+
+// Stop 停止接受连接，等待处理中的请求结束
+func (s *Server) Stop() {
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(connTimeout))
+
+	// 只接受同一用户的进程，socket 文件的权限之外再检查一次
+	cred, err := peerCredential(conn)
+	if err != nil {
+		log.Warnf("agent: get peer credential failed: %v", err)
+		return
+	}
+	if int(cred.Uid) != os.Getuid() {
+		log.Warnf("agent: reject connection from pid %d uid %d", cred.Pid, cred.Uid)
+		writeResponse(conn, &Response{Error: "permission denied"})
+		return
+	}
+
+	// 请求中可能有份额，读取和解码时的数据使用后清零
+	data := &secure.Buffer{}
+	defer data.Reset()
+	req := &Request{}
+	if err = readAll(io.LimitReader(conn, maxRequestSize), data); err == nil {
+		err = json.Unmarshal(data.Bytes(), req)
+	}
+	if req.Share != nil {
+		defer req.Share.Wipe()
+	}
+	if err != nil {
+		writeResponse(conn, &Response{Error: "invalid request"})
+		return
+	}
+	log.Infof("agent: %s request from pid %d", req.Op, cred.Pid)
+
+	resp, err := s.handle(req)
+	if err != nil {
+		resp = &Response{Error: err.Error()}
+	}
+	writeResponse(conn, resp)
+	for _, share := range resp.Shares {
+		share.Wipe()
+	}
+}
+
+func (s *Server) handle(req *Request) (*Response, error) {
+	switch req.Op {
+	case OpAdd:
+		if req.Share == nil {
+			return nil, InvalidShare
+		}
+		info, err := s.keyring.Add(req.Share, req.TTL)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Infos: []*Info{info}}, nil
+	case OpList:
+		return &Response{Infos: s.keyring.List()}, nil
+	case OpGet:
+		shares, err := s.keyring.Get(req.IDs...)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Shares: shares}, nil
+	case OpRefresh:
+		infos := make([]*Info, 0, len(req.IDs))
+		for _, id := range req.IDs {
+			info, err := s.keyring.Refresh(id, req.TTL)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return &Response{Infos: infos}, nil
+	case OpRemove:
+		n, err := s.keyring.Remove(req.IDs...)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Removed: n}, nil
+	case OpContribute:
+		progress, err := s.contribute(req)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Progress: progress}, nil
+	default:
+		return nil, fmt.Errorf("unknown op %q", req.Op)
+	}
+}
+
+// contribute 将份额提交到 shamir serve 的恢复会话，份额不经过发起请求的进程
+func (s *Server) contribute(req *Request) (*recovery.Progress, error) {
+	if len(req.IDs) != 1 || req.Server == "" || req.Session == "" {
+		return nil, fmt.Errorf("contribute needs one share id, the server and the session")
+	}
+	shares, err := s.keyring.Get(req.IDs[0])
+	if err != nil {
+		return nil, err
+	}
+	defer shares[0].Wipe()
+
+	// 与 code.StrKey 的格式相同，密钥在添加时已检查只包含62进制的字符和分隔符，不需要转义，也不必转换成 string
+	body := &secure.Buffer{}
+	defer body.Reset()
+	_, _ = body.Write([]byte(`{"key_x":"`))
+	_, _ = body.Write(shares[0].X)
+	_, _ = body.Write([]byte(`","key_y":"`))
+	_, _ = body.Write(shares[0].Y)
+	_, _ = body.Write([]byte(`"}`))
+
+	target := strings.TrimSuffix(req.Server, "/") + "/v1/sessions/" + url.PathEscape(req.Session) + "/shares"
+	httpReq, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+req.Token)
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("submit share failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		result := map[string]string{}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, maxRequestSize)).Decode(&result)
+		return nil, fmt.Errorf("submit share failed: %s: %s", resp.Status, result["error"])
+	}
+	progress := &recovery.Progress{}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxRequestSize)).Decode(progress); err != nil {
+		return nil, fmt.Errorf("invalid response from server: %w", err)
+	}
+	log.Infof("agent: share %s contributed to session %s", req.IDs[0], req.Session)
+	return progress, nil
+}
+
+// writeResponse 写入响应，编码后的数据写入后清零
+func writeResponse(w io.Writer, resp *Response) {
+	data, err := marshal(resp, sharesSize(resp.Shares...))
+	if err != nil {
+		log.Errorf("agent: write response failed: %v", err)
+		return
+	}
+	defer secure.Wipe(data)
+	if _, err = w.Write(data); err != nil {
+		log.Errorf("agent: write response failed: %v", err)
+	}
+}
+
+// marshal 编码请求或响应，预先分配 size 大小的缓冲，足够大时编码过程中不会扩容留下无法清零的副本，
+// 返回的数据由调用者清零
+func marshal(v interface{}, size int) ([]byte, error) {
+	stream := jsoniter.NewStream(json, nil, size)
+	stream.WriteVal(v)
+	stream.WriteRaw("\n")
+	if stream.Error != nil {
+		return nil, stream.Error
+	}
+	return stream.Buffer(), nil
+}
+
+// sharesSize 估计份额编码后的大小，密钥使用 base64 编码
+func sharesSize(shares ...*Share) int {
+	size := 1024
+	for _, share := range shares {
+		if share != nil {
+			size += 2*(len(share.Necessary)+len(share.X)+len(share.Y)) + len(share.Comment) + 256
+		}
+	}
+	return size
+}
+
+// readAll 读取全部数据到 buffer 中，扩容和中转时的数据都会被清零，buffer 由调用者清零
+func readAll(r io.Reader, buffer *secure.Buffer) error {
+	chunk := make([]byte, 32<<10)
+	defer secure.Wipe(chunk)
+	_, err := io.CopyBuffer(buffer, r, chunk)
+	return err
+}
+
+// peerCredential 通过 SO_PEERCRED 获取连接对端进程的 pid 和 uid
+func peerCredential(conn net.Conn) (*unix.Ucred, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("not a unix socket connection")
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, credErr
+}
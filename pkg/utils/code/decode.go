@@ -9,6 +9,7 @@ import (
 	"math/big"
 
 	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/secure"
 )
 
 const (
@@ -76,10 +77,12 @@ func (s *SecretDecoder) Write(data *big.Int) error {
 		return fmt.Errorf("invalid data bit integer")
 	}
 
-	// 每次写上次的数据，防止将最后一个hash校验的数据误写入
+	// 每次写上次的数据，防止将最后一个hash校验的数据误写入，写入后清零
 	if s.last != nil {
 		writeData := getSecretBytes(s.last)
 		n, err := s.writer.Write(writeData)
+		secure.Wipe(writeData)
+		secure.WipeInt(s.last)
 		if err != nil {
 			return fmt.Errorf("write data failed: %w", err)
 		}
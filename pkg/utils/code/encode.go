@@ -12,10 +12,12 @@ import (
 	"github.com/pkg/errors"
 
 	"shamir/pkg/utils/compute"
+	"shamir/pkg/utils/secure"
 )
 
 const (
-	bytePrefix = 0xff
+	// SecretPrefix 秘密编码时加上的前缀，避免开头的零字节丢失
+	SecretPrefix = 0xff
 	// 目前最大分割的子秘密长1024，其生成的key不会超过2048长度
 	maxKeyLen = 2048
 )
@@ -27,7 +29,7 @@ var (
 // EncodeSecret 将字符串秘密编码成为大整数，方便加密
 func EncodeSecret(secret string) *big.Int {
 	// 所有的秘密都加上 0xff前缀, 避免全零数据丢失真实数据
-	return new(big.Int).SetBytes(append([]byte{bytePrefix}, secret...))
+	return new(big.Int).SetBytes(append([]byte{SecretPrefix}, secret...))
 }
 
 func EncodeCompoundSecret(secret string, splitLen int) []*big.Int {
@@ -71,9 +73,13 @@ func NewSecretEncoder(reader io.Reader, splitLen int) *SecretEncoder {
 	}
 }
 
+// Read 返回下一段秘密，读取时使用的缓冲会被清零，返回的秘密由调用者使用 secure.WipeInt 清零
 func (s *SecretEncoder) Read() (*big.Int, error) {
-	data := make([]byte, s.splitLen)
-	n, err := s.reader.Read(data)
+	// 第一个字节是前缀，避免再复制一次秘密
+	data := make([]byte, s.splitLen+1)
+	defer secure.Wipe(data)
+	data[0] = SecretPrefix
+	n, err := s.reader.Read(data[1:])
 	if err != nil && !errors.Is(err, io.EOF) {
 		return nil, fmt.Errorf("read secret file failed: %w", err)
 	}
@@ -82,7 +88,7 @@ func (s *SecretEncoder) Read() (*big.Int, error) {
 		return nil, nil
 	}
 
-	nHash, err := s.hash.Write(data[:n])
+	nHash, err := s.hash.Write(data[1 : n+1])
 	if err != nil {
 		return nil, fmt.Errorf("secret hash check failed: %w", err)
 	}
@@ -90,7 +96,7 @@ func (s *SecretEncoder) Read() (*big.Int, error) {
 		return nil, fmt.Errorf("secret hash check failed, hash write expected %d bytes, actual %d bytes", n, nHash)
 	}
 
-	return new(big.Int).SetBytes(append([]byte{bytePrefix}, data[:n]...)), nil
+	return new(big.Int).SetBytes(data[:n+1]), nil
 }
 
 func (s *SecretEncoder) GetHash() *big.Int {
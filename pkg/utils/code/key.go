@@ -2,6 +2,7 @@ package code
 
 import (
 	"fmt"
+	"io"
 	"math/big"
 )
 
@@ -81,3 +82,60 @@ func EncodeStrCompoundKeys(keys []*StrKey) ([]CompoundKey, error) {
 	}
 	return result, nil
 }
+
+// XYKeyDecoder 将密钥对的x、y分别写成两条密钥链
+type XYKeyDecoder struct {
+	x *KeyDecoder
+	y *KeyDecoder
+}
+
+func NewXYKeyDecoder(x, y io.Writer) *XYKeyDecoder {
+	return &XYKeyDecoder{
+		x: NewKeyDecoder(x),
+		y: NewKeyDecoder(y),
+	}
+}
+
+func (xy *XYKeyDecoder) Write(key Key) error {
+	if key.X == nil || key.Y == nil {
+		return fmt.Errorf("%w, nil point of x or y", InvalidKey)
+	}
+
+	if err := xy.x.Write(key.X); err != nil {
+		return err
+	}
+	return xy.y.Write(key.Y)
+}
+
+// XYKeyEncoder 从x、y两条密钥链中逐段读取密钥对
+type XYKeyEncoder struct {
+	x *KeyEncoder
+	y *KeyEncoder
+}
+
+func NewXYKeyEncoder(x, y io.Reader) *XYKeyEncoder {
+	return &XYKeyEncoder{
+		x: NewKeyEncoder(x),
+		y: NewKeyEncoder(y),
+	}
+}
+
+// Read 返回密钥对，密钥类型(是否是hash值的密钥)
+func (xy *XYKeyEncoder) Read() (Key, bool, error) {
+	key := Key{}
+	x, xOk, xErr := xy.x.Read()
+	y, yOk, yErr := xy.y.Read()
+	if xErr != nil {
+		return key, false, fmt.Errorf("read x key failed: %w", xErr)
+	}
+	if yErr != nil {
+		return key, false, fmt.Errorf("read y key failed: %w", yErr)
+	}
+
+	if xOk != yOk {
+		return key, false, fmt.Errorf("x key not match y key")
+	}
+
+	key.X, key.Y = x, y
+	return key, xOk, nil
+}
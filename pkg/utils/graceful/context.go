@@ -0,0 +1,124 @@
+package graceful
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// SignalError 任务被信号中断，按照 shell 的惯例退出码为 128+信号值
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return fmt.Sprintf("interrupted by signal %s", e.Signal)
+}
+
+func (e *SignalError) ExitCode() int {
+	if s, ok := e.Signal.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
+// SignalContext 收到信号时取消的 context，用于中断任务并回滚。
+// 只处理第一个信号，之后恢复信号的默认行为，任务卡住时再次发送信号可以直接结束进程
+type SignalContext struct {
+	context.Context
+
+	cancel context.CancelFunc
+	quit   chan os.Signal
+	once   sync.Once
+
+	mu  sync.Mutex
+	err *SignalError
+}
+
+func NewSignalContext(parent context.Context, signals ...os.Signal) *SignalContext {
+	ctx, cancel := context.WithCancel(parent)
+	c := &SignalContext{
+		Context: ctx,
+		cancel:  cancel,
+		// signal.Notify 不会阻塞发送，需要有缓冲避免丢失信号
+		quit: make(chan os.Signal, 1),
+	}
+
+	signal.Notify(c.quit, signals...)
+	go c.listen()
+	return c
+}
+
+func (c *SignalContext) listen() {
+	select {
+	case s := <-c.quit:
+		c.mu.Lock()
+		c.err = &SignalError{Signal: s}
+		c.mu.Unlock()
+		c.Stop()
+	case <-c.Done():
+	}
+}
+
+// Cause 收到信号后返回 *SignalError，否则返回 context 的错误
+func (c *SignalContext) Cause() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return c.Err()
+}
+
+// Stop 停止监听信号并取消 context，任务结束后需要调用
+func (c *SignalContext) Stop() {
+	c.once.Do(func() {
+		signal.Stop(c.quit)
+		c.cancel()
+	})
+}
+
+// Reader 返回的读取在 context 取消后立即返回 Cause，阻塞在管道或终端上的读取也会被打断。
+// 被打断的读取仍在后台进行，之后不能再从 reader 读取
+func (c *SignalContext) Reader(reader io.Reader) io.Reader {
+	return &contextReader{ctx: c, reader: reader}
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+type contextReader struct {
+	ctx    *SignalContext
+	reader io.Reader
+	// buffer 后台读取使用自己的缓冲，被打断后不会再写入调用者的数据
+	buffer []byte
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, r.ctx.Cause()
+	}
+	if len(p) > len(r.buffer) {
+		r.buffer = make([]byte, len(p))
+	}
+
+	done := make(chan readResult, 1)
+	buffer := r.buffer[:len(p)]
+	go func() {
+		n, err := r.reader.Read(buffer)
+		done <- readResult{n: n, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return copy(p, buffer[:result.n]), result.err
+	case <-r.ctx.Done():
+		return 0, r.ctx.Cause()
+	}
+}
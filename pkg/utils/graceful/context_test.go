@@ -0,0 +1,53 @@
+package graceful
+
+import (
+	"context"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalContext(t *testing.T) {
+	ctx := NewSignalContext(context.Background(), syscall.SIGUSR1)
+	defer ctx.Stop()
+
+	// 阻塞在管道上的读取被信号打断
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	}()
+	_, err := ctx.Reader(reader).Read(make([]byte, 8))
+
+	var signalErr *SignalError
+	require.ErrorAs(t, err, &signalErr)
+	assert.Equal(t, 128+int(syscall.SIGUSR1), signalErr.ExitCode())
+	assert.ErrorIs(t, ctx.Cause(), signalErr)
+}
+
+func TestSignalContextStop(t *testing.T) {
+	ctx := NewSignalContext(context.Background(), syscall.SIGUSR2)
+	data, err := io.ReadAll(ctx.Reader(io.LimitReader(zeroReader{}, 16)))
+	require.NoError(t, err)
+	assert.Len(t, data, 16)
+
+	ctx.Stop()
+	assert.ErrorIs(t, ctx.Cause(), context.Canceled)
+	_, err = ctx.Reader(zeroReader{}).Read(make([]byte, 8))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -40,7 +40,8 @@ func (g *Graceful) listen() {
 		signals = append(signals, s)
 	}
 
-	quit := make(chan os.Signal)
+	// signal.Notify 不会阻塞发送，需要有缓冲避免丢失信号
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, signals...)
 	s := <-quit
 	handler, ok := g.handlers[s]
@@ -51,6 +52,11 @@ func (g *Graceful) listen() {
 	close(g.finish)
 }
 
+// Done 收到信号并执行完处理函数后关闭，用于与其他事件一起等待
+func (g *Graceful) Done() <-chan struct{} {
+	return g.finish
+}
+
 // Wait 创建完成graceful模块后，需要调用 Wait 方法阻塞式等待
 func (g *Graceful) Wait() {
 	<-g.finish
@@ -36,28 +36,28 @@ type Logger interface {
 // Debug uses fmt.Sprint to construct and log a message.
 func Debug(args ...interface{}) {
 	if logger != nil {
-		logger.Debug(args)
+		logger.Debug(args...)
 	}
 }
 
 // Info uses fmt.Sprint to construct and log a message.
 func Info(args ...interface{}) {
 	if logger != nil {
-		logger.Info(args)
+		logger.Info(args...)
 	}
 }
 
 // Warn uses fmt.Sprint to construct and log a message.
 func Warn(args ...interface{}) {
 	if logger != nil {
-		logger.Warn(args)
+		logger.Warn(args...)
 	}
 }
 
 // Error uses fmt.Sprint to construct and log a message.
 func Error(args ...interface{}) {
 	if logger != nil {
-		logger.Error(args)
+		logger.Error(args...)
 	}
 }
 
@@ -65,42 +65,42 @@ func Error(args ...interface{}) {
 // logger then panics
 func DPanic(args ...interface{}) {
 	if logger != nil {
-		logger.DPanic(args)
+		logger.DPanic(args...)
 	}
 }
 
 // Panic uses fmt.Sprint to construct and log a message, then panics.
 func Panic(args ...interface{}) {
 	if logger != nil {
-		logger.Panic(args)
+		logger.Panic(args...)
 	}
 }
 
 // Debugf uses fmt.Sprintf to log a templated message.
 func Debugf(template string, args ...interface{}) {
 	if logger != nil {
-		logger.Debugf(template, args)
+		logger.Debugf(template, args...)
 	}
 }
 
 // Infof uses fmt.Sprintf to log a templated message.
 func Infof(template string, args ...interface{}) {
 	if logger != nil {
-		logger.Infof(template, args)
+		logger.Infof(template, args...)
 	}
 }
 
 // Warnf uses fmt.Sprintf to log a templated message.
 func Warnf(template string, args ...interface{}) {
 	if logger != nil {
-		logger.Warnf(template, args)
+		logger.Warnf(template, args...)
 	}
 }
 
 // Errorf uses fmt.Sprintf to log a templated message.
 func Errorf(template string, args ...interface{}) {
 	if logger != nil {
-		logger.Errorf(template, args)
+		logger.Errorf(template, args...)
 	}
 }
 
@@ -108,13 +108,13 @@ func Errorf(template string, args ...interface{}) {
 // logger then panics
 func DPanicf(template string, args ...interface{}) {
 	if logger != nil {
-		logger.DPanicf(template, args)
+		logger.DPanicf(template, args...)
 	}
 }
 
 // Panicf uses fmt.Sprintf to log a templated message, then panics.
 func Panicf(template string, args ...interface{}) {
 	if logger != nil {
-		logger.Panicf(template, args)
+		logger.Panicf(template, args...)
 	}
 }
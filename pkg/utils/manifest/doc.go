@@ -0,0 +1,4 @@
+// Package manifest 拆分秘密时生成的清单 manifest.json
+// 记录门限值、份额个数、分段长度、生成时间和工具版本，每个份额文件的指纹、持有人的标签，
+// 以及对秘密的承诺，解密和校验时用来识别属于同一次拆分的文件并确认还原出的秘密
+package manifest
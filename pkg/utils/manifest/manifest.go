@@ -0,0 +1,220 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+
+	"shamir/pkg/utils/shamir"
+)
+
+const (
+	// Version 清单格式的版本
+	Version = 1
+	// ContentTar 秘密是由多个文件或目录打包成的 tar 归档
+	ContentTar = "tar"
+	// CommitmentScrypt 对秘密的 SHA-256 值使用 scrypt 加盐计算承诺，增加猜测低熵秘密的代价
+	CommitmentScrypt = "scrypt-sha256"
+
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLen      = 16
+	// maxScryptN 限制清单中的 scrypt 参数，避免构造的清单耗尽内存
+	maxScryptN = 1 << 20
+	// maxSize 清单只包含摘要，限制读取的大小
+	maxSize = 1 << 20
+)
+
+var (
+	InvalidManifest  = errors.New("invalid manifest")
+	CommitmentFailed = errors.New("secret does not match the commitment in manifest")
+)
+
+// Manifest 一次拆分的清单，摘要均为小写十六进制
+type Manifest struct {
+	Version   int    `json:"version"`
+	Tool      string `json:"tool"`
+	FastPrime bool   `json:"fast_prime"`
+	Threshold int    `json:"threshold"`
+	Number    int    `json:"number"`
+	// ChunkSize 秘密每段的字节数
+	ChunkSize int `json:"chunk_size"`
+	// Content 秘密的内容类型，为空时是单个文件或字符串
+	Content   string    `json:"content,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Necessary 必须密钥文件的 SHA-256 值
+	Necessary  string     `json:"necessary_sha256"`
+	Shares     []Share    `json:"shares"`
+	Commitment Commitment `json:"commitment"`
+}
+
+// Share 一个持有人的份额，X、Y 是密钥文件原始内容(份额被加密时为密文)的 SHA-256 值
+type Share struct {
+	Holder      int    `json:"holder"`
+	Label       string `json:"label,omitempty"`
+	X           string `json:"x_sha256"`
+	Y           string `json:"y_sha256"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Commitment 对秘密的承诺，Value = scrypt(SHA-256(secret), Salt, N, R, P)
+type Commitment struct {
+	Algorithm string `json:"algorithm"`
+	Salt      string `json:"salt"`
+	N         int    `json:"n"`
+	R         int    `json:"r"`
+	P         int    `json:"p"`
+	Value     string `json:"value"`
+}
+
+// Fingerprint 份额的指纹，由x、y密钥文件的 SHA-256 值计算
+func Fingerprint(x, y []byte) string {
+	h := sha256.New()
+	h.Write(x)
+	h.Write(y)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewShare 由x、y密钥文件的 SHA-256 值生成份额的记录
+func NewShare(holder int, label string, x, y []byte) Share {
+	return Share{
+		Holder:      holder,
+		Label:       label,
+		X:           hex.EncodeToString(x),
+		Y:           hex.EncodeToString(y),
+		Fingerprint: Fingerprint(x, y),
+	}
+}
+
+// NewCommitment 使用随机的盐对秘密的 SHA-256 值 digest 计算承诺
+func NewCommitment(digest []byte) (Commitment, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return Commitment{}, err
+	}
+	value, err := scrypt.Key(digest, salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return Commitment{}, err
+	}
+
+	return Commitment{
+		Algorithm: CommitmentScrypt,
+		Salt:      hex.EncodeToString(salt),
+		N:         scryptN,
+		R:         scryptR,
+		P:         scryptP,
+		Value:     hex.EncodeToString(value),
+	}, nil
+}
+
+// Verify 校验秘密的 SHA-256 值 digest，不一致时返回 CommitmentFailed
+func (c Commitment) Verify(digest []byte) error {
+	if c.Algorithm != CommitmentScrypt {
+		return fmt.Errorf("%w: unsupported commitment algorithm %q", InvalidManifest, c.Algorithm)
+	}
+	if c.N > maxScryptN || c.R > scryptR || c.P > scryptP {
+		return fmt.Errorf("%w: commitment parameters too large", InvalidManifest)
+	}
+	salt, err := hex.DecodeString(c.Salt)
+	if err != nil {
+		return fmt.Errorf("%w: invalid commitment salt", InvalidManifest)
+	}
+	want, err := hex.DecodeString(c.Value)
+	if err != nil || len(want) == 0 {
+		return fmt.Errorf("%w: invalid commitment value", InvalidManifest)
+	}
+
+	value, err := scrypt.Key(digest, salt, c.N, c.R, c.P, len(want))
+	if err != nil {
+		return fmt.Errorf("%w: %v", InvalidManifest, err)
+	}
+	if subtle.ConstantTimeCompare(value, want) != 1 {
+		return CommitmentFailed
+	}
+	return nil
+}
+
+// Share 返回指纹对应的份额，不在清单中时返回nil
+func (m *Manifest) Share(fingerprint string) *Share {
+	for i := range m.Shares {
+		if m.Shares[i].Fingerprint == fingerprint {
+			return &m.Shares[i]
+		}
+	}
+	return nil
+}
+
+// Label 返回持有人的标签，没有标签时为空
+func (m *Manifest) Label(holder int) string {
+	for _, share := range m.Shares {
+		if share.Holder == holder {
+			return share.Label
+		}
+	}
+	return ""
+}
+
+// CheckNecessary 校验必须密钥文件的 SHA-256 值
+func (m *Manifest) CheckNecessary(sum []byte) error {
+	if m.Necessary != hex.EncodeToString(sum) {
+		return fmt.Errorf("necessary key not match manifest")
+	}
+	return nil
+}
+
+func (m *Manifest) Marshal() ([]byte, error) {
+	data, err := jsoniter.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Load 读取并检查清单
+func Load(reader io.Reader) (*Manifest, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", InvalidManifest, maxSize)
+	}
+
+	m := &Manifest{}
+	if err = jsoniter.NewDecoder(bytes.NewReader(data)).Decode(m); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidManifest, err)
+	}
+	if err = m.check(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Manifest) check() error {
+	if m.Version != Version {
+		return fmt.Errorf("%w: unsupported version %d", InvalidManifest, m.Version)
+	}
+	if m.Threshold < shamir.MinThreshold || m.Threshold > m.Number {
+		return fmt.Errorf("%w: threshold %d of %d shares", InvalidManifest, m.Threshold, m.Number)
+	}
+	if len(m.Shares) != m.Number {
+		return fmt.Errorf("%w: has %d shares but number is %d", InvalidManifest, len(m.Shares), m.Number)
+	}
+	for _, share := range m.Shares {
+		if share.Fingerprint == "" {
+			return fmt.Errorf("%w: share of holder %d has no fingerprint", InvalidManifest, share.Holder)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newManifest(t *testing.T, secret string) *Manifest {
+	digest := sha256.Sum256([]byte(secret))
+	commitment, err := NewCommitment(digest[:])
+	require.NoError(t, err)
+
+	necessary := sha256.Sum256([]byte("necessary"))
+	m := &Manifest{
+		Version:    Version,
+		Tool:       "shamir test",
+		FastPrime:  true,
+		Threshold:  2,
+		Number:     3,
+		ChunkSize:  60,
+		CreatedAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Necessary:  hex.EncodeToString(necessary[:]),
+		Commitment: commitment,
+	}
+	for i, label := range []string{"alice", "", "carol"} {
+		x := sha256.Sum256([]byte{'x', byte(i)})
+		y := sha256.Sum256([]byte{'y', byte(i)})
+		m.Shares = append(m.Shares, NewShare(i, label, x[:], y[:]))
+	}
+	return m
+}
+
+func TestManifest(t *testing.T) {
+	m := newManifest(t, "secret")
+	data, err := m.Marshal()
+	require.NoError(t, err)
+
+	loaded, err := Load(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, m, loaded)
+
+	x := sha256.Sum256([]byte{'x', 2})
+	y := sha256.Sum256([]byte{'y', 2})
+	share := loaded.Share(Fingerprint(x[:], y[:]))
+	require.NotNil(t, share)
+	assert.Equal(t, 2, share.Holder)
+	assert.Equal(t, "carol", loaded.Label(2))
+	assert.Empty(t, loaded.Label(1))
+	assert.Nil(t, loaded.Share(Fingerprint(y[:], x[:])))
+
+	necessary := sha256.Sum256([]byte("necessary"))
+	assert.NoError(t, loaded.CheckNecessary(necessary[:]))
+	assert.Error(t, loaded.CheckNecessary(x[:]))
+}
+
+func TestCommitment(t *testing.T) {
+	m := newManifest(t, "secret")
+	digest := sha256.Sum256([]byte("secret"))
+	assert.NoError(t, m.Commitment.Verify(digest[:]))
+
+	other := sha256.Sum256([]byte("other"))
+	assert.ErrorIs(t, m.Commitment.Verify(other[:]), CommitmentFailed)
+
+	// 同一个秘密每次的盐不同
+	again := newManifest(t, "secret")
+	assert.NotEqual(t, m.Commitment.Value, again.Commitment.Value)
+
+	m.Commitment.N = 1 << 30
+	assert.ErrorIs(t, m.Commitment.Verify(digest[:]), InvalidManifest)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, modify := range map[string]func(m *Manifest){
+		"version":   func(m *Manifest) { m.Version = 2 },
+		"threshold": func(m *Manifest) { m.Threshold = 4 },
+		"shares":    func(m *Manifest) { m.Shares = m.Shares[:2] },
+		"fingerprint": func(m *Manifest) {
+			m.Shares[0].Fingerprint = ""
+		},
+	} {
+		m := newManifest(t, "secret")
+		modify(m)
+		data, err := m.Marshal()
+		require.NoError(t, err)
+		_, err = Load(bytes.NewReader(data))
+		assert.ErrorIs(t, err, InvalidManifest, name)
+	}
+
+	_, err := Load(bytes.NewBufferString("not json"))
+	assert.ErrorIs(t, err, InvalidManifest)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -12,6 +13,12 @@ const (
 	NecessaryFileName = KeyFilePrefix + "necessary-key"
 	XKeyFilePrefix    = KeyFilePrefix + "x-key_"
 	YKeyFilePrefix    = KeyFilePrefix + "y-key_"
+	// SsssShareFilePrefix ssss 兼容方案的份额文件前缀
+	SsssShareFilePrefix = KeyFilePrefix + "ssss-share_"
+	// SignatureFilePrefix 发牌人对份额签名的文件前缀
+	SignatureFilePrefix = KeyFilePrefix + "signature_"
+	// ManifestFileName 拆分时生成的清单
+	ManifestFileName = "manifest.json"
 )
 
 // IsExist 返回路径是否存在
@@ -55,12 +62,6 @@ func GetAllKeyFile(path string) ([]string, error) {
 	return result, nil
 }
 
-// IsNecessaryKeyExist 传入path是目录地址，判断该目录下是否存在necessary key
-func IsNecessaryKeyExist(path string) bool {
-	path = filepath.Join(filepath.Clean(path), NecessaryFileName)
-	return IsExist(path)
-}
-
 func CheckNoKey(path string) error {
 	files, err := GetAllKeyFile(path)
 	if err != nil {
@@ -77,51 +78,16 @@ func CheckNoKey(path string) error {
 	return nil
 }
 
-type KeyName struct {
-	XKey string
-	YKey string
-}
-
-// GetKeysName 从指定目录获取存在的密钥对的文件名，和必须密钥的文件名
-func GetKeysName(path string) ([]*KeyName, string, error) {
-	path = filepath.Clean(path)
-	if !IsExist(path) {
-		return nil, "", fmt.Errorf("path %q not exist", path)
-	}
-
-	if !IsNecessaryKeyExist(path) {
-		return nil, "", fmt.Errorf("necessary key not exist")
-	}
-
-	names, err := GetAllKeyFile(path)
-	if err != nil {
-		return nil, "", err
-	}
-	namesMap := make(map[string]struct{}, len(names))
-	for _, file := range names {
-		namesMap[file] = struct{}{}
+// LessHolder 比较两个持有人的顺序，数字序号的持有人排在前面并按数值比较，其余按字符串比较
+func LessHolder(a, b string) bool {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return an < bn
+	case aErr == nil || bErr == nil:
+		return aErr == nil
+	default:
+		return a < b
 	}
-
-	// 找到文件夹中的密钥对，密钥对的前缀分别是x和y相关前缀，后缀一致
-	var keys []*KeyName
-	for file := range namesMap {
-		if !strings.HasPrefix(file, XKeyFilePrefix) {
-			continue
-		}
-
-		suffix := strings.TrimPrefix(file, XKeyFilePrefix)
-		if _, ok := namesMap[YKeyFilePrefix+suffix]; !ok {
-			continue
-		}
-		keys = append(keys, &KeyName{
-			XKey: XKeyFilePrefix + suffix,
-			YKey: YKeyFilePrefix + suffix,
-		})
-	}
-
-	if len(keys) == 0 {
-		return nil, "", fmt.Errorf("path %q can not found key", path)
-	}
-
-	return keys, NecessaryFileName, nil
 }
@@ -0,0 +1,4 @@
+// Package progress 输出加密、解密大文件时的进度
+// Reporter 统计处理的字节数和段数，定时输出速率和预计剩余时间，
+// 终端中使用 Text 格式在同一行刷新，JSON 格式每行输出一个 Stat 供其他程序解析 /*
+package progress
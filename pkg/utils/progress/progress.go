@@ -0,0 +1,213 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"shamir/pkg/utils/compute"
+)
+
+// Format 进度的输出格式
+type Format string
+
+const (
+	// Text 在同一行刷新的文本，用于终端
+	Text Format = "text"
+	// JSON 每行一个 Stat，用于其他程序解析
+	JSON Format = "json"
+
+	defaultInterval = 500 * time.Millisecond
+)
+
+// Stat 某一时刻的进度，总量未知时为0
+type Stat struct {
+	Operation   string  `json:"operation"`
+	Bytes       int64   `json:"bytes"`
+	TotalBytes  int64   `json:"total_bytes,omitempty"`
+	Chunks      int64   `json:"chunks"`
+	TotalChunks int64   `json:"total_chunks,omitempty"`
+	Rate        float64 `json:"bytes_per_second"`
+	Elapsed     float64 `json:"elapsed_seconds"`
+	// ETA 预计剩余的秒数，总量未知时为-1
+	ETA  float64 `json:"eta_seconds"`
+	Done bool    `json:"done"`
+}
+
+type Option func(r *Reporter)
+
+// WithTotalBytes 处理的总字节数，如加密时输入文件的大小
+func WithTotalBytes(total int64) Option {
+	return func(r *Reporter) {
+		r.totalBytes = total
+	}
+}
+
+// WithTotalChunks 处理的总段数，如解密时必须密钥的段数
+func WithTotalChunks(total int64) Option {
+	return func(r *Reporter) {
+		r.totalChunks = total
+	}
+}
+
+func WithInterval(interval time.Duration) Option {
+	return func(r *Reporter) {
+		r.interval = interval
+	}
+}
+
+// Reporter 定时将进度输出到 writer，计数可以在任意 goroutine 中增加
+type Reporter struct {
+	operation string
+	format    Format
+	writer    io.Writer
+	interval  time.Duration
+
+	totalBytes, totalChunks int64
+	bytes, chunks           atomic.Int64
+
+	start time.Time
+	now   func() time.Time
+	// printed 文本格式下已经输出过进度行，结束时需要换行
+	printed bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+func NewReporter(operation string, format Format, writer io.Writer, opts ...Option) *Reporter {
+	r := &Reporter{
+		operation: operation,
+		format:    format,
+		writer:    writer,
+		interval:  defaultInterval,
+		now:       time.Now,
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Start 开始定时输出进度
+func (r *Reporter) Start() {
+	r.start = r.now()
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.print(r.Stat(false))
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Finish 停止定时输出，成功时输出最终的进度，文本格式下换行结束进度行
+func (r *Reporter) Finish(success bool) {
+	r.once.Do(func() {
+		close(r.stop)
+		r.wg.Wait()
+		if success {
+			r.print(r.Stat(true))
+		}
+		if r.format == Text && r.printed {
+			_, _ = fmt.Fprintln(r.writer)
+		}
+	})
+}
+
+func (r *Reporter) AddBytes(n int) {
+	r.bytes.Add(int64(n))
+}
+
+func (r *Reporter) AddChunk() {
+	r.chunks.Add(1)
+}
+
+func (r *Reporter) Stat(done bool) Stat {
+	stat := Stat{
+		Operation:   r.operation,
+		Bytes:       r.bytes.Load(),
+		TotalBytes:  r.totalBytes,
+		Chunks:      r.chunks.Load(),
+		TotalChunks: r.totalChunks,
+		Elapsed:     r.now().Sub(r.start).Seconds(),
+		ETA:         -1,
+		Done:        done,
+	}
+	if stat.Elapsed > 0 {
+		stat.Rate = float64(stat.Bytes) / stat.Elapsed
+	}
+
+	// 按已处理的比例估算剩余时间，实际处理的量超过总量时为0
+	switch {
+	case done:
+		stat.ETA = 0
+	case stat.TotalBytes > 0 && stat.Bytes > 0:
+		stat.ETA = math.Max(0, float64(stat.TotalBytes-stat.Bytes)/float64(stat.Bytes)*stat.Elapsed)
+	case stat.TotalChunks > 0 && stat.Chunks > 0:
+		stat.ETA = math.Max(0, float64(stat.TotalChunks-stat.Chunks)/float64(stat.Chunks)*stat.Elapsed)
+	}
+	return stat
+}
+
+func (r *Reporter) print(stat Stat) {
+	if r.format == JSON {
+		data, err := jsoniter.Marshal(stat)
+		if err != nil {
+			return
+		}
+		_, _ = r.writer.Write(append(data, '\n'))
+		return
+	}
+
+	// 清除行尾，避免较短的一行留下上一行的字符
+	_, _ = fmt.Fprintf(r.writer, "\r%s\033[K", stat.String())
+	r.printed = true
+}
+
+func (s Stat) String() string {
+	bytes := FormatBytes(s.Bytes)
+	if s.TotalBytes > 0 {
+		bytes = fmt.Sprintf("%s / %s (%d%%)", bytes, FormatBytes(s.TotalBytes), s.Bytes*100/s.TotalBytes)
+	}
+	chunks := fmt.Sprintf("%d chunks", s.Chunks)
+	if s.TotalChunks > 0 {
+		chunks = fmt.Sprintf("%d / %d chunks (%d%%)", s.Chunks, s.TotalChunks, s.Chunks*100/s.TotalChunks)
+	}
+
+	eta := "ETA --"
+	switch {
+	case s.Done:
+		eta = fmt.Sprintf("done in %s", time.Duration(s.Elapsed*float64(time.Second)).Round(time.Second))
+	case s.ETA >= 0:
+		eta = fmt.Sprintf("ETA %s", time.Duration(s.ETA*float64(time.Second)).Round(time.Second))
+	}
+	return fmt.Sprintf("%s: %s, %s, %s/s, %s", s.Operation, bytes, chunks, FormatBytes(int64(s.Rate)), eta)
+}
+
+// FormatBytes 以 1024 为进制输出字节数
+func FormatBytes(n int64) string {
+	switch {
+	case n >= compute.UnitM*compute.UnitK:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(compute.UnitM*compute.UnitK))
+	case n >= compute.UnitM:
+		return fmt.Sprintf("%.1f MiB", float64(n)/compute.UnitM)
+	case n >= compute.UnitK:
+		return fmt.Sprintf("%.1f KiB", float64(n)/compute.UnitK)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,69 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"shamir/pkg/utils/compute"
+)
+
+func TestStat(t *testing.T) {
+	now := time.Now()
+	r := NewReporter("encrypt", Text, &bytes.Buffer{}, WithTotalBytes(4*compute.UnitM))
+	r.now = func() time.Time { return now }
+	r.start = now
+
+	stat := r.Stat(false)
+	assert.Equal(t, float64(-1), stat.ETA)
+
+	now = now.Add(2 * time.Second)
+	r.AddBytes(compute.UnitM)
+	r.AddChunk()
+	stat = r.Stat(false)
+	assert.Equal(t, float64(compute.UnitM/2), stat.Rate)
+	assert.Equal(t, float64(6), stat.ETA)
+	assert.Equal(t, "encrypt: 1.0 MiB / 4.0 MiB (25%), 1 chunks, 512.0 KiB/s, ETA 6s", stat.String())
+
+	// 按段数估算
+	r = NewReporter("decrypt", Text, &bytes.Buffer{}, WithTotalChunks(10))
+	r.now = func() time.Time { return now }
+	r.start = now.Add(-time.Second)
+	for i := 0; i < 5; i++ {
+		r.AddChunk()
+	}
+	assert.Equal(t, float64(1), r.Stat(false).ETA)
+	assert.Equal(t, float64(0), r.Stat(true).ETA)
+}
+
+func TestReporterJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := NewReporter("decrypt", JSON, out, WithTotalChunks(2), WithInterval(10*time.Millisecond))
+	r.Start()
+	r.AddBytes(100)
+	r.AddChunk()
+	time.Sleep(30 * time.Millisecond)
+	r.AddChunk()
+	r.Finish(true)
+	r.Finish(false)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.GreaterOrEqual(t, len(lines), 2)
+	var last Stat
+	require.NoError(t, jsoniter.Unmarshal([]byte(lines[len(lines)-1]), &last))
+	assert.True(t, last.Done)
+	assert.Equal(t, int64(100), last.Bytes)
+	assert.Equal(t, int64(2), last.Chunks)
+	assert.Equal(t, "decrypt", last.Operation)
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "12 B", FormatBytes(12))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "2.0 GiB", FormatBytes(2*compute.UnitM*compute.UnitK))
+}
@@ -0,0 +1,5 @@
+// Package recovery 用于多人远程恢复秘密的会话管理
+// 发起人使用 Create 打开会话，持有人使用发起人转交的 submit token 通过 Submit 逐个提交份额，
+// 收到至少 t 个有效份额后发起人使用 Release 还原秘密，份额有误时尝试其他组合，
+// 还原成功后会话被清除，失败时保留会话以便继续提交，超时的会话由 Expire 清除
+package recovery
@@ -0,0 +1,443 @@
+package recovery
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/secure"
+	"shamir/pkg/utils/shamir"
+)
+
+const (
+	defaultTTL         = 30 * time.Minute
+	defaultMaxSessions = 100
+	maxThreshold       = 1000
+	// maxExtraShares 会话在门限值之外最多接受的份额，错误的份额不会阻止其他人继续提交
+	maxExtraShares = 16
+	// maxReleaseCombinations 还原时最多尝试的份额组合数
+	maxReleaseCombinations = 1024
+
+	idLen    = 16
+	tokenLen = 32
+)
+
+var (
+	SessionNotFound = errors.New("session not found or expired")
+	TooManySessions = errors.New("too many sessions")
+	InvalidShare    = errors.New("invalid share")
+	// InconsistentShare 份额与会话中的多数份额不在同一个多项式上，不是同一次加密的份额或已被篡改
+	InconsistentShare = errors.New("share not consistent with other shares")
+	DuplicateShare    = errors.New("share already submitted")
+	TooManyShares     = errors.New("too many shares submitted")
+	NotReady          = errors.New("not enough shares to restore the secret")
+	Unauthorized      = errors.New("unauthorized")
+)
+
+// Progress 会话的进度，不包含任何密钥信息，可以展示给所有参与者
+type Progress struct {
+	ID        string    `json:"id" yaml:"id"`
+	Threshold int       `json:"threshold" yaml:"threshold"`
+	Collected int       `json:"collected" yaml:"collected"`
+	Ready     bool      `json:"ready" yaml:"ready"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+// Created 新建的会话，Token 和 SubmitToken 只在创建时返回给发起人。
+// Token 用于还原秘密或取消会话，SubmitToken 由发起人转交给持有人，用于提交份额
+type Created struct {
+	Progress
+	Token       string `json:"token" yaml:"token"`
+	SubmitToken string `json:"submit_token" yaml:"submit_token"`
+}
+
+type session struct {
+	id         string
+	tokenHash  []byte
+	submitHash []byte
+	threshold  int
+	primes     []*big.Int
+	keys       []code.CompoundKey
+	expiresAt  time.Time
+}
+
+func (s *session) progress() *Progress {
+	return &Progress{
+		ID:        s.id,
+		Threshold: s.threshold,
+		Collected: len(s.keys),
+		Ready:     len(s.keys) >= s.threshold,
+		ExpiresAt: s.expiresAt,
+	}
+}
+
+func (s *session) authorized(token string) bool {
+	return matchToken(token, s.tokenHash)
+}
+
+func (s *session) canSubmit(token string) bool {
+	return matchToken(token, s.submitHash)
+}
+
+// add 加入份额，份额多于门限值时用第一段密钥检查是否位于同一个多项式上：
+// 新的份额与多数份额不一致时拒绝，已有的份额与多数份额不一致时清除
+func (s *session) add(key code.CompoundKey) error {
+	keys := make([]code.CompoundKey, 0, len(s.keys)+1)
+	keys = append(append(keys, s.keys...), key)
+	if len(keys) <= s.threshold {
+		s.keys = keys
+		return nil
+	}
+
+	first := make([]code.Key, 0, len(keys))
+	for _, k := range keys {
+		first = append(first, code.Key{X: k.X[0], Y: k.Y[0]})
+	}
+	consistent, err := shamir.ConsistentKeys(first, s.threshold, s.primes[0], maxReleaseCombinations)
+	if err != nil {
+		return errors.Wrap(InvalidShare, err.Error())
+	}
+	if consistent[len(consistent)-1] != len(keys)-1 {
+		return InconsistentShare
+	}
+
+	s.keys = make([]code.CompoundKey, 0, len(consistent))
+	for i, k := range keys {
+		if len(s.keys) < len(consistent) && consistent[len(s.keys)] == i {
+			s.keys = append(s.keys, k)
+			continue
+		}
+		secure.WipeInts(k.X...)
+		secure.WipeInts(k.Y...)
+		log.Warnf("recovery session %s: share %d dropped, not consistent with other shares", s.id, i+1)
+	}
+	return nil
+}
+
+// wipe 清零会话中的必须密钥和份额
+func (s *session) wipe() {
+	secure.WipeInts(s.primes...)
+	for _, key := range s.keys {
+		secure.WipeInts(key.X...)
+		secure.WipeInts(key.Y...)
+	}
+	s.primes, s.keys = nil, nil
+}
+
+type Option func(m *Manager)
+
+// WithTTL 会话的有效期，超时后会话被清除
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.ttl = ttl
+	}
+}
+
+// WithMaxSessions 同时存在的会话数量上限
+func WithMaxSessions(n int) Option {
+	return func(m *Manager) {
+		m.maxSessions = n
+	}
+}
+
+// Manager 管理所有的恢复会话，可以并发使用
+type Manager struct {
+	mu          sync.Mutex
+	sessions    map[string]*session
+	ttl         time.Duration
+	maxSessions int
+	now         func() time.Time
+}
+
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
+		sessions:    map[string]*session{},
+		ttl:         defaultTTL,
+		maxSessions: defaultMaxSessions,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Create 使用必须密钥打开一个门限值为 threshold 的会话
+func (m *Manager) Create(threshold int, necessary string) (*Created, error) {
+	if threshold < shamir.MinThreshold || threshold > maxThreshold {
+		return nil, fmt.Errorf("invalid threshold %d, should in [%d, %d]", threshold, shamir.MinThreshold, maxThreshold)
+	}
+	primes, ok := code.EncodeKeys(necessary)
+	if !ok {
+		return nil, fmt.Errorf("invalid necessary key")
+	}
+	// 最后一段是hash值，至少有两段
+	if len(primes) < 2 {
+		return nil, fmt.Errorf("invalid necessary key")
+	}
+	for _, prime := range primes {
+		if prime.Cmp(big.NewInt(1)) <= 0 {
+			return nil, fmt.Errorf("invalid necessary key")
+		}
+	}
+
+	id, err := randomBytes(idLen)
+	if err != nil {
+		return nil, err
+	}
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	submitToken, submitHash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &session{
+		id:         hex.EncodeToString(id),
+		tokenHash:  tokenHash,
+		submitHash: submitHash,
+		threshold:  threshold,
+		primes:     primes,
+		expiresAt:  m.now().Add(m.ttl),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sessions) >= m.maxSessions {
+		return nil, TooManySessions
+	}
+	m.sessions[s.id] = s
+	log.Infof("recovery session %s created, threshold %d", s.id, threshold)
+
+	return &Created{Progress: *s.progress(), Token: token, SubmitToken: submitToken}, nil
+}
+
+// Submit 向会话提交一个份额，份额需要能够解析、段数与必须密钥一致，且每一段的x密钥不能与已提交的份额重复，
+// 份额多于门限值时第一段密钥还需要与多数份额位于同一个多项式上。token 必须是创建会话时返回的 SubmitToken
+func (m *Manager) Submit(id, token, xKey, yKey string) (*Progress, error) {
+	xKeys, xOk := code.EncodeKeys(xKey)
+	yKeys, yOk := code.EncodeKeys(yKey)
+	if !xOk || !yOk || len(xKeys) != len(yKeys) {
+		return nil, InvalidShare
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.canSubmit(token) {
+		return nil, Unauthorized
+	}
+	if len(s.keys) >= s.threshold+maxExtraShares {
+		return nil, TooManyShares
+	}
+	if len(xKeys) != len(s.primes) {
+		return nil, errors.Wrapf(InvalidShare, "has %d parts but need %d", len(xKeys), len(s.primes))
+	}
+	for i, x := range xKeys {
+		x = new(big.Int).Mod(x, s.primes[i])
+		if x.Sign() == 0 {
+			return nil, InvalidShare
+		}
+		// 重复的x密钥会导致解密时panic
+		for _, key := range s.keys {
+			if new(big.Int).Mod(key.X[i], s.primes[i]).Cmp(x) == 0 {
+				return nil, DuplicateShare
+			}
+		}
+	}
+
+	if err = s.add(code.CompoundKey{X: xKeys, Y: yKeys}); err != nil {
+		return nil, err
+	}
+	log.Infof("recovery session %s: %d of %d shares collected", s.id, len(s.keys), s.threshold)
+	return s.progress(), nil
+}
+
+// Progress 返回会话的进度
+func (m *Manager) Progress(id string) (*Progress, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.progress(), nil
+}
+
+// Release 收集到至少 t 个份额后还原秘密并用秘密中的hash值校验，多于 t 个份额时依次尝试 t 个份额的组合。
+// 还原成功后清除会话，所有组合都失败时保留会话和已提交的份额，可以继续提交份额后重试。
+// token 必须是创建会话时返回的 token
+func (m *Manager) Release(id, token string) ([]byte, error) {
+	m.mu.Lock()
+	s, err := m.get(id)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	if !s.authorized(token) {
+		m.mu.Unlock()
+		return nil, Unauthorized
+	}
+	if len(s.keys) < s.threshold {
+		m.mu.Unlock()
+		return nil, NotReady
+	}
+	// 尝试期间会话不在 map 中，其他请求无法修改或清除其中的份额
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	index := make([]int, s.threshold)
+	for i := range index {
+		index[i] = i
+	}
+	for tried := 0; tried < maxReleaseCombinations; tried++ {
+		keys := make([]code.CompoundKey, 0, len(index))
+		for _, i := range index {
+			keys = append(keys, s.keys[i])
+		}
+		secret, err := restore(keys, s.primes)
+		if err == nil {
+			s.wipe()
+			log.Infof("recovery session %s: secret released by shares %v", s.id, index)
+			return secret, nil
+		}
+		log.Warnf("recovery session %s: shares %v: %v", s.id, index, err)
+		if !shamir.NextCombination(index, len(s.keys)) {
+			break
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.id] = s
+	return nil, shamir.HashCheckFailed
+}
+
+// restore 使用 t 个份额还原秘密并用秘密中的hash值校验
+func restore(keys []code.CompoundKey, primes []*big.Int) ([]byte, error) {
+	chunks, err := shamir.CompoundDecrypt(keys, primes)
+	if err != nil {
+		return nil, err
+	}
+	defer secure.WipeInts(chunks...)
+
+	secret := &bytes.Buffer{}
+	decoder := code.NewSecretDecoder(secret)
+	for _, chunk := range chunks {
+		if err = decoder.Write(chunk); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = decoder.HashCheck()
+	}
+	// 份额有误时还原出的数据可能无法写入，同样视为校验失败
+	if err != nil {
+		secure.Wipe(secret.Bytes())
+		return nil, err
+	}
+	return secret.Bytes(), nil
+}
+
+// Cancel 取消会话并清除已经提交的份额，token 必须是创建会话时返回的 token
+func (m *Manager) Cancel(id, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	if !s.authorized(token) {
+		return Unauthorized
+	}
+
+	m.remove(s)
+	log.Infof("recovery session %s canceled", s.id)
+	return nil
+}
+
+// Expire 清除所有超时的会话，返回清除的数量
+func (m *Manager) Expire() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	now := m.now()
+	for _, s := range m.sessions {
+		if now.Before(s.expiresAt) {
+			continue
+		}
+		m.remove(s)
+		log.Infof("recovery session %s expired", s.id)
+		count++
+	}
+	return count
+}
+
+// Close 清除所有会话
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sessions {
+		m.remove(s)
+	}
+}
+
+// get 返回未超时的会话，调用者需持有锁
+func (m *Manager) get(id string) (*session, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, SessionNotFound
+	}
+	if !m.now().Before(s.expiresAt) {
+		m.remove(s)
+		return nil, SessionNotFound
+	}
+	return s, nil
+}
+
+func (m *Manager) remove(s *session) {
+	delete(m.sessions, s.id)
+	s.wipe()
+}
+
+// newToken 生成随机的 token，会话中只保存其hash值
+func newToken() (string, []byte, error) {
+	data, err := randomBytes(tokenLen)
+	if err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+	sum := sha256.Sum256([]byte(token))
+	return token, sum[:], nil
+}
+
+func matchToken(token string, hash []byte) bool {
+	sum := sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(sum[:], hash) == 1
+}
+
+func randomBytes(n int) ([]byte, error) {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		return nil, fmt.Errorf("generate random bytes failed: %w", err)
+	}
+	return data, nil
+}
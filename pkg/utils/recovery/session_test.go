@@ -0,0 +1,195 @@
+package recovery
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/compute"
+	"shamir/pkg/utils/shamir"
+)
+
+const secret = "this is a secret.同时可以使用中文。"
+
+// split 与命令行加密相同，逐段加密秘密并在最后加密hash值
+func split(t *testing.T, threshold, n int) (string, []*code.StrKey) {
+	necessary := &bytes.Buffer{}
+	nes := code.NewKeyDecoder(necessary)
+	xs := make([]*bytes.Buffer, n)
+	ys := make([]*bytes.Buffer, n)
+	xDecoders := make([]*code.KeyDecoder, n)
+	yDecoders := make([]*code.KeyDecoder, n)
+	for i := 0; i < n; i++ {
+		xs[i], ys[i] = &bytes.Buffer{}, &bytes.Buffer{}
+		xDecoders[i], yDecoders[i] = code.NewKeyDecoder(xs[i]), code.NewKeyDecoder(ys[i])
+	}
+
+	reader := code.NewSecretEncoder(strings.NewReader(secret), compute.GetSecretMaxLen()-1)
+	for {
+		chunk, err := reader.Read()
+		require.NoError(t, err)
+		last := chunk == nil
+		if last {
+			chunk = reader.GetHash()
+		}
+
+		keys, prime, err := shamir.Encrypt(chunk, threshold, n, true)
+		require.NoError(t, err)
+		for i, key := range keys {
+			require.NoError(t, xDecoders[i].Write(key.X))
+			require.NoError(t, yDecoders[i].Write(key.Y))
+		}
+		require.NoError(t, nes.Write(prime))
+		if last {
+			break
+		}
+	}
+
+	keys := make([]*code.StrKey, 0, n)
+	for i := 0; i < n; i++ {
+		keys = append(keys, &code.StrKey{X: xs[i].String(), Y: ys[i].String()})
+	}
+	return necessary.String(), keys
+}
+
+func TestRecovery(t *testing.T) {
+	necessary, keys := split(t, 2, 3)
+	m := NewManager()
+
+	created, err := m.Create(2, necessary)
+	require.NoError(t, err)
+	assert.Equal(t, 2, created.Threshold)
+	assert.NotEmpty(t, created.Token)
+
+	assert.NotEmpty(t, created.SubmitToken)
+
+	_, err = m.Submit(created.ID, "", keys[0].X, keys[0].Y)
+	assert.ErrorIs(t, err, Unauthorized)
+	_, err = m.Submit(created.ID, created.Token, keys[0].X, keys[0].Y)
+	assert.ErrorIs(t, err, Unauthorized)
+	_, err = m.Submit(created.ID, created.SubmitToken, "!", keys[0].Y)
+	assert.ErrorIs(t, err, InvalidShare)
+	progress, err := m.Submit(created.ID, created.SubmitToken, keys[0].X, keys[0].Y)
+	require.NoError(t, err)
+	assert.Equal(t, 1, progress.Collected)
+	assert.False(t, progress.Ready)
+	_, err = m.Submit(created.ID, created.SubmitToken, keys[0].X, keys[0].Y)
+	assert.ErrorIs(t, err, DuplicateShare)
+
+	_, err = m.Release(created.ID, created.Token)
+	assert.ErrorIs(t, err, NotReady)
+
+	progress, err = m.Submit(created.ID, created.SubmitToken, keys[2].X, keys[2].Y)
+	require.NoError(t, err)
+	assert.True(t, progress.Ready)
+	// 达到门限值后仍然接受份额
+	progress, err = m.Submit(created.ID, created.SubmitToken, keys[1].X, keys[1].Y)
+	require.NoError(t, err)
+	assert.Equal(t, 3, progress.Collected)
+
+	_, err = m.Release(created.ID, "wrong token")
+	assert.ErrorIs(t, err, Unauthorized)
+	data, err := m.Release(created.ID, created.Token)
+	require.NoError(t, err)
+	assert.Equal(t, secret, string(data))
+
+	// 还原后会话被清除
+	_, err = m.Progress(created.ID)
+	assert.ErrorIs(t, err, SessionNotFound)
+}
+
+func TestRecoveryWrongShare(t *testing.T) {
+	necessary, keys := split(t, 2, 3)
+	_, otherKeys := split(t, 2, 3)
+	m := NewManager()
+
+	created, err := m.Create(2, necessary)
+	require.NoError(t, err)
+	_, err = m.Submit(created.ID, created.SubmitToken, keys[0].X, keys[0].Y)
+	require.NoError(t, err)
+	_, err = m.Submit(created.ID, created.SubmitToken, otherKeys[1].X, otherKeys[1].Y)
+	require.NoError(t, err)
+
+	_, err = m.Release(created.ID, created.Token)
+	assert.ErrorIs(t, err, shamir.HashCheckFailed)
+
+	// 还原失败时保留会话，继续提交正确的份额后跳过错误的份额还原
+	progress, err := m.Progress(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.Collected)
+	_, err = m.Submit(created.ID, created.SubmitToken, keys[2].X, keys[2].Y)
+	require.NoError(t, err)
+	data, err := m.Release(created.ID, created.Token)
+	require.NoError(t, err)
+	assert.Equal(t, secret, string(data))
+	_, err = m.Progress(created.ID)
+	assert.ErrorIs(t, err, SessionNotFound)
+}
+
+func TestRecoveryInconsistentShare(t *testing.T) {
+	necessary, keys := split(t, 2, 5)
+	_, otherKeys := split(t, 2, 5)
+	m := NewManager()
+
+	created, err := m.Create(2, necessary)
+	require.NoError(t, err)
+	for _, key := range []*code.StrKey{keys[0], keys[1], otherKeys[2]} {
+		_, err = m.Submit(created.ID, created.SubmitToken, key.X, key.Y)
+		require.NoError(t, err)
+	}
+
+	// 多于门限值的份额一致后清除不一致的份额
+	progress, err := m.Submit(created.ID, created.SubmitToken, keys[3].X, keys[3].Y)
+	require.NoError(t, err)
+	assert.Equal(t, 3, progress.Collected)
+	// 之后不一致的份额直接被拒绝
+	_, err = m.Submit(created.ID, created.SubmitToken, otherKeys[4].X, otherKeys[4].Y)
+	assert.ErrorIs(t, err, InconsistentShare)
+
+	data, err := m.Release(created.ID, created.Token)
+	require.NoError(t, err)
+	assert.Equal(t, secret, string(data))
+}
+
+func TestRecoveryTooManyShares(t *testing.T) {
+	necessary, keys := split(t, 2, 2+maxExtraShares+1)
+	m := NewManager()
+
+	created, err := m.Create(2, necessary)
+	require.NoError(t, err)
+	for _, key := range keys[:2+maxExtraShares] {
+		_, err = m.Submit(created.ID, created.SubmitToken, key.X, key.Y)
+		require.NoError(t, err)
+	}
+	last := keys[len(keys)-1]
+	_, err = m.Submit(created.ID, created.SubmitToken, last.X, last.Y)
+	assert.ErrorIs(t, err, TooManyShares)
+}
+
+func TestRecoveryExpire(t *testing.T) {
+	necessary, keys := split(t, 2, 3)
+	now := time.Now()
+	m := NewManager(WithTTL(time.Minute), WithMaxSessions(1))
+	m.now = func() time.Time { return now }
+
+	created, err := m.Create(2, necessary)
+	require.NoError(t, err)
+	_, err = m.Create(2, necessary)
+	assert.ErrorIs(t, err, TooManySessions)
+
+	now = now.Add(2 * time.Minute)
+	_, err = m.Submit(created.ID, created.SubmitToken, keys[0].X, keys[0].Y)
+	assert.ErrorIs(t, err, SessionNotFound)
+
+	created, err = m.Create(2, necessary)
+	require.NoError(t, err)
+	assert.Equal(t, 0, m.Expire())
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, 1, m.Expire())
+	assert.ErrorIs(t, m.Cancel(created.ID, created.Token), SessionNotFound)
+}
@@ -0,0 +1,134 @@
+package seal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"github.com/pkg/errors"
+)
+
+const (
+	ageIntro         = "age-encryption.org/v1"
+	ageKeyPrefix     = "age1"
+	sshKeyPrefix     = "ssh-"
+	maxIdentityBytes = 1 << 16
+	// age 头部 stanza 所在的长度，足够包含第一个 stanza 的类型
+	agePeekLen = 128
+)
+
+// ParseRecipient 解析持有人的公钥，支持 age X25519 公钥(age1...)和 SSH ed25519 公钥(ssh-ed25519 ...)
+func ParseRecipient(publicKey string) (age.Recipient, error) {
+	publicKey = strings.TrimSpace(publicKey)
+	switch {
+	case strings.HasPrefix(publicKey, ageKeyPrefix):
+		return age.ParseX25519Recipient(publicKey)
+	case strings.HasPrefix(publicKey, sshKeyPrefix):
+		if !strings.HasPrefix(publicKey, "ssh-ed25519 ") {
+			return nil, fmt.Errorf("unsupported ssh public key type, only support ssh-ed25519")
+		}
+		return agessh.ParseRecipient(publicKey)
+	default:
+		return nil, fmt.Errorf("unknown public key %q, should be age X25519 or ssh-ed25519 public key", publicKey)
+	}
+}
+
+// ParseIdentityFile 读取私钥文件，支持 age 私钥文件(AGE-SECRET-KEY-1...)和未加密的 SSH ed25519 私钥
+func ParseIdentityFile(file string) ([]age.Identity, error) {
+	file = filepath.Clean(file)
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open identity file %q failed: %w", file, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxIdentityBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read identity file %q failed: %w", file, err)
+	}
+
+	if bytes.Contains(data, []byte("PRIVATE KEY-----")) {
+		identity, e := agessh.ParseIdentity(data)
+		if e != nil {
+			return nil, fmt.Errorf("parse ssh identity %q failed: %w", file, e)
+		}
+		return []age.Identity{identity}, nil
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse age identity %q failed: %w", file, err)
+	}
+	return identities, nil
+}
+
+type ageWriter struct {
+	age   io.WriteCloser
+	armor io.WriteCloser
+	dst   io.WriteCloser
+}
+
+// NewAgeWriter 写入的数据将加密给所有的 recipients，并以 ASCII armor 格式写入 dst
+// Close 时会依次关闭加密流和 dst
+func NewAgeWriter(dst io.WriteCloser, recipients ...age.Recipient) (io.WriteCloser, error) {
+	armorWriter := armor.NewWriter(dst)
+	encryptWriter, err := age.Encrypt(armorWriter, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("create age encrypt writer failed: %w", err)
+	}
+
+	return &ageWriter{
+		age:   encryptWriter,
+		armor: armorWriter,
+		dst:   dst,
+	}, nil
+}
+
+func (a *ageWriter) Write(p []byte) (int, error) {
+	return a.age.Write(p)
+}
+
+func (a *ageWriter) Close() error {
+	return closeAll(a.age, a.armor, a.dst)
+}
+
+func isAge(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(armor.Header)) || bytes.HasPrefix(header, []byte(ageIntro))
+}
+
+func openAge(name string, src io.Reader, armored bool, o *Opener) (io.Reader, error) {
+	if armored {
+		src = armor.NewReader(src)
+	}
+
+	// 通过 age 头部的 stanza 判断是否是口令保护的份额
+	reader := bufio.NewReader(src)
+	header, err := reader.Peek(agePeekLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("read age header failed: %w", err)
+	}
+
+	identities := o.identities
+	if isScrypt(header) {
+		identities, err = o.passphraseIdentities(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("%w: age encrypted, please use --identity", NeedIdentity)
+	}
+
+	result, err := age.Decrypt(reader, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt age share failed: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,4 @@
+// Package seal 用于加密保护写出的份额文件
+// 使用 NewAgeWriter 将份额加密给持有人的 age X25519 或 SSH ed25519 公钥
+// 使用 Open 读取份额，若份额被加密则使用传入的私钥解密，否则原样读取 /*
+package seal
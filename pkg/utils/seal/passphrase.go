@@ -0,0 +1,62 @@
+package seal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+)
+
+const scryptStanza = "\n-> scrypt "
+
+// scrypt 的工作因子，内存消耗约为 128 * 8 * 2^N 字节
+var scryptWorkFactor = 18
+
+// PassphraseFunc 获取名为 name 的份额的口令
+type PassphraseFunc func(name string) ([]byte, error)
+
+// NewPassphraseWriter 使用口令保护写入的数据，口令经过 scrypt 派生密钥后使用 ChaCha20-Poly1305 加密
+// 数据以 age 格式写入 dst，Close 时会依次关闭加密流和 dst
+func NewPassphraseWriter(dst io.WriteCloser, passphrase []byte) (io.WriteCloser, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase can not be empty")
+	}
+
+	recipient, err := age.NewScryptRecipient(string(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("create passphrase recipient failed: %w", err)
+	}
+	recipient.SetWorkFactor(scryptWorkFactor)
+
+	return NewAgeWriter(dst, recipient)
+}
+
+func isScrypt(header []byte) bool {
+	return bytes.Contains(header, []byte(scryptStanza))
+}
+
+func (o *Opener) passphraseIdentities(name string) ([]age.Identity, error) {
+	passphrases := o.passphrases
+	if o.prompt != nil {
+		passphrase, err := o.prompt(name)
+		if err != nil {
+			return nil, fmt.Errorf("get passphrase of share %s failed: %w", name, err)
+		}
+		passphrases = append([][]byte{passphrase}, passphrases...)
+	}
+	if len(passphrases) == 0 {
+		return nil, fmt.Errorf("%w, please input passphrase", NeedPassphrase)
+	}
+
+	identities := make([]age.Identity, 0, len(passphrases))
+	for _, passphrase := range passphrases {
+		identity, err := age.NewScryptIdentity(string(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("create passphrase identity failed: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
@@ -0,0 +1,161 @@
+package seal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+const (
+	pgpMessageType = "PGP MESSAGE"
+	pgpHeader      = "-----BEGIN " + pgpMessageType + "-----"
+	pgpArmorPrefix = "-----BEGIN PGP"
+)
+
+// ReadKeyringFile 读取 OpenPGP 密钥环文件，支持 ASCII armor 和二进制格式
+func ReadKeyringFile(file string) (openpgp.EntityList, error) {
+	file = filepath.Clean(file)
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open keyring file %q failed: %w", file, err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	header, err := reader.Peek(len(pgpArmorPrefix))
+	var keyring openpgp.EntityList
+	if err == nil && string(header) == pgpArmorPrefix {
+		keyring, err = openpgp.ReadArmoredKeyRing(reader)
+	} else {
+		keyring, err = openpgp.ReadKeyRing(reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read keyring %q failed: %w", file, err)
+	}
+
+	return keyring, nil
+}
+
+// FindEntity 从密钥环中按名称查找公钥，名称可以是用户ID、用户名、邮箱或者16位的密钥ID、指纹
+func FindEntity(keyring openpgp.EntityList, name string) (*openpgp.Entity, error) {
+	name = strings.TrimSpace(name)
+	var found []*openpgp.Entity
+	for _, entity := range keyring {
+		if matchEntity(entity, name) {
+			found = append(found, entity)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("public key %q not found in keyring", name)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("public key %q is ambiguous, found %d keys", name, len(found))
+	}
+}
+
+func matchEntity(entity *openpgp.Entity, name string) bool {
+	keyID := strings.ToUpper(strings.TrimPrefix(name, "0x"))
+	fingerprint := fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+	if keyID == entity.PrimaryKey.KeyIdString() || keyID == fingerprint {
+		return true
+	}
+
+	for identityName, identity := range entity.Identities {
+		if identityName == name {
+			return true
+		}
+		if identity.UserId != nil && (identity.UserId.Name == name || identity.UserId.Email == name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DecryptKeyring 使用口令解开密钥环中被保护的私钥
+func DecryptKeyring(keyring openpgp.EntityList, passphrase []byte) error {
+	for _, entity := range keyring {
+		if entity.PrivateKey != nil && entity.PrivateKey.Encrypted {
+			if err := entity.PrivateKey.Decrypt(passphrase); err != nil {
+				return fmt.Errorf("decrypt private key %s failed: %w", entity.PrimaryKey.KeyIdString(), err)
+			}
+		}
+		for _, subkey := range entity.Subkeys {
+			if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+				if err := subkey.PrivateKey.Decrypt(passphrase); err != nil {
+					return fmt.Errorf("decrypt private subkey %s failed: %w", subkey.PublicKey.KeyIdString(), err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+type pgpWriter struct {
+	pgp   io.WriteCloser
+	armor io.WriteCloser
+	dst   io.WriteCloser
+}
+
+// NewPGPWriter 写入的数据将加密给所有的 entities，并以 ASCII armor 的 OpenPGP 消息格式写入 dst
+// Close 时会依次关闭加密流和 dst
+func NewPGPWriter(dst io.WriteCloser, entities ...*openpgp.Entity) (io.WriteCloser, error) {
+	armorWriter, err := armor.Encode(dst, pgpMessageType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create pgp armor writer failed: %w", err)
+	}
+
+	encryptWriter, err := openpgp.Encrypt(armorWriter, entities, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create pgp encrypt writer failed: %w", err)
+	}
+
+	return &pgpWriter{
+		pgp:   encryptWriter,
+		armor: armorWriter,
+		dst:   dst,
+	}, nil
+}
+
+func (p *pgpWriter) Write(data []byte) (int, error) {
+	return p.pgp.Write(data)
+}
+
+func (p *pgpWriter) Close() error {
+	return closeAll(p.pgp, p.armor, p.dst)
+}
+
+func isPGP(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(pgpHeader))
+}
+
+func openPGP(src io.Reader, keyring openpgp.EntityList) (io.Reader, error) {
+	if len(keyring.DecryptionKeys()) == 0 {
+		return nil, fmt.Errorf("%w: OpenPGP encrypted, please use --pgp-secret-key", NeedIdentity)
+	}
+
+	block, err := armor.Decode(src)
+	if err != nil {
+		return nil, fmt.Errorf("decode pgp armor failed: %w", err)
+	}
+	if block.Type != pgpMessageType {
+		return nil, fmt.Errorf("invalid pgp armor type %q", block.Type)
+	}
+
+	message, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt pgp share failed: %w", err)
+	}
+	return message.UnverifiedBody, nil
+}
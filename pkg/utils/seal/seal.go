@@ -0,0 +1,155 @@
+package seal
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+)
+
+// 探测文件格式时读取的头部长度
+const peekLen = 64
+
+// 份额加密的格式，未加密时为空
+const (
+	FormatAge        = "age"
+	FormatPassphrase = "age-passphrase"
+	FormatPGP        = "openpgp"
+)
+
+var (
+	NeedIdentity   = errors.New("share is sealed")
+	NeedPassphrase = errors.New("share is protected by passphrase")
+)
+
+type OpenerOption func(o *Opener)
+
+// WithAgeIdentities 使用 age 私钥解密份额
+func WithAgeIdentities(identities ...age.Identity) OpenerOption {
+	return func(o *Opener) {
+		o.identities = append(o.identities, identities...)
+	}
+}
+
+// WithPGPKeyring 使用 OpenPGP 私钥环解密份额
+func WithPGPKeyring(keyring openpgp.EntityList) OpenerOption {
+	return func(o *Opener) {
+		o.keyring = append(o.keyring, keyring...)
+	}
+}
+
+// WithPassphrases 使用口令解密被口令保护的份额，会依次尝试每个口令
+func WithPassphrases(passphrases ...[]byte) OpenerOption {
+	return func(o *Opener) {
+		o.passphrases = append(o.passphrases, passphrases...)
+	}
+}
+
+// WithPassphrasePrompt 遇到被口令保护的份额时，调用 prompt 获取口令
+func WithPassphrasePrompt(prompt PassphraseFunc) OpenerOption {
+	return func(o *Opener) {
+		o.prompt = prompt
+	}
+}
+
+// Opener 读取份额，自动识别份额是否被加密以及加密的格式
+type Opener struct {
+	identities  []age.Identity
+	keyring     openpgp.EntityList
+	passphrases [][]byte
+	prompt      PassphraseFunc
+}
+
+func NewOpener(opts ...OpenerOption) *Opener {
+	o := &Opener{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// Open 若份额被加密，使用对应的私钥解密；否则返回原始内容
+func (o *Opener) Open(src io.Reader) (io.Reader, error) {
+	return o.OpenNamed("", src)
+}
+
+// OpenNamed 与 Open 相同，name 为份额的名称，需要输入口令时用于提示
+func (o *Opener) OpenNamed(name string, src io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(src)
+	header, err := reader.Peek(peekLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	header = bytes.TrimLeft(header, " \t\r\n")
+
+	switch {
+	case isAge(header):
+		return openAge(name, reader, bytes.HasPrefix(header, []byte(armor.Header)), o)
+	case isPGP(header):
+		return openPGP(reader, o.keyring)
+	default:
+		return reader, nil
+	}
+}
+
+// IsSealed 返回份额头部是否是加密格式
+func IsSealed(header []byte) bool {
+	header = bytes.TrimLeft(header, " \t\r\n")
+	return isAge(header) || isPGP(header)
+}
+
+// PeekSealed 读取头部判断份额是否被加密，返回的 reader 仍然从头读取全部内容
+func PeekSealed(src io.Reader) (io.Reader, bool, error) {
+	reader := bufio.NewReader(src)
+	header, err := reader.Peek(peekLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, false, err
+	}
+	return reader, IsSealed(header), nil
+}
+
+// Format 只读取头部识别份额加密的格式，不需要私钥和口令，未加密时返回空
+func Format(src io.Reader) (string, error) {
+	reader := bufio.NewReader(src)
+	header, err := reader.Peek(peekLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return "", err
+	}
+	header = bytes.TrimLeft(header, " \t\r\n")
+
+	switch {
+	case isAge(header):
+		var ageReader io.Reader = reader
+		if bytes.HasPrefix(header, []byte(armor.Header)) {
+			ageReader = armor.NewReader(reader)
+		}
+		ageHeader := make([]byte, agePeekLen)
+		n, e := io.ReadFull(ageReader, ageHeader)
+		if e != nil && !errors.Is(e, io.EOF) && !errors.Is(e, io.ErrUnexpectedEOF) {
+			return "", e
+		}
+		if isScrypt(ageHeader[:n]) {
+			return FormatPassphrase, nil
+		}
+		return FormatAge, nil
+	case isPGP(header):
+		return FormatPGP, nil
+	default:
+		return "", nil
+	}
+}
+
+func closeAll(closers ...io.Closer) error {
+	var result error
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
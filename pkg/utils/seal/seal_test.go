@@ -0,0 +1,184 @@
+package seal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+const share = "ThisIsABigNumber1_ThisIsABigNumber2"
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	return nil
+}
+
+func sealAndOpen(t *testing.T, writer io.WriteCloser, buffer *bufferCloser, opener *Opener) {
+	_, err := writer.Write([]byte(share))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.True(t, IsSealed(buffer.Bytes()))
+
+	peeked, sealed, err := PeekSealed(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	assert.True(t, sealed)
+	data, err := io.ReadAll(peeked)
+	require.NoError(t, err)
+	assert.Equal(t, buffer.Bytes(), data)
+	_, sealed, err = PeekSealed(strings.NewReader(share))
+	require.NoError(t, err)
+	assert.False(t, sealed)
+
+	_, err = NewOpener().Open(bytes.NewReader(buffer.Bytes()))
+	assert.ErrorIs(t, err, NeedIdentity)
+
+	reader, err := opener.Open(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	data, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, share, string(data))
+}
+
+func TestX25519(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	recipient, err := ParseRecipient(identity.Recipient().String())
+	require.NoError(t, err)
+
+	identityFile := filepath.Join(t.TempDir(), "identity.txt")
+	require.NoError(t, os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600))
+	identities, err := ParseIdentityFile(identityFile)
+	require.NoError(t, err)
+
+	buffer := &bufferCloser{}
+	writer, err := NewAgeWriter(buffer, recipient)
+	require.NoError(t, err)
+	sealAndOpen(t, writer, buffer, NewOpener(WithAgeIdentities(identities...)))
+
+	format, err := Format(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, FormatAge, format)
+}
+
+func TestSSHEd25519(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	require.NoError(t, err)
+	recipient, err := ParseRecipient(string(ssh.MarshalAuthorizedKey(sshPublicKey)))
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	identityFile := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(identityFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	identities, err := ParseIdentityFile(identityFile)
+	require.NoError(t, err)
+
+	buffer := &bufferCloser{}
+	writer, err := NewAgeWriter(buffer, recipient)
+	require.NoError(t, err)
+	sealAndOpen(t, writer, buffer, NewOpener(WithAgeIdentities(identities...)))
+}
+
+func writeArmored(t *testing.T, file, blockType string, serialize func(w io.Writer) error) {
+	buffer := &bytes.Buffer{}
+	writer, err := pgparmor.Encode(buffer, blockType, nil)
+	require.NoError(t, err)
+	require.NoError(t, serialize(writer))
+	require.NoError(t, writer.Close())
+	require.NoError(t, os.WriteFile(file, buffer.Bytes(), 0600))
+}
+
+func TestOpenPGP(t *testing.T) {
+	config := &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA}
+	entity, err := openpgp.NewEntity("holder", "", "holder@example.com", config)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	publicFile := filepath.Join(dir, "pubring.asc")
+	writeArmored(t, publicFile, openpgp.PublicKeyType, entity.Serialize)
+	secretFile := filepath.Join(dir, "secret.asc")
+	writeArmored(t, secretFile, openpgp.PrivateKeyType, func(w io.Writer) error {
+		return entity.SerializePrivate(w, nil)
+	})
+
+	keyring, err := ReadKeyringFile(publicFile)
+	require.NoError(t, err)
+	recipient, err := FindEntity(keyring, "holder@example.com")
+	require.NoError(t, err)
+	_, err = FindEntity(keyring, "nobody@example.com")
+	assert.Error(t, err)
+
+	secretKeyring, err := ReadKeyringFile(secretFile)
+	require.NoError(t, err)
+
+	buffer := &bufferCloser{}
+	writer, err := NewPGPWriter(buffer, recipient)
+	require.NoError(t, err)
+	sealAndOpen(t, writer, buffer, NewOpener(WithPGPKeyring(secretKeyring)))
+
+	format, err := Format(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, FormatPGP, format)
+}
+
+func TestPassphrase(t *testing.T) {
+	scryptWorkFactor = 10
+	buffer := &bufferCloser{}
+	writer, err := NewPassphraseWriter(buffer, []byte("correct horse"))
+	require.NoError(t, err)
+
+	prompt := func(name string) ([]byte, error) {
+		assert.Equal(t, "holder-0", name)
+		return []byte("correct horse"), nil
+	}
+	_, err = writer.Write([]byte(share))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	format, err := Format(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, FormatPassphrase, format)
+
+	_, err = NewOpener().Open(bytes.NewReader(buffer.Bytes()))
+	assert.ErrorIs(t, err, NeedPassphrase)
+	_, err = NewOpener(WithPassphrases([]byte("wrong"))).Open(bytes.NewReader(buffer.Bytes()))
+	assert.Error(t, err)
+
+	reader, err := NewOpener(WithPassphrasePrompt(prompt)).OpenNamed("holder-0", bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, share, string(data))
+}
+
+func TestOpenPlain(t *testing.T) {
+	reader, err := NewOpener().Open(bytes.NewBufferString(share))
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, share, string(data))
+
+	format, err := Format(bytes.NewBufferString(share))
+	require.NoError(t, err)
+	assert.Empty(t, format)
+}
@@ -0,0 +1,5 @@
+// Package secure 文件和内存的安全工具
+// LockedBuffer 将秘密保存在锁定的内存中，不会被交换到磁盘或出现在 core dump 中；
+// Wipe、WipeInt 在使用后清零字节切片和大整数；DisableCoreDump 在启动时禁止 core dump。
+// Go 的字符串无法清零，秘密应当尽量以 []byte 传递
+package secure
@@ -0,0 +1,179 @@
+package secure
+
+import (
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"shamir/pkg/utils/log"
+)
+
+var mlockWarning sync.Once
+
+// LockedBuffer 使用单独映射的内存保存秘密，锁定后不会被交换到磁盘，也不会出现在 core dump 中，
+// Destroy 时清零释放，忘记 Destroy 时由垃圾回收清零释放
+type LockedBuffer struct {
+	data   []byte
+	locked bool
+}
+
+// NewLockedBuffer 申请 size 字节锁定的内存
+func NewLockedBuffer(size int) (*LockedBuffer, error) {
+	b := &LockedBuffer{}
+	if size == 0 {
+		return b, nil
+	}
+
+	data, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, err
+	}
+	b.data = data
+	_ = unix.Madvise(data, unix.MADV_DONTDUMP)
+
+	// 超过 RLIMIT_MEMLOCK 时无法锁定，仍然可以使用，只是可能被交换到磁盘
+	if err = unix.Mlock(data); err != nil {
+		mlockWarning.Do(func() {
+			log.Warnf("lock memory failed, secrets may be swapped to disk: %v", err)
+		})
+	} else {
+		b.locked = true
+	}
+
+	runtime.SetFinalizer(b, (*LockedBuffer).Destroy)
+	return b, nil
+}
+
+// LockBytes 将 src 复制到锁定的内存中，src 由调用者清零
+func LockBytes(src []byte) (*LockedBuffer, error) {
+	b, err := NewLockedBuffer(len(src))
+	if err != nil {
+		return nil, err
+	}
+	copy(b.data, src)
+	return b, nil
+}
+
+// Bytes 返回锁定的内存，Destroy 后不能再使用，使用期间需要持有 LockedBuffer 避免被回收
+func (b *LockedBuffer) Bytes() []byte {
+	return b.data
+}
+
+func (b *LockedBuffer) String() string {
+	return string(b.data)
+}
+
+func (b *LockedBuffer) Destroy() {
+	if b == nil || b.data == nil {
+		return
+	}
+
+	Wipe(b.data)
+	if b.locked {
+		_ = unix.Munlock(b.data)
+	}
+	if err := unix.Munmap(b.data); err != nil {
+		log.Errorf("unmap locked memory failed: %v", err)
+	}
+	b.data = nil
+	runtime.SetFinalizer(b, nil)
+}
+
+// Wipe 清零字节切片，包括长度之外的容量
+func Wipe(data []byte) {
+	data = data[:cap(data)]
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+// WipeInt 清零大整数的数据，之后的值为0
+func WipeInt(n *big.Int) {
+	if n == nil {
+		return
+	}
+	words := n.Bits()
+	words = words[:cap(words)]
+	for i := range words {
+		words[i] = 0
+	}
+	n.SetInt64(0)
+}
+
+func WipeInts(ns ...*big.Int) {
+	for _, n := range ns {
+		WipeInt(n)
+	}
+}
+
+type wipeReader struct {
+	data   []byte
+	offset int
+}
+
+// NewWipeReader 读取 data，Close 时清零 data
+func NewWipeReader(data []byte) io.ReadCloser {
+	return &wipeReader{data: data}
+}
+
+func (r *wipeReader) Read(p []byte) (int, error) {
+	if r.offset >= len(r.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+func (r *wipeReader) Close() error {
+	Wipe(r.data)
+	return nil
+}
+
+// Buffer 读写缓冲，扩容和读完时清零旧的数据，用于暂存解密出的秘密
+type Buffer struct {
+	data   []byte
+	offset int
+}
+
+func (b *Buffer) Len() int {
+	return len(b.data) - b.offset
+}
+
+func (b *Buffer) Write(p []byte) (int, error) {
+	if len(b.data)+len(p) > cap(b.data) {
+		data := make([]byte, len(b.data), 2*cap(b.data)+len(p))
+		copy(data, b.data)
+		Wipe(b.data)
+		b.data = data
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+func (b *Buffer) Read(p []byte) (int, error) {
+	if b.Len() == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, b.data[b.offset:])
+	b.offset += n
+	if b.offset == len(b.data) {
+		b.Reset()
+	}
+	return n, nil
+}
+
+// Bytes 返回未读的数据，下次读写前有效
+func (b *Buffer) Bytes() []byte {
+	return b.data[b.offset:]
+}
+
+// Reset 清零并清空数据，保留容量
+func (b *Buffer) Reset() {
+	Wipe(b.data)
+	b.data = b.data[:0]
+	b.offset = 0
+}
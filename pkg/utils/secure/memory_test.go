@@ -0,0 +1,73 @@
+package secure
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockedBuffer(t *testing.T) {
+	b, err := LockBytes([]byte("locked secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "locked secret", b.String())
+
+	b.Destroy()
+	assert.Nil(t, b.Bytes())
+	b.Destroy()
+
+	empty, err := NewLockedBuffer(0)
+	require.NoError(t, err)
+	assert.Empty(t, empty.Bytes())
+	empty.Destroy()
+}
+
+func TestWipe(t *testing.T) {
+	data := []byte("secret data")
+	Wipe(data[:3])
+	assert.Equal(t, make([]byte, len(data)), data)
+
+	n, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	require.True(t, ok)
+	words := n.Bits()
+	WipeInt(n)
+	assert.Equal(t, 0, n.Sign())
+	for _, word := range words {
+		assert.Zero(t, word)
+	}
+	WipeInts(nil, big.NewInt(1))
+}
+
+func TestBuffer(t *testing.T) {
+	b := &Buffer{}
+	_, err := b.Write([]byte("hello "))
+	require.NoError(t, err)
+	old := b.data
+	_, err = b.Write(bytes.Repeat([]byte("world"), 10))
+	require.NoError(t, err)
+	// 扩容后旧的数据被清零
+	assert.Equal(t, make([]byte, len(old)), old)
+
+	p := make([]byte, 6)
+	n, err := b.Read(p)
+	require.NoError(t, err)
+	assert.Equal(t, "hello ", string(p[:n]))
+	data := b.data
+	rest, err := io.ReadAll(b)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte("world"), 10), rest)
+	// 读完后清零
+	assert.Equal(t, make([]byte, len(data)), data)
+	assert.Equal(t, 0, b.Len())
+
+	reader := NewWipeReader([]byte("wipe me"))
+	secret := reader.(*wipeReader).data
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "wipe me", string(got))
+	require.NoError(t, reader.Close())
+	assert.Equal(t, make([]byte, 7), secret)
+}
@@ -0,0 +1,14 @@
+package secure
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// DisableCoreDump 禁止生成 core dump，同时设置进程不可 dump，
+// 其他同用户的进程无法 ptrace 或读取 /proc/<pid>/mem 中的秘密
+func DisableCoreDump() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0}); err != nil {
+		return err
+	}
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
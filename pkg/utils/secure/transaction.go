@@ -0,0 +1,271 @@
+package secure
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+
+	"shamir/pkg/utils/log"
+)
+
+const (
+	// FilePermission 密钥、签名和秘密文件只有所有者可以读写
+	FilePermission = 0600
+
+	tempFileTries = 10
+)
+
+var (
+	TransactionDone = errors.New("transaction already committed or rolled back")
+)
+
+// Transaction 事务性地写入一组文件。文件先以 0600 和 O_EXCL|O_NOFOLLOW 创建为目标目录下的隐藏临时文件，
+// Commit 时全部 fsync 后才逐个改名为目标文件，任意一步失败都会删除临时文件和已经改名的文件，
+// 进程崩溃时只会留下临时文件，不会出现写了一半的目标文件
+type Transaction struct {
+	files []*TxFile
+	done  bool
+}
+
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// TxFile 事务中的一个文件，Close 时 fsync，写入和关闭的错误会在 Commit 时返回
+type TxFile struct {
+	file   *os.File
+	name   string
+	temp   string
+	closed bool
+	err    error
+}
+
+// Create 创建 name 对应的临时文件，name 已存在时返回 os.ErrExist
+func (t *Transaction) Create(name string) (*TxFile, error) {
+	if t.done {
+		return nil, TransactionDone
+	}
+	name = filepath.Clean(name)
+	if _, err := os.Lstat(name); err == nil {
+		return nil, fmt.Errorf("create file %q failed: %w", name, os.ErrExist)
+	}
+
+	var lastErr error
+	for i := 0; i < tempFileTries; i++ {
+		temp, err := tempName(name)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL|unix.O_NOFOLLOW, FilePermission)
+		if err != nil {
+			if os.IsExist(err) {
+				lastErr = err
+				continue
+			}
+			return nil, fmt.Errorf("create file %q failed: %w", name, err)
+		}
+
+		f := &TxFile{file: file, name: name, temp: temp}
+		t.files = append(t.files, f)
+		return f, nil
+	}
+	return nil, fmt.Errorf("create file %q failed: %w", name, lastErr)
+}
+
+// WriteFile 在事务中写入整个文件
+func (t *Transaction) WriteFile(name string, data []byte) error {
+	f, err := t.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Path 返回提交前 name 对应的临时文件路径，用于读取已经写入的内容，如对份额签名
+func (t *Transaction) Path(name string) string {
+	name = filepath.Clean(name)
+	for _, f := range t.files {
+		if f.name == name {
+			return f.temp
+		}
+	}
+	return name
+}
+
+// Names 返回事务中所有目标文件的路径，按创建顺序排列
+func (t *Transaction) Names() []string {
+	names := make([]string, 0, len(t.files))
+	for _, f := range t.files {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// Remove 从事务中删除 name 对应的临时文件，提交后不会出现，如已经打包进归档的文件
+func (t *Transaction) Remove(name string) {
+	name = filepath.Clean(name)
+	for i, f := range t.files {
+		if f.name != name {
+			continue
+		}
+		_ = f.Close()
+		removeFile(f.temp)
+		t.files = append(t.files[:i], t.files[i+1:]...)
+		return
+	}
+}
+
+// Commit 关闭并 fsync 所有文件，之后改名为目标文件并 fsync 所在目录。
+// 改名不会覆盖已存在的文件，失败时回滚整个事务
+func (t *Transaction) Commit() error {
+	if t.done {
+		return TransactionDone
+	}
+	for _, f := range t.files {
+		if err := f.Close(); err != nil {
+			t.Rollback()
+			return fmt.Errorf("write file %q failed: %w", f.name, err)
+		}
+	}
+
+	for i, f := range t.files {
+		if err := renameNoReplace(f.temp, f.name); err != nil {
+			for _, committed := range t.files[:i] {
+				removeFile(committed.name)
+			}
+			t.Rollback()
+			return fmt.Errorf("rename file %q failed: %w", f.name, err)
+		}
+		f.temp = ""
+	}
+
+	t.done = true
+	synced := map[string]bool{}
+	for _, f := range t.files {
+		dir := filepath.Dir(f.name)
+		if synced[dir] {
+			continue
+		}
+		synced[dir] = true
+		if err := syncDir(dir); err != nil {
+			return fmt.Errorf("sync directory %q failed: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// Rollback 关闭并删除所有临时文件，提交成功后调用没有影响，可以重复调用
+func (t *Transaction) Rollback() {
+	if t.done {
+		return
+	}
+	t.done = true
+	for _, f := range t.files {
+		_ = f.Close()
+		if f.temp != "" {
+			removeFile(f.temp)
+		}
+	}
+}
+
+func (f *TxFile) Write(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	n, err := f.file.Write(p)
+	if err != nil {
+		f.err = err
+	}
+	return n, err
+}
+
+// Rewind 清空已写入的内容，从头重新写入
+func (f *TxFile) Rewind() error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.closed {
+		return os.ErrClosed
+	}
+	if err := f.file.Truncate(0); err != nil {
+		f.err = err
+		return err
+	}
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		f.err = err
+		return err
+	}
+	return nil
+}
+
+// Name 返回目标文件路径
+func (f *TxFile) Name() string {
+	return f.name
+}
+
+// Close fsync 后关闭文件，可以重复调用，返回第一次写入或关闭的错误
+func (f *TxFile) Close() error {
+	if f.closed {
+		return f.err
+	}
+	f.closed = true
+
+	if err := f.file.Sync(); err != nil && f.err == nil {
+		f.err = err
+	}
+	if err := f.file.Close(); err != nil && f.err == nil {
+		f.err = err
+	}
+	return f.err
+}
+
+// tempName 目标文件同目录下的隐藏文件，保证改名在同一个文件系统中
+func tempName(name string) (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(name), fmt.Sprintf(".%s.%s.tmp", filepath.Base(name), hex.EncodeToString(random))), nil
+}
+
+// renameNoReplace 改名时目标文件已存在返回错误，文件系统不支持 RENAME_NOREPLACE 时使用硬链接
+func renameNoReplace(oldPath, newPath string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, oldPath, unix.AT_FDCWD, newPath, unix.RENAME_NOREPLACE)
+	if !errors.Is(err, unix.EINVAL) && !errors.Is(err, unix.ENOSYS) {
+		return err
+	}
+
+	if err = os.Link(oldPath, newPath); err != nil {
+		return err
+	}
+	return os.Remove(oldPath)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// 部分文件系统不支持目录的 fsync
+	if err = d.Sync(); err != nil && !errors.Is(err, unix.EINVAL) {
+		return err
+	}
+	return nil
+}
+
+func removeFile(name string) {
+	if err := os.Remove(name); err != nil {
+		log.Error(err)
+	}
+}
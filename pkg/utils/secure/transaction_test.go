@@ -0,0 +1,115 @@
+package secure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestTransactionCommit(t *testing.T) {
+	dir := t.TempDir()
+	tx := NewTransaction()
+
+	f, err := tx.Create(filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("data of a"))
+	require.NoError(t, err)
+	require.NoError(t, tx.WriteFile(filepath.Join(dir, "b"), []byte("data of b")))
+
+	// 提交前目标文件不存在，只能通过临时文件读取
+	_, err = os.Stat(filepath.Join(dir, "a"))
+	assert.True(t, os.IsNotExist(err))
+	data, err := os.ReadFile(tx.Path(filepath.Join(dir, "b")))
+	require.NoError(t, err)
+	assert.Equal(t, "data of b", string(data))
+	assert.Equal(t, filepath.Join(dir, "c"), tx.Path(filepath.Join(dir, "c")))
+
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, []string{"a", "b"}, listDir(t, dir))
+	for name, content := range map[string]string{"a": "data of a", "b": "data of b"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(FilePermission), info.Mode().Perm())
+		data, err = os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	assert.ErrorIs(t, tx.Commit(), TransactionDone)
+	tx.Rollback()
+	assert.Equal(t, []string{"a", "b"}, listDir(t, dir))
+}
+
+func TestTransactionRollback(t *testing.T) {
+	dir := t.TempDir()
+	tx := NewTransaction()
+	require.NoError(t, tx.WriteFile(filepath.Join(dir, "a"), []byte("a")))
+	_, err := tx.Create(filepath.Join(dir, "b"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, tx.Names())
+	tx.Remove(filepath.Join(dir, "a"))
+	assert.Equal(t, []string{filepath.Join(dir, "b")}, tx.Names())
+	assert.Len(t, listDir(t, dir), 1)
+
+	tx.Rollback()
+	tx.Rollback()
+	assert.Empty(t, listDir(t, dir))
+	_, err = tx.Create(filepath.Join(dir, "c"))
+	assert.ErrorIs(t, err, TransactionDone)
+}
+
+func TestTransactionExist(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "exist"), []byte("old"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "exist"), filepath.Join(dir, "link")))
+
+	tx := NewTransaction()
+	_, err := tx.Create(filepath.Join(dir, "exist"))
+	assert.ErrorIs(t, err, os.ErrExist)
+	_, err = tx.Create(filepath.Join(dir, "link"))
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	// 提交时目标文件已经出现，整个事务回滚且不覆盖已有文件
+	require.NoError(t, tx.WriteFile(filepath.Join(dir, "a"), []byte("a")))
+	require.NoError(t, tx.WriteFile(filepath.Join(dir, "late"), []byte("new")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "late"), []byte("old"), 0644))
+	assert.ErrorIs(t, tx.Commit(), os.ErrExist)
+
+	assert.Equal(t, []string{"exist", "late", "link"}, listDir(t, dir))
+	data, err := os.ReadFile(filepath.Join(dir, "late"))
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+}
+
+func TestTxFileRewind(t *testing.T) {
+	dir := t.TempDir()
+	tx := NewTransaction()
+	f, err := tx.Create(filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("wrong secret"))
+	require.NoError(t, err)
+
+	require.NoError(t, f.Rewind())
+	_, err = f.Write([]byte("secret"))
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	data, err := os.ReadFile(filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(data))
+
+	assert.ErrorIs(t, f.Rewind(), os.ErrClosed)
+}
@@ -18,6 +18,7 @@ const (
 var (
 	HashSumFailed   = errors.New("secret hash sum failed")
 	HashCheckFailed = errors.New("secret hash check failed")
+	DuplicateXKey   = errors.New("keys not match, duplicate x key")
 )
 
 // Decrypt 根据密钥对解密，传入 len(keys) 必须是门限值threshold, prime 也必须是加密时使用的素数。
@@ -30,6 +31,76 @@ func Decrypt(keys []code.Key, prime *big.Int) (secret *big.Int, err error) {
 	return decrypt(keys, prime), nil
 }
 
+// CheckKeys 检查密钥对能否用于 Decrypt，重复的x密钥会导致解密时panic，
+// 损坏或者来源不可信的密钥可能出现这种情况
+func CheckKeys(keys []code.Key, prime *big.Int) error {
+	if prime == nil || prime.Sign() <= 0 {
+		return fmt.Errorf("invalid necessary key")
+	}
+	if err := decryptCheck(keys, prime); err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		x := new(big.Int).Mod(key.X, prime).String()
+		if _, ok := seen[x]; ok {
+			return DuplicateXKey
+		}
+		seen[x] = struct{}{}
+	}
+	return nil
+}
+
+// ConsistentKeys 找出位于同一个 threshold-1 次多项式上最多的一组密钥对，返回它们在 keys 中的下标。
+// 同一次加密的密钥对在每一段上都位于同一个多项式上，而两个不同的多项式最多在 threshold-1 个点上相交，
+// 因此密钥对多于门限值时只需要一段密钥就能排除不一致的密钥对，不必完整解密每个组合。
+// 最多尝试 limit 个组合，没有多于 threshold 个密钥对相互一致时无法判断，返回全部下标
+func ConsistentKeys(keys []code.Key, threshold int, prime *big.Int, limit int) ([]int, error) {
+	if err := CheckKeys(keys, prime); err != nil {
+		return nil, err
+	}
+
+	all := make([]int, 0, len(keys))
+	for i := range keys {
+		all = append(all, i)
+	}
+	if threshold < MinThreshold || len(keys) <= threshold {
+		return all, nil
+	}
+
+	var best []int
+	index := make([]int, threshold)
+	for i := range index {
+		index[i] = i
+	}
+	chosen := make([]code.Key, threshold)
+	for tried := 0; tried < limit; tried++ {
+		for i, j := range index {
+			chosen[i] = keys[j]
+		}
+		polynomial := newLagrange(chosen, prime)
+		var group []int
+		for i, key := range keys {
+			if polynomial.at(key.X).Cmp(new(big.Int).Mod(key.Y, prime)) == 0 {
+				group = append(group, i)
+			}
+		}
+		if len(group) > len(best) {
+			best = group
+		}
+		// 其他多项式与 best 最多有 threshold-1 个公共点，剩下的密钥对不可能组成更大的一组
+		if 2*len(best) > len(keys)+threshold-1 || !NextCombination(index, len(keys)) {
+			break
+		}
+	}
+
+	if len(best) <= threshold {
+		return all, nil
+	}
+	return best, nil
+}
+
 // CompoundDecrypt 用于解密复合型秘密，使用复合型密钥和复合型素数，解密出复合型秘密
 func CompoundDecrypt(keys []code.CompoundKey, prime []*big.Int) (secret []*big.Int, err error) {
 	if err = checkCompoundKeys(keys, prime); err != nil {
@@ -85,6 +156,57 @@ func decrypt(keys []code.Key, prime *big.Int) *big.Int {
 	return result
 }
 
+// lagrange 经过 t 个点的多项式，预先计算每个点的权重 y_i / ∏(x_i - x_j)，求值时不需要再求逆
+type lagrange struct {
+	xs      []*big.Int
+	weights []*big.Int
+	prime   *big.Int
+}
+
+// newLagrange keys 的x密钥不能重复
+func newLagrange(keys []code.Key, prime *big.Int) *lagrange {
+	l := &lagrange{
+		xs:      make([]*big.Int, 0, len(keys)),
+		weights: make([]*big.Int, 0, len(keys)),
+		prime:   prime,
+	}
+	for i, key := range keys {
+		denominator := big.NewInt(1)
+		for j, other := range keys {
+			if j == i {
+				continue
+			}
+			denominator.Mul(denominator, new(big.Int).Sub(key.X, other.X)).Mod(denominator, prime)
+		}
+		denominator.ModInverse(denominator, prime)
+		l.xs = append(l.xs, key.X)
+		l.weights = append(l.weights, denominator.Mul(denominator, key.Y).Mod(denominator, prime))
+	}
+	return l
+}
+
+// at 求多项式在 x 处的值 mod prime，即 ∑ w_i * ∏(x - x_j) (j != i)，用前缀积和后缀积避免重复相乘
+func (l *lagrange) at(x *big.Int) *big.Int {
+	n := len(l.xs)
+	suffix := make([]*big.Int, n+1)
+	suffix[n] = big.NewInt(1)
+	for i := n - 1; i >= 0; i-- {
+		suffix[i] = new(big.Int).Sub(x, l.xs[i])
+		suffix[i].Mul(suffix[i], suffix[i+1]).Mod(suffix[i], l.prime)
+	}
+
+	result := big.NewInt(0)
+	prefix := big.NewInt(1)
+	term := new(big.Int)
+	for i := 0; i < n; i++ {
+		term.Mul(l.weights[i], prefix).Mod(term, l.prime)
+		term.Mul(term, suffix[i+1])
+		result.Add(result, term).Mod(result, l.prime)
+		prefix.Mul(prefix, new(big.Int).Sub(x, l.xs[i])).Mod(prefix, l.prime)
+	}
+	return result
+}
+
 func compoundDecrypt(keys []code.CompoundKey, prime []*big.Int) []*big.Int {
 	result := make([]*big.Int, 0, len(prime))
 	for i, tmpPrime := range prime {
@@ -174,3 +296,22 @@ func product(xKeys []*big.Int, xKeysI *big.Int, prime *big.Int) *big.Int {
 
 	return result
 }
+
+// NextCombination 将 index 更新为字典序的下一个组合，index 中的元素取值范围为 [0, n)，
+// 已经是最后一个组合时返回false
+func NextCombination(index []int, n int) bool {
+	k := len(index)
+	i := k - 1
+	for i >= 0 && index[i] == n-k+i {
+		i--
+	}
+	if i < 0 {
+		return false
+	}
+
+	index[i]++
+	for j := i + 1; j < k; j++ {
+		index[j] = index[j-1] + 1
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package shamir
 
 import (
+	"bytes"
+	"io"
 	"math/big"
 	"testing"
 
@@ -108,6 +110,87 @@ func (d *decryptEncryptSuit) TestDecrypt() {
 	assert.Equal(d.T(), bigSecret, bigResult)
 }
 
+func (d *decryptEncryptSuit) TestCheckKeys() {
+	assert.NoError(d.T(), CheckKeys(d.keys[:d.threshold], d.prime))
+
+	// x密钥模素数后重复
+	duplicate := append([]code.Key{}, d.keys[:d.threshold-1]...)
+	duplicate = append(duplicate, code.Key{X: new(big.Int).Add(d.keys[0].X, d.prime), Y: d.keys[0].Y})
+	assert.ErrorIs(d.T(), CheckKeys(duplicate, d.prime), DuplicateXKey)
+
+	assert.Error(d.T(), CheckKeys(d.keys[:d.threshold], nil))
+	assert.Error(d.T(), CheckKeys([]code.Key{{X: d.keys[0].X}}, d.prime))
+}
+
+func (d *decryptEncryptSuit) TestConsistentKeys() {
+	all := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	result, err := ConsistentKeys(d.keys, d.threshold, d.prime, 1024)
+	require.NoError(d.T(), err)
+	assert.Equal(d.T(), all, result)
+
+	// 排除y密钥被篡改的密钥对
+	keys := append([]code.Key{}, d.keys...)
+	keys[0] = code.Key{X: keys[0].X, Y: new(big.Int).Add(keys[0].Y, big.NewInt(1))}
+	keys[6] = code.Key{X: keys[6].X, Y: big.NewInt(6)}
+	result, err = ConsistentKeys(keys, d.threshold, d.prime, 1024)
+	require.NoError(d.T(), err)
+	assert.Equal(d.T(), []int{1, 2, 3, 4, 5, 7, 8, 9}, result)
+
+	// 只多出一个密钥对时无法判断哪个被篡改
+	result, err = ConsistentKeys(keys[:d.threshold+1], d.threshold, d.prime, 1024)
+	require.NoError(d.T(), err)
+	assert.Equal(d.T(), all[:d.threshold+1], result)
+
+	// 达到尝试次数上限时无法判断
+	result, err = ConsistentKeys(keys, d.threshold, d.prime, 1)
+	require.NoError(d.T(), err)
+	assert.Equal(d.T(), all, result)
+
+	duplicate := append([]code.Key{}, d.keys...)
+	duplicate[1] = duplicate[0]
+	_, err = ConsistentKeys(duplicate, d.threshold, d.prime, 1024)
+	assert.ErrorIs(d.T(), err, DuplicateXKey)
+}
+
+func (d *decryptEncryptSuit) TestStream() {
+	keyBuffers := make([][2]*bytes.Buffer, d.keysNumber)
+	writers := make([]KeyWriter, 0, d.keysNumber)
+	for i := range keyBuffers {
+		keyBuffers[i] = [2]*bytes.Buffer{{}, {}}
+		writers = append(writers, code.NewXYKeyDecoder(keyBuffers[i][0], keyBuffers[i][1]))
+	}
+	necessary := &bytes.Buffer{}
+	writer, err := NewSplitWriter(d.threshold, d.keysNumber, true, writers, necessary)
+	require.NoError(d.T(), err)
+
+	// 逐字节写入，分段与写入的大小无关
+	for _, b := range []byte(bigSecret) {
+		_, err = writer.Write([]byte{b})
+		require.NoError(d.T(), err)
+	}
+	require.NoError(d.T(), writer.Close())
+	_, err = writer.Write([]byte("after close"))
+	assert.ErrorIs(d.T(), err, WriterClosed)
+
+	combine := func(holders ...int) ([]byte, error) {
+		readers := make([]KeyReader, 0, len(holders))
+		for _, i := range holders {
+			readers = append(readers, code.NewXYKeyEncoder(
+				bytes.NewReader(keyBuffers[i][0].Bytes()), bytes.NewReader(keyBuffers[i][1].Bytes())))
+		}
+		return io.ReadAll(NewCombineReader(readers, bytes.NewReader(necessary.Bytes())))
+	}
+	result, err := combine(9, 2, 5, 0)
+	require.NoError(d.T(), err)
+	assert.Equal(d.T(), bigSecret, string(result))
+
+	// 密钥数量不足时无法通过hash校验
+	_, err = combine(1, 2, 3)
+	assert.ErrorIs(d.T(), err, HashCheckFailed)
+	_, err = combine(1, 1, 2, 3)
+	assert.ErrorIs(d.T(), err, DuplicateXKey)
+}
+
 func TestShamir(t *testing.T) {
 	test := new(decryptEncryptSuit)
 	suite.Run(t, test)
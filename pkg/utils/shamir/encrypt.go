@@ -9,6 +9,7 @@ import (
 	"shamir/pkg/utils/code"
 	"shamir/pkg/utils/compute"
 	"shamir/pkg/utils/log"
+	"shamir/pkg/utils/secure"
 )
 
 const (
@@ -95,6 +96,8 @@ func encrypt(secret *big.Int, threshold, keysNumber int, fast bool) (keys []code
 	if err != nil {
 		return nil, nil, err
 	}
+	// 随机系数与秘密一样可以还原出其他人的密钥，使用后清零
+	defer secure.WipeInts(tmpCoefficients...)
 	coefficients = append(coefficients, tmpCoefficients...)
 
 	xKeys, err := compute.NewRandGenerator(minInt(minPrime, prime)).RandIntListNoRepeat(keysNumber)
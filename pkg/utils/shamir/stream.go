@@ -0,0 +1,234 @@
+package shamir
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/compute"
+	"shamir/pkg/utils/secure"
+)
+
+var (
+	WriterClosed = errors.New("split writer already closed")
+)
+
+// KeyWriter 一个持有人的密钥输出，每加密一段写入一个密钥对，如 code.XYKeyDecoder
+type KeyWriter interface {
+	Write(key code.Key) error
+}
+
+// KeyReader 一个持有人的密钥输入，逐段返回密钥对以及是否是hash值的密钥，如 code.XYKeyEncoder
+type KeyReader interface {
+	Read() (code.Key, bool, error)
+}
+
+// SplitLen 秘密分段的长度，应减去额外的前缀开销
+func SplitLen(fast bool) int {
+	if fast {
+		return compute.GetSecretMaxLen() - 1
+	}
+	return compute.GetSecretMaxLenNoFast() - 1
+}
+
+type splitWriter struct {
+	threshold, number int
+	fast              bool
+	keys              []KeyWriter
+	necessary         *code.KeyDecoder
+
+	// buffer 锁定的内存，第一个字节是 code.EncodeSecret 的前缀，之后是不足一段的数据，
+	// 只保留一段，内存占用与秘密长度无关
+	buffer   *secure.LockedBuffer
+	pending  int
+	splitLen int
+	hash     hash.Hash
+	err      error
+	closed   bool
+}
+
+// NewSplitWriter 写入的秘密每满一段就加密，密钥对写入 keys，素数写入 necessary，
+// Close 时加密剩余的数据和秘密的hash值。Close 不会关闭 keys 和 necessary，出错后的写入都返回第一次的错误
+func NewSplitWriter(threshold, number int, fast bool, keys []KeyWriter, necessary io.Writer) (io.WriteCloser, error) {
+	if threshold < MinThreshold || threshold > number {
+		return nil, fmt.Errorf("invalid threshold %d of %d keys", threshold, number)
+	}
+	if len(keys) != number {
+		return nil, fmt.Errorf("need %d key writers, got %d", number, len(keys))
+	}
+
+	splitLen := SplitLen(fast)
+	buffer, err := secure.NewLockedBuffer(splitLen + 1)
+	if err != nil {
+		return nil, fmt.Errorf("allocate memory failed: %w", err)
+	}
+	buffer.Bytes()[0] = code.SecretPrefix
+	return &splitWriter{
+		threshold: threshold,
+		number:    number,
+		fast:      fast,
+		keys:      keys,
+		necessary: code.NewKeyDecoder(necessary),
+		buffer:    buffer,
+		splitLen:  splitLen,
+		hash:      sha256.New(),
+	}, nil
+}
+
+func (w *splitWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, WriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	data := w.buffer.Bytes()[1:]
+	for len(p) > 0 {
+		n := copy(data[w.pending:], p)
+		w.pending += n
+		p = p[n:]
+		written += n
+
+		if w.pending == w.splitLen {
+			if w.err = w.flush(); w.err != nil {
+				return written, w.err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close 加密剩余的数据和hash值，之后清零释放缓存
+func (w *splitWriter) Close() error {
+	if w.closed {
+		return WriterClosed
+	}
+	w.closed = true
+	defer w.buffer.Destroy()
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.pending > 0 {
+		if w.err = w.flush(); w.err != nil {
+			return w.err
+		}
+	}
+	w.err = w.encrypt(new(big.Int).SetBytes(w.hash.Sum(nil)))
+	return w.err
+}
+
+// flush 加密缓存的一段数据，之后清零缓存和秘密
+func (w *splitWriter) flush() error {
+	chunk := w.buffer.Bytes()[:w.pending+1]
+	w.hash.Write(chunk[1:])
+	secret := new(big.Int).SetBytes(chunk)
+	defer secure.WipeInt(secret)
+
+	secure.Wipe(chunk[1:])
+	w.pending = 0
+	return w.encrypt(secret)
+}
+
+func (w *splitWriter) encrypt(secret *big.Int) error {
+	keys, prime, err := Encrypt(secret, w.threshold, w.number, w.fast)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if err = w.keys[i].Write(key); err != nil {
+			return err
+		}
+	}
+	return w.necessary.Write(prime)
+}
+
+type combineReader struct {
+	keys      []KeyReader
+	necessary *code.KeyEncoder
+
+	// buffer 解密出的一段秘密，SecretDecoder 晚一段写入，最后一段hash值不会写入，读出后清零
+	buffer  *secure.Buffer
+	decoder *code.SecretDecoder
+	done    bool
+	err     error
+}
+
+// NewCombineReader 从 keys 和 necessary 中逐段解密秘密，读到最后一段时校验hash值，
+// 校验失败返回 HashCheckFailed 而不是 io.EOF，此时已经读出的数据应当丢弃
+func NewCombineReader(keys []KeyReader, necessary io.Reader) io.Reader {
+	buffer := &secure.Buffer{}
+	return &combineReader{
+		keys:      keys,
+		necessary: code.NewKeyEncoder(necessary),
+		buffer:    buffer,
+		decoder:   code.NewSecretDecoder(buffer),
+	}
+}
+
+func (r *combineReader) Read(p []byte) (int, error) {
+	for r.buffer.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+
+	return r.buffer.Read(p)
+}
+
+// next 解密下一段秘密，最后一段是hash值，校验后结束
+func (r *combineReader) next() error {
+	keys := make([]code.Key, 0, len(r.keys))
+	isHash := false
+	for i, reader := range r.keys {
+		key, ok, err := reader.Read()
+		if err != nil {
+			return err
+		}
+		if i != 0 && isHash != ok {
+			return fmt.Errorf("keys not match")
+		}
+
+		isHash = ok
+		keys = append(keys, key)
+	}
+
+	prime, ok, err := r.necessary.Read()
+	if err != nil {
+		return err
+	}
+	if isHash != ok {
+		return fmt.Errorf("necessary key not match")
+	}
+
+	if err = CheckKeys(keys, prime); err != nil {
+		return err
+	}
+	secret, err := Decrypt(keys, prime)
+	if err != nil {
+		return err
+	}
+	// 密钥有误时解密出的数据可能无法写入，同样视为校验失败
+	if err = r.decoder.Write(secret); err != nil {
+		return fmt.Errorf("%w: %v", HashCheckFailed, err)
+	}
+
+	if isHash {
+		if err = r.decoder.HashCheck(); err != nil {
+			return HashCheckFailed
+		}
+		r.done = true
+	}
+	return nil
+}
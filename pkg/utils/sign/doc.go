@@ -0,0 +1,4 @@
+// Package sign 用于发牌人(dealer)对份额签名，持有人可以据此识别伪造的份额
+// 签名覆盖份额文件的原始内容(加密后的信封)、必须密钥和清单，使用 Ed25519 算法
+// 使用 Sign 生成签名，使用 Verify 或 Check 校验签名 /*
+package sign
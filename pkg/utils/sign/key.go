@@ -0,0 +1,86 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const maxKeyFileBytes = 1 << 16
+
+// LoadPrivateKey 读取 Ed25519 私钥，支持 PKCS#8 PEM 格式和 OpenSSH 格式
+func LoadPrivateKey(file string) (ed25519.PrivateKey, error) {
+	data, err := readKeyFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ssh.ParseRawPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %q failed: %w", file, err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *ed25519.PrivateKey:
+		return *k, nil
+	default:
+		return nil, fmt.Errorf("invalid private key %q, only support ed25519", file)
+	}
+}
+
+// LoadPublicKey 读取 Ed25519 公钥，支持 PKIX PEM 格式和 ssh-ed25519 格式
+func LoadPublicKey(file string) (ed25519.PublicKey, error) {
+	data, err := readKeyFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		key, e := x509.ParsePKIXPublicKey(block.Bytes)
+		if e != nil {
+			return nil, fmt.Errorf("parse public key %q failed: %w", file, e)
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid public key %q, only support ed25519", file)
+		}
+		return publicKey, nil
+	}
+
+	sshKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key %q failed: %w", file, err)
+	}
+	cryptoKey, ok := sshKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key %q, only support ed25519", file)
+	}
+	publicKey, ok := cryptoKey.CryptoPublicKey().(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key %q, only support ed25519", file)
+	}
+	return publicKey, nil
+}
+
+func readKeyFile(file string) ([]byte, error) {
+	file = filepath.Clean(file)
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open key file %q failed: %w", file, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxKeyFileBytes))
+	if err != nil {
+		return nil, fmt.Errorf("read key file %q failed: %w", file, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,176 @@
+package sign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	signatureVersion = "shamir-signature-v1"
+	holderField      = "holder"
+	keyField         = "key"
+	signatureField   = "signature"
+	fieldSplit       = ": "
+)
+
+// Status 份额签名的校验结果
+type Status string
+
+const (
+	// Unsigned 份额没有签名
+	Unsigned Status = "unsigned"
+	// Valid 签名有效，且是受信任的发牌人签名
+	Valid Status = "valid"
+	// Untrusted 签名有效，但不是受信任的发牌人签名
+	Untrusted Status = "untrusted"
+	// Invalid 签名与份额内容不匹配
+	Invalid Status = "invalid"
+)
+
+var (
+	InvalidSignature = errors.New("invalid signature")
+	UntrustedSigner  = errors.New("share is signed by untrusted dealer")
+)
+
+// Envelope 签名覆盖的内容，X、Y、Necessary 和 Manifest 分别是对应文件原始内容的 SHA-256 值，
+// 没有清单时 Manifest 为空
+type Envelope struct {
+	Holder    string
+	X         []byte
+	Y         []byte
+	Necessary []byte
+	Manifest  []byte
+}
+
+// Digest 计算信封的待签名消息，各部分是定长的摘要，Manifest 为空或在最后，直接拼接不会产生歧义
+func (e *Envelope) Digest() []byte {
+	message := bytes.NewBufferString(signatureVersion + "\n" + e.Holder + "\n")
+	for _, part := range [][]byte{e.X, e.Y, e.Necessary, e.Manifest} {
+		message.Write(part)
+	}
+	return message.Bytes()
+}
+
+// HashFile 计算文件原始内容的 SHA-256 值
+func HashFile(file string) ([]byte, error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, fmt.Errorf("open file %q failed: %w", file, err)
+	}
+	defer f.Close()
+
+	sum, err := HashReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("hash file %q failed: %w", file, err)
+	}
+	return sum, nil
+}
+
+// HashReader 计算读取到的全部内容的 SHA-256 值
+func HashReader(reader io.Reader) ([]byte, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// Signature 发牌人对一个份额的签名
+type Signature struct {
+	Holder    string
+	PublicKey ed25519.PublicKey
+	Value     []byte
+}
+
+// Sign 使用发牌人私钥对份额签名
+func Sign(key ed25519.PrivateKey, envelope *Envelope) *Signature {
+	return &Signature{
+		Holder:    envelope.Holder,
+		PublicKey: key.Public().(ed25519.PublicKey),
+		Value:     ed25519.Sign(key, envelope.Digest()),
+	}
+}
+
+// Verify 校验签名，签名必须由 trusted 公钥签发且与份额内容一致
+func Verify(trusted ed25519.PublicKey, envelope *Envelope, signature *Signature) error {
+	switch Check(trusted, envelope, signature) {
+	case Valid:
+		return nil
+	case Unsigned:
+		return fmt.Errorf("%w: share of holder %s is unsigned", InvalidSignature, envelope.Holder)
+	case Untrusted:
+		return fmt.Errorf("%w: holder %s", UntrustedSigner, envelope.Holder)
+	default:
+		return fmt.Errorf("%w: share of holder %s does not match signature", InvalidSignature, envelope.Holder)
+	}
+}
+
+// Check 返回签名的状态，trusted 为空时只校验签名本身是否有效
+func Check(trusted ed25519.PublicKey, envelope *Envelope, signature *Signature) Status {
+	if signature == nil {
+		return Unsigned
+	}
+	if signature.Holder != envelope.Holder || len(signature.PublicKey) != ed25519.PublicKeySize ||
+		!ed25519.Verify(signature.PublicKey, envelope.Digest(), signature.Value) {
+		return Invalid
+	}
+	if trusted != nil && !trusted.Equal(signature.PublicKey) {
+		return Untrusted
+	}
+
+	return Valid
+}
+
+// Fingerprint 返回公钥的指纹，用于展示签名者
+func Fingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// MarshalText 将签名编码为文本格式
+func (s *Signature) MarshalText() ([]byte, error) {
+	builder := strings.Builder{}
+	builder.WriteString(signatureVersion + "\n")
+	builder.WriteString(holderField + fieldSplit + s.Holder + "\n")
+	builder.WriteString(keyField + fieldSplit + base64.StdEncoding.EncodeToString(s.PublicKey) + "\n")
+	builder.WriteString(signatureField + fieldSplit + base64.StdEncoding.EncodeToString(s.Value) + "\n")
+	return []byte(builder.String()), nil
+}
+
+// UnmarshalText 从文本格式解析签名
+func (s *Signature) UnmarshalText(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != signatureVersion {
+		return fmt.Errorf("%w: unknown signature version", InvalidSignature)
+	}
+
+	fields := make(map[string]string, 3)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), fieldSplit)
+		if ok {
+			fields[name] = value
+		}
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(fields[keyField])
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: invalid public key", InvalidSignature)
+	}
+	value, err := base64.StdEncoding.DecodeString(fields[signatureField])
+	if err != nil || len(value) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: invalid signature value", InvalidSignature)
+	}
+
+	s.Holder, s.PublicKey, s.Value = fields[holderField], publicKey, value
+	return nil
+}
@@ -0,0 +1,90 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sum(data string) []byte {
+	result := sha256.Sum256([]byte(data))
+	return result[:]
+}
+
+func newEnvelope() *Envelope {
+	return &Envelope{
+		Holder:    "0",
+		X:         sum("ThisIsABigNumber1_ThisIsABigNumber2"),
+		Y:         sum("ThisIsABigNumber3_ThisIsABigNumber4"),
+		Necessary: sum("ThisIsABigNumber5_ThisIsABigNumber6"),
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	envelope := newEnvelope()
+	signature := Sign(privateKey, envelope)
+	data, err := signature.MarshalText()
+	require.NoError(t, err)
+
+	parsed := &Signature{}
+	require.NoError(t, parsed.UnmarshalText(data))
+	assert.NoError(t, Verify(publicKey, envelope, parsed))
+	assert.ErrorIs(t, Verify(otherKey, envelope, parsed), UntrustedSigner)
+	assert.ErrorIs(t, Verify(publicKey, envelope, nil), InvalidSignature)
+	assert.Equal(t, Valid, Check(nil, envelope, parsed))
+
+	// 修改必须密钥后签名失效
+	envelope.Necessary = sum("ThisIsABigNumber7")
+	assert.Equal(t, Invalid, Check(publicKey, envelope, parsed))
+}
+
+func TestSignManifest(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	envelope := newEnvelope()
+	envelope.Manifest = sum(`{"threshold": 2}`)
+	signature := Sign(privateKey, envelope)
+	assert.NoError(t, Verify(publicKey, envelope, signature))
+
+	// 替换或删除清单后签名失效
+	envelope.Manifest = sum(`{"threshold": 3}`)
+	assert.Equal(t, Invalid, Check(publicKey, envelope, signature))
+	envelope.Manifest = nil
+	assert.Equal(t, Invalid, Check(publicKey, envelope, signature))
+}
+
+func TestLoadKey(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	privateFile := filepath.Join(dir, "dealer.key")
+	require.NoError(t, os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	der, err = x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	publicFile := filepath.Join(dir, "dealer.pub")
+	require.NoError(t, os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	loadedPrivate, err := LoadPrivateKey(privateFile)
+	require.NoError(t, err)
+	loadedPublic, err := LoadPublicKey(publicFile)
+	require.NoError(t, err)
+	assert.True(t, publicKey.Equal(loadedPublic))
+	assert.True(t, privateKey.Equal(loadedPrivate))
+}
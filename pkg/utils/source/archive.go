@@ -0,0 +1,193 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	slashpath "path"
+	"strings"
+)
+
+// 支持的归档格式
+const (
+	zipExt   = ".zip"
+	tarExt   = ".tar"
+	tarGzExt = ".tar.gz"
+	tgzExt   = ".tgz"
+)
+
+// IsArchive 根据扩展名判断文件是否是支持的归档
+func IsArchive(file string) bool {
+	name := strings.ToLower(file)
+	for _, ext := range []string{zipExt, tarExt, tarGzExt, tgzExt} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func isGzip(file string) bool {
+	name := strings.ToLower(file)
+	return strings.HasSuffix(name, tarGzExt) || strings.HasSuffix(name, tgzExt)
+}
+
+func collectArchive(archive string) ([]*File, error) {
+	if strings.HasSuffix(strings.ToLower(archive), zipExt) {
+		return collectZip(archive)
+	}
+	return collectTar(archive)
+}
+
+// newArchiveFile 归档中的文件，member 为其在归档中的路径，位于归档根目录时位置即为归档本身
+func newArchiveFile(archive, member string, open func() (io.ReadCloser, error)) *File {
+	dir := archive
+	if slashpath.Dir(member) != "." {
+		dir = archive + ":" + slashpath.Dir(member)
+	}
+	return &File{
+		Name:   slashpath.Base(member),
+		Dir:    dir,
+		source: archive + ":" + member,
+		open:   open,
+	}
+}
+
+func collectZip(archive string) ([]*File, error) {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open zip archive %q failed: %w", archive, err)
+	}
+	defer reader.Close()
+
+	var result []*File
+	for _, f := range reader.File {
+		member := slashpath.Clean(f.Name)
+		if !f.Mode().IsRegular() || !isKeyFile(slashpath.Base(member)) {
+			continue
+		}
+
+		name := f.Name
+		result = append(result, newArchiveFile(archive, member, func() (io.ReadCloser, error) {
+			return openZipMember(archive, name)
+		}))
+	}
+	return result, nil
+}
+
+func openZipMember(archive, name string) (io.ReadCloser, error) {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open zip archive %q failed: %w", archive, err)
+	}
+
+	for _, f := range reader.File {
+		if f.Name != name {
+			continue
+		}
+		member, e := f.Open()
+		if e != nil {
+			_ = reader.Close()
+			return nil, fmt.Errorf("open %s:%s failed: %w", archive, name, e)
+		}
+		return &readCloser{Reader: member, closers: []io.Closer{member, reader}}, nil
+	}
+
+	_ = reader.Close()
+	return nil, fmt.Errorf("%s:%s not exist", archive, name)
+}
+
+func collectTar(archive string) ([]*File, error) {
+	reader, closers, err := openTar(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAll(closers)
+
+	var result []*File
+	for {
+		header, e := reader.Next()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return nil, fmt.Errorf("read tar archive %q failed: %w", archive, e)
+		}
+
+		member := slashpath.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || !isKeyFile(slashpath.Base(member)) {
+			continue
+		}
+
+		name := header.Name
+		result = append(result, newArchiveFile(archive, member, func() (io.ReadCloser, error) {
+			return openTarMember(archive, name)
+		}))
+	}
+	return result, nil
+}
+
+// openTarMember tar 归档无法随机读取，每次打开都从头查找
+func openTarMember(archive, name string) (io.ReadCloser, error) {
+	reader, closers, err := openTar(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		header, e := reader.Next()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			closeAll(closers)
+			return nil, fmt.Errorf("read tar archive %q failed: %w", archive, e)
+		}
+		if header.Name == name {
+			return &readCloser{Reader: reader, closers: closers}, nil
+		}
+	}
+
+	closeAll(closers)
+	return nil, fmt.Errorf("%s:%s not exist", archive, name)
+}
+
+func openTar(archive string) (*tar.Reader, []io.Closer, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open tar archive %q failed: %w", archive, err)
+	}
+	if !isGzip(archive) {
+		return tar.NewReader(f), []io.Closer{f}, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, fmt.Errorf("open gzip archive %q failed: %w", archive, err)
+	}
+	return tar.NewReader(gz), []io.Closer{gz, f}, nil
+}
+
+// readCloser 读取归档中的文件，关闭时依次关闭打开的归档
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	return closeAll(r.closers)
+}
+
+func closeAll(closers []io.Closer) error {
+	var err error
+	for _, closer := range closers {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,4 @@
+// Package source 用于从多个输入中收集 shamir 密钥文件
+// 输入可以是目录(递归查找)、单个密钥文件、.zip/.tar/.tar.gz 归档或通配符
+// 使用 Collect 收集文件，使用 File.Open 读取文件内容 /*
+package source
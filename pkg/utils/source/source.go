@@ -0,0 +1,140 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shamir/pkg/utils/path"
+)
+
+// File 从输入中找到的一个文件
+type File struct {
+	// Name 文件名，如 shamir_x-key_1
+	Name string
+	// Dir 文件所在的位置，同一位置中的x、y密钥文件才能组成密钥对，归档中的文件以 "归档路径:目录" 表示
+	Dir string
+
+	source string
+	open   func() (io.ReadCloser, error)
+}
+
+// Source 返回文件的来源，用于提示用户
+func (f *File) Source() string {
+	return f.source
+}
+
+// Open 打开文件，每次调用都从头读取
+func (f *File) Open() (io.ReadCloser, error) {
+	return f.open()
+}
+
+func newOSFile(file string) *File {
+	return &File{
+		Name:   filepath.Base(file),
+		Dir:    filepath.Dir(file),
+		source: file,
+		open: func() (io.ReadCloser, error) {
+			return os.Open(file)
+		},
+	}
+}
+
+// Collect 从多个输入中收集文件，相同来源的文件只保留一份。
+// 目录中递归查找以 path.KeyFilePrefix 开头的文件和清单，归档中同样只取这些文件，
+// 直接指定的文件不限制文件名。返回的文件按输入顺序排列，同一输入中按路径排序
+func Collect(inputs []string) ([]*File, error) {
+	var result []*File
+	seen := make(map[string]struct{})
+	for _, input := range inputs {
+		files, err := collect(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if _, ok := seen[file.source]; ok {
+				continue
+			}
+			seen[file.source] = struct{}{}
+			result = append(result, file)
+		}
+	}
+
+	return result, nil
+}
+
+func collect(input string) ([]*File, error) {
+	if !hasMeta(input) {
+		return collectPath(filepath.Clean(input))
+	}
+
+	matches, err := filepath.Glob(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input pattern %q: %w", input, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("input pattern %q matches nothing", input)
+	}
+
+	var result []*File
+	for _, match := range matches {
+		files, e := collectPath(match)
+		if e != nil {
+			return nil, e
+		}
+		result = append(result, files...)
+	}
+	return result, nil
+}
+
+func collectPath(input string) ([]*File, error) {
+	stat, err := os.Stat(input)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("input path %q not exist", input)
+		}
+		return nil, fmt.Errorf("stat input path %q failed: %w", input, err)
+	}
+
+	switch {
+	case stat.IsDir():
+		return collectDir(input)
+	case IsArchive(input):
+		return collectArchive(input)
+	default:
+		return []*File{newOSFile(input)}, nil
+	}
+}
+
+// collectDir 递归查找目录中的密钥文件，filepath.WalkDir 按文件名顺序遍历
+func collectDir(dir string) ([]*File, error) {
+	var result []*File
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || !isKeyFile(entry.Name()) {
+			return nil
+		}
+
+		result = append(result, newOSFile(file))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read dir %q failed: %w", dir, err)
+	}
+
+	return result, nil
+}
+
+func isKeyFile(name string) bool {
+	return strings.HasPrefix(name, path.KeyFilePrefix) || name == path.ManifestFileName
+}
+
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[`)
+}
@@ -0,0 +1,114 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var members = map[string]string{
+	"keys/shamir_x-key_1": "x1",
+	"keys/shamir_y-key_1": "y1",
+	"keys/readme.txt":     "not a key",
+}
+
+func readAll(t *testing.T, file *File) string {
+	reader, err := file.Open()
+	require.NoError(t, err)
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func writeTar(t *testing.T, file string, gz bool) {
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var w io.Writer = f
+	if gz {
+		gzWriter := gzip.NewWriter(f)
+		defer gzWriter.Close()
+		w = gzWriter
+	}
+	tw := tar.NewWriter(w)
+	for _, name := range []string{"keys/readme.txt", "keys/shamir_x-key_1", "keys/shamir_y-key_1"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(members[name])),
+			Typeflag: tar.TypeReg}))
+		_, err = tw.Write([]byte(members[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+}
+
+func writeZip(t *testing.T, file string) {
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"keys/readme.txt", "keys/shamir_x-key_1", "keys/shamir_y-key_1"} {
+		w, e := zw.Create(name)
+		require.NoError(t, e)
+		_, e = w.Write([]byte(members[name]))
+		require.NoError(t, e)
+	}
+	require.NoError(t, zw.Close())
+}
+
+func TestCollectArchive(t *testing.T) {
+	dir := t.TempDir()
+	writeTar(t, filepath.Join(dir, "a.tar"), false)
+	writeTar(t, filepath.Join(dir, "b.tar.gz"), true)
+	writeZip(t, filepath.Join(dir, "c.zip"))
+
+	for _, name := range []string{"a.tar", "b.tar.gz", "c.zip"} {
+		archive := filepath.Join(dir, name)
+		files, err := Collect([]string{archive})
+		require.NoError(t, err)
+		require.Len(t, files, 2, name)
+
+		assert.Equal(t, "shamir_x-key_1", files[0].Name)
+		assert.Equal(t, archive+":keys", files[0].Dir)
+		assert.Equal(t, archive+":keys/shamir_x-key_1", files[0].Source())
+		assert.Equal(t, "x1", readAll(t, files[0]))
+		assert.Equal(t, "y1", readAll(t, files[1]))
+		// 可以重复读取
+		assert.Equal(t, "x1", readAll(t, files[0]))
+	}
+}
+
+func TestCollectDirAndGlob(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "usb1", "keys"), 0700))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "usb2"), 0700))
+	xFile := filepath.Join(dir, "usb1", "keys", "shamir_x-key_1")
+	require.NoError(t, os.WriteFile(xFile, []byte("x1"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "usb1", "keys", "other"), []byte("other"), 0600))
+	share := filepath.Join(dir, "usb2", "share.txt")
+	require.NoError(t, os.WriteFile(share, []byte("1-abcd"), 0600))
+
+	// 目录递归查找，直接指定的文件不限制文件名，重复的来源只保留一份
+	files, err := Collect([]string{filepath.Join(dir, "usb1"), share, filepath.Join(dir, "usb*", "keys", "*")})
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, xFile, files[0].Source())
+	assert.Equal(t, filepath.Join(dir, "usb1", "keys"), files[0].Dir)
+	assert.Equal(t, share, files[1].Source())
+	assert.Equal(t, "other", files[2].Name)
+	assert.Equal(t, "x1", readAll(t, files[0]))
+
+	_, err = Collect([]string{filepath.Join(dir, "not-exist")})
+	assert.Error(t, err)
+	_, err = Collect([]string{filepath.Join(dir, "*.zip")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package ssss
+
+import (
+	"encoding/binary"
+	"math/big"
+)
+
+const (
+	// 安全级别不低于 64 位时 ssss 才会使用扩散层
+	minDiffusionDegree = 64
+	diffusionRounds    = 40
+	xteaDelta          = 0x9E3779B9
+	xteaRounds         = 32
+)
+
+// ssss 在拆分前会用 XTEA 对秘密做一次可逆的扩散，合并后再逆向还原
+// 这样即使秘密本身结构简单，每个份额的每一位也都依赖于整个秘密
+
+func encipherBlock(v *[2]uint32) {
+	var sum uint32
+	for i := 0; i < xteaRounds; i++ {
+		v[0] += (((v[1] << 4) ^ (v[1] >> 5)) + v[1]) ^ sum
+		sum += xteaDelta
+		v[1] += (((v[0] << 4) ^ (v[0] >> 5)) + v[0]) ^ sum
+	}
+}
+
+func decipherBlock(v *[2]uint32) {
+	var sum uint32 = 0xC6EF3720 // xteaDelta * xteaRounds 截断为32位
+	for i := 0; i < xteaRounds; i++ {
+		v[1] -= (((v[0] << 4) ^ (v[0] >> 5)) + v[0]) ^ sum
+		sum -= xteaDelta
+		v[0] -= (((v[1] << 4) ^ (v[1] >> 5)) + v[1]) ^ sum
+	}
+}
+
+// 以 idx 为起点，循环地取 8 字节作为一个分组进行处理
+func encodeSlice(data []byte, idx int, process func(v *[2]uint32)) {
+	var v [2]uint32
+	var block [4]byte
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 4; j++ {
+			block[j] = data[(idx+4*i+j)%len(data)]
+		}
+		v[i] = binary.BigEndian.Uint32(block[:])
+	}
+
+	process(&v)
+
+	for i := 0; i < 2; i++ {
+		binary.BigEndian.PutUint32(block[:], v[i])
+		for j := 0; j < 4; j++ {
+			data[(idx+4*i+j)%len(data)] = block[j]
+		}
+	}
+}
+
+// ssss 按 16 位字导出大整数：低位字在前，字内高字节在前
+// 位数不是 16 的倍数时，最高字的低字节挪到高字节的位置参与运算
+func exportIndex(p, degree int) int {
+	if degree%16 == 8 && p == degree/8-1 {
+		return p
+	}
+	if p%2 == 0 {
+		return p + 1
+	}
+	return p - 1
+}
+
+func exportBytes(x *big.Int, degree int) []byte {
+	n := degree / 8
+	le := make([]byte, n)
+	// FillBytes 为大端序，转换为小端序索引
+	be := x.FillBytes(make([]byte, n))
+	for i := range be {
+		le[n-1-i] = be[i]
+	}
+
+	data := make([]byte, n)
+	for p := range data {
+		data[p] = le[exportIndex(p, degree)]
+	}
+	return data
+}
+
+func importBytes(data []byte, degree int) *big.Int {
+	n := degree / 8
+	be := make([]byte, n)
+	for p := range data {
+		be[n-1-exportIndex(p, degree)] = data[p]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+func diffuse(x *big.Int, degree int) *big.Int {
+	data := exportBytes(x, degree)
+	for i := 0; i < diffusionRounds*len(data); i += 2 {
+		encodeSlice(data, i, encipherBlock)
+	}
+	return importBytes(data, degree)
+}
+
+func undiffuse(x *big.Int, degree int) *big.Int {
+	data := exportBytes(x, degree)
+	for i := diffusionRounds*len(data) - 2; i >= 0; i -= 2 {
+		encodeSlice(data, i, decipherBlock)
+	}
+	return importBytes(data, degree)
+}
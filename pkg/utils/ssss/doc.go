@@ -0,0 +1,5 @@
+// Package ssss 兼容 Unix ssss 工具(ssss-split / ssss-combine)的秘密共享方案
+// ssss 在 GF(2^k) 有限域上运算，k 为安全级别，取值为 8 的倍数，最大 1024
+// 使用 Split 将秘密拆分为 "i-hex" 格式的份额，可以被 ssss-combine 合并
+// 使用 Combine 合并 ssss-split 生成的份额，恢复秘密
+package ssss
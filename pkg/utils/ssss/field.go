@@ -0,0 +1,134 @@
+package ssss
+
+import (
+	"fmt"
+	"math/big"
+)
+
+const (
+	MinDegree = 8
+	MaxDegree = 1024
+)
+
+// GF(2) 上的不可约多项式系数，与 ssss 保持一致
+// 第 i 组三个数 (a, b, c) 表示多项式 x^(8*(i+1)) + x^a + x^b + x^c + 1
+var irredCoeff = []uint8{
+	4, 3, 1, 5, 3, 1, 4, 3, 1, 7, 3, 2, 5, 4, 3, 5, 3, 2, 7, 4, 2, 4, 3, 1, 10, 9, 3, 9, 4, 2, 7, 6, 2, 10, 9,
+	6, 4, 3, 1, 5, 4, 3, 4, 3, 1, 7, 2, 1, 5, 3, 2, 7, 4, 2, 6, 3, 2, 5, 3, 2, 15, 3, 2, 11, 3, 2, 9, 8, 7, 7,
+	2, 1, 5, 3, 2, 9, 3, 1, 7, 3, 1, 9, 8, 3, 9, 4, 2, 8, 5, 3, 15, 14, 10, 10, 5, 2, 9, 6, 2, 9, 3, 2, 9, 5,
+	2, 11, 10, 1, 7, 3, 2, 11, 2, 1, 9, 7, 4, 4, 3, 1, 8, 3, 1, 7, 4, 1, 7, 2, 1, 13, 11, 6, 5, 3, 2, 7, 3, 2,
+	8, 7, 5, 12, 3, 2, 13, 10, 6, 5, 3, 2, 5, 3, 2, 9, 5, 2, 9, 7, 2, 13, 4, 3, 4, 3, 1, 11, 6, 4, 18, 9, 6,
+	19, 18, 13, 11, 3, 2, 15, 9, 6, 4, 3, 1, 16, 5, 2, 15, 14, 6, 8, 5, 2, 15, 11, 2, 11, 6, 2, 7, 5, 3, 8,
+	3, 1, 19, 16, 9, 11, 9, 6, 15, 7, 6, 13, 4, 3, 14, 13, 3, 13, 6, 3, 9, 5, 2, 19, 13, 6, 19, 10, 3, 11,
+	6, 5, 9, 2, 1, 14, 3, 2, 13, 3, 1, 7, 5, 4, 11, 9, 8, 11, 6, 5, 23, 16, 9, 19, 14, 6, 23, 10, 2, 8, 3,
+	2, 5, 4, 3, 9, 6, 4, 4, 3, 2, 13, 8, 6, 13, 11, 1, 13, 10, 3, 11, 6, 5, 19, 17, 4, 15, 14, 7, 13, 9, 6,
+	9, 7, 3, 9, 7, 1, 14, 3, 2, 11, 8, 2, 11, 6, 4, 13, 5, 2, 11, 5, 1, 11, 4, 1, 19, 10, 3, 21, 10, 6, 13,
+	3, 1, 15, 7, 5, 19, 18, 10, 7, 5, 3, 12, 7, 2, 7, 5, 1, 14, 9, 6, 10, 3, 2, 15, 13, 12, 12, 11, 9, 16,
+	9, 7, 12, 9, 3, 9, 5, 2, 17, 10, 6, 24, 9, 3, 17, 15, 13, 5, 4, 3, 19, 17, 8, 15, 6, 3, 19, 6, 1,
+}
+
+// Field 有限域 GF(2^degree)，域中元素用 big.Int 的二进制位表示多项式系数
+type Field struct {
+	degree int
+	poly   *big.Int
+}
+
+// IsValidDegree 返回安全级别是否合法，需要是 [8, 1024] 区间内 8 的倍数
+func IsValidDegree(degree int) bool {
+	return degree >= MinDegree && degree <= MaxDegree && degree%8 == 0
+}
+
+// NewField 生成 GF(2^degree) 有限域
+func NewField(degree int) (*Field, error) {
+	if !IsValidDegree(degree) {
+		return nil, fmt.Errorf("invalid security level %d, should be multiple of 8 in [%d, %d]",
+			degree, MinDegree, MaxDegree)
+	}
+
+	poly := new(big.Int)
+	poly.SetBit(poly, degree, 1)
+	for _, bit := range irredCoeff[3*(degree/8-1) : 3*(degree/8)] {
+		poly.SetBit(poly, int(bit), 1)
+	}
+	poly.SetBit(poly, 0, 1)
+
+	return &Field{
+		degree: degree,
+		poly:   poly,
+	}, nil
+}
+
+// Degree 返回有限域的位数，即安全级别
+func (f *Field) Degree() int {
+	return f.degree
+}
+
+// Add 计算 x + y，GF(2^k) 中加法和减法都是异或
+func (f *Field) Add(x, y *big.Int) *big.Int {
+	return new(big.Int).Xor(x, y)
+}
+
+// Mul 计算 x * y mod poly
+func (f *Field) Mul(x, y *big.Int) *big.Int {
+	b := new(big.Int).Set(x)
+	z := new(big.Int)
+	if y.Bit(0) == 1 {
+		z.Set(b)
+	}
+
+	for i := 1; i < f.degree; i++ {
+		b.Lsh(b, 1)
+		if b.Bit(f.degree) == 1 {
+			b.Xor(b, f.poly)
+		}
+		if y.Bit(i) == 1 {
+			z.Xor(z, b)
+		}
+	}
+
+	return z
+}
+
+// Invert 计算 x^(-1) mod poly，x 不能为0
+func (f *Field) Invert(x *big.Int) (*big.Int, error) {
+	if x.Sign() == 0 {
+		return nil, fmt.Errorf("invert zero in GF(2^%d)", f.degree)
+	}
+
+	// GF(2)[x] 上的扩展欧几里得算法
+	u, v := new(big.Int).Set(x), new(big.Int).Set(f.poly)
+	z, g := big.NewInt(1), big.NewInt(0)
+	h := new(big.Int)
+	for u.Cmp(big.NewInt(1)) != 0 {
+		i := u.BitLen() - v.BitLen()
+		if i < 0 {
+			u, v = v, u
+			z, g = g, z
+			i = -i
+		}
+		u.Xor(u, h.Lsh(v, uint(i)))
+		z.Xor(z, h.Lsh(g, uint(i)))
+	}
+
+	return z, nil
+}
+
+// Horner 计算 ssss 使用的首一多项式 f(x) = x^n + c[n-1]*x^(n-1) + ... + c[0]
+func (f *Field) Horner(coefficients []*big.Int, x *big.Int) *big.Int {
+	y := new(big.Int).Set(x)
+	for i := len(coefficients) - 1; i > 0; i-- {
+		y = f.Mul(f.Add(y, coefficients[i]), x)
+	}
+
+	return f.Add(y, coefficients[0])
+}
+
+// Pow 计算 x^n
+func (f *Field) Pow(x *big.Int, n int) *big.Int {
+	result := big.NewInt(1)
+	for i := 0; i < n; i++ {
+		result = f.Mul(result, x)
+	}
+
+	return result
+}
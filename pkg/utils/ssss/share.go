@@ -0,0 +1,76 @@
+package ssss
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	shareSplit = "-"
+	hexBase    = 16
+)
+
+// Share ssss 格式的份额，字符串形式为 "[token-]index-hex"
+type Share struct {
+	Token string
+	// Index 即份额的x坐标，从1开始
+	Index int
+	Y     *big.Int
+	// Degree 安全级别，由hex部分的长度决定
+	Degree int
+	// 序号的宽度，ssss-split 会按 n 的位数在序号前补0
+	width int
+}
+
+// ParseShare 解析 ssss 格式的份额字符串
+func ParseShare(share string) (*Share, error) {
+	share = strings.TrimSpace(share)
+	parts := strings.Split(share, shareSplit)
+	var token string
+	switch len(parts) {
+	case 2:
+	case 3:
+		token, parts = parts[0], parts[1:]
+	default:
+		return nil, fmt.Errorf("%w: invalid syntax %q", InvalidShare, share)
+	}
+
+	index, err := strconv.Atoi(parts[0])
+	if err != nil || index <= 0 {
+		return nil, fmt.Errorf("%w: invalid share index %q", InvalidShare, parts[0])
+	}
+
+	degree := 4 * len(parts[1])
+	if !IsValidDegree(degree) {
+		return nil, fmt.Errorf("%w: share has illegal length", InvalidShare)
+	}
+
+	y, ok := new(big.Int).SetString(parts[1], hexBase)
+	if !ok || y.Sign() < 0 {
+		return nil, fmt.Errorf("%w: invalid hex share %q", InvalidShare, parts[1])
+	}
+
+	return &Share{
+		Token:  token,
+		Index:  index,
+		Y:      y,
+		Degree: degree,
+		width:  len(parts[0]),
+	}, nil
+}
+
+// String 输出与 ssss-split 相同格式的份额字符串
+func (s *Share) String() string {
+	builder := strings.Builder{}
+	if s.Token != "" {
+		builder.WriteString(s.Token)
+		builder.WriteString(shareSplit)
+	}
+
+	builder.WriteString(fmt.Sprintf("%0*d", s.width, s.Index))
+	builder.WriteString(shareSplit)
+	builder.WriteString(fmt.Sprintf("%0*s", s.Degree/4, s.Y.Text(hexBase)))
+	return builder.String()
+}
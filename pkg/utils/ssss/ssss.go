@@ -0,0 +1,180 @@
+package ssss
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MinThreshold = 2
+	// MaxSecretLen 最大安全级别下秘密的字节数
+	MaxSecretLen = MaxDegree / 8
+)
+
+var (
+	InvalidShare   = errors.New("invalid ssss share")
+	SharesNotMatch = errors.New("ssss shares not match")
+)
+
+type config struct {
+	degree    int
+	token     string
+	diffusion bool
+}
+
+type Option func(conf *config)
+
+// WithSecurity 指定安全级别，默认使用秘密的位数
+func WithSecurity(degree int) Option {
+	return func(conf *config) {
+		conf.degree = degree
+	}
+}
+
+// WithToken 给份额加上 token 前缀，对应 ssss-split -w
+func WithToken(token string) Option {
+	return func(conf *config) {
+		conf.token = token
+	}
+}
+
+// WithoutDiffusion 不使用扩散层，对应 ssss-split -D / ssss-combine -D
+func WithoutDiffusion() Option {
+	return func(conf *config) {
+		conf.diffusion = false
+	}
+}
+
+func newConfig(opts []Option) *config {
+	conf := &config{diffusion: true}
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	return conf
+}
+
+// Split 将秘密拆分为 number 个 ssss 格式份额，任意 threshold 个份额可以恢复秘密
+// 秘密按大端序转换为域中元素，长度不足安全级别时相当于在左侧补0
+func Split(secret []byte, threshold, number int, opts ...Option) ([]*Share, error) {
+	conf := newConfig(opts)
+	if conf.degree == 0 {
+		conf.degree = 8 * len(secret)
+	}
+	if err := tnCheck(threshold, number); err != nil {
+		return nil, err
+	}
+
+	field, err := NewField(conf.degree)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) > conf.degree/8 {
+		return nil, fmt.Errorf("secret too long, security level %d supports at most %d bytes", conf.degree, conf.degree/8)
+	}
+
+	coefficients := make([]*big.Int, 0, threshold)
+	secretInt := new(big.Int).SetBytes(secret)
+	if conf.diffusion && conf.degree >= minDiffusionDegree {
+		secretInt = diffuse(secretInt, conf.degree)
+	}
+	coefficients = append(coefficients, secretInt)
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(conf.degree))
+	for i := 1; i < threshold; i++ {
+		coefficient, e := rand.Int(rand.Reader, limit)
+		if e != nil {
+			return nil, fmt.Errorf("get random coefficient failed: %w", e)
+		}
+		coefficients = append(coefficients, coefficient)
+	}
+
+	width := len(strconv.Itoa(number))
+	shares := make([]*Share, 0, number)
+	for i := 1; i <= number; i++ {
+		shares = append(shares, &Share{
+			Token:  conf.token,
+			Index:  i,
+			Y:      field.Horner(coefficients, big.NewInt(int64(i))),
+			Degree: conf.degree,
+			width:  width,
+		})
+	}
+
+	return shares, nil
+}
+
+// Combine 使用 ssss 份额恢复秘密，返回安全级别长度的秘密字节(大端序，左侧可能补0)
+// 传入份额的个数必须是拆分时的门限值，因为 ssss 的多项式次数与门限值相关
+func Combine(shares []*Share, opts ...Option) ([]byte, error) {
+	conf := newConfig(opts)
+	if len(shares) < MinThreshold {
+		return nil, fmt.Errorf("%w: need at least %d shares", SharesNotMatch, MinThreshold)
+	}
+
+	degree := shares[0].Degree
+	indexes := make(map[int]struct{}, len(shares))
+	for _, share := range shares {
+		if share.Degree != degree {
+			return nil, fmt.Errorf("%w: shares have different security level", SharesNotMatch)
+		}
+		if _, ok := indexes[share.Index]; ok {
+			return nil, fmt.Errorf("%w: duplicated share index %d", SharesNotMatch, share.Index)
+		}
+		indexes[share.Index] = struct{}{}
+	}
+
+	field, err := NewField(degree)
+	if err != nil {
+		return nil, err
+	}
+
+	// ssss 的多项式是首一的，先减去 x^t 项，再用拉格朗日插值求 f(0)
+	threshold := len(shares)
+	xs := make([]*big.Int, 0, threshold)
+	ys := make([]*big.Int, 0, threshold)
+	for _, share := range shares {
+		x := big.NewInt(int64(share.Index))
+		xs = append(xs, x)
+		ys = append(ys, field.Add(share.Y, field.Pow(x, threshold)))
+	}
+
+	secret := new(big.Int)
+	for i := range xs {
+		numerator, denominator := big.NewInt(1), big.NewInt(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			numerator = field.Mul(numerator, xs[j])
+			denominator = field.Mul(denominator, field.Add(xs[j], xs[i]))
+		}
+
+		inverse, e := field.Invert(denominator)
+		if e != nil {
+			return nil, e
+		}
+		secret = field.Add(secret, field.Mul(ys[i], field.Mul(numerator, inverse)))
+	}
+
+	if conf.diffusion && degree >= minDiffusionDegree {
+		secret = undiffuse(secret, degree)
+	}
+
+	return secret.FillBytes(make([]byte, degree/8)), nil
+}
+
+func tnCheck(threshold, number int) error {
+	if threshold < MinThreshold {
+		return fmt.Errorf("threshold(%d) can not smaller than %d", threshold, MinThreshold)
+	}
+	if threshold > number {
+		return fmt.Errorf("threshold(%d) can not bigger than shares number(%d)", threshold, number)
+	}
+
+	return nil
+}
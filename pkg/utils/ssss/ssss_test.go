@@ -0,0 +1,111 @@
+package ssss
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldInvert(t *testing.T) {
+	for _, degree := range []int{8, 72, 128, MaxDegree} {
+		field, err := NewField(degree)
+		require.NoError(t, err)
+
+		x := big.NewInt(0x5a)
+		inverse, err := field.Invert(x)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), field.Mul(x, inverse).Int64())
+	}
+}
+
+func TestDiffusion(t *testing.T) {
+	for _, degree := range []int{64, 72, 136, MaxDegree} {
+		secret := new(big.Int).SetBytes(bytes.Repeat([]byte{0xa5}, degree/8))
+		diffused := diffuse(secret, degree)
+		assert.NotEqual(t, 0, diffused.Cmp(secret))
+		assert.LessOrEqual(t, diffused.BitLen(), degree)
+		assert.Equal(t, 0, undiffuse(diffused, degree).Cmp(secret))
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("my secret root password")
+	shares, err := Split(secret, 3, 5, WithToken("root"))
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	parsed := make([]*Share, 0, 3)
+	for _, share := range []*Share{shares[4], shares[0], shares[2]} {
+		p, e := ParseShare(share.String())
+		require.NoError(t, e)
+		assert.Equal(t, "root", p.Token)
+		assert.Equal(t, share.String(), p.String())
+		parsed = append(parsed, p)
+	}
+
+	result, err := Combine(parsed)
+	require.NoError(t, err)
+	assert.Equal(t, secret, result)
+
+	// 门限值不匹配时无法恢复
+	result, err = Combine(parsed[:2])
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, result)
+}
+
+// TestCombineKnownAnswer 合并 ssss-split -t 3 -n 5 生成的份额，来自 ssss 文档中的示例，
+// 用于确认与 ssss 的域、份额格式和扩散层一致，而不只是与本包的 Split 一致
+func TestCombineKnownAnswer(t *testing.T) {
+	lines := []string{
+		"1-1c41ef496eccfbeba439714085df8437236298da8dd824",
+		"2-fbc74a03a50e14ab406c225afb5f45c40ae11976d2b665",
+		"3-fa1c3a9c6df8af0779c36de6c33f6e36e989d0e0b91309",
+		"4-468de7d6eb36674c9cf008c8e8fc8c566537ad6301eb9e",
+	}
+	shares := make([]*Share, 0, len(lines))
+	for _, line := range lines {
+		share, err := ParseShare(line)
+		require.NoError(t, err)
+		assert.Equal(t, 184, share.Degree)
+		shares = append(shares, share)
+	}
+
+	for _, combination := range [][]int{{0, 1, 2}, {3, 0, 2}, {1, 3, 0}, {2, 3, 1}} {
+		selected := make([]*Share, 0, len(combination))
+		for _, i := range combination {
+			selected = append(selected, shares[i])
+		}
+		result, err := Combine(selected)
+		require.NoError(t, err)
+		assert.Equal(t, "my secret root password", string(result), combination)
+	}
+}
+
+func TestSplitCombineSecurity(t *testing.T) {
+	secret := []byte("short")
+	shares, err := Split(secret, 2, 12, WithSecurity(256), WithoutDiffusion())
+	require.NoError(t, err)
+	assert.Equal(t, "01", shares[0].String()[:2])
+	assert.Len(t, shares[0].String(), len("01-")+256/4)
+
+	result, err := Combine(shares[10:], WithoutDiffusion())
+	require.NoError(t, err)
+	assert.Equal(t, secret, bytes.TrimLeft(result, "\x00"))
+
+	_, err = Split(secret, 2, 3, WithSecurity(16))
+	assert.Error(t, err)
+}
+
+func TestParseShare(t *testing.T) {
+	_, err := ParseShare("1-abc")
+	assert.ErrorIs(t, err, InvalidShare)
+	_, err = ParseShare("abc")
+	assert.ErrorIs(t, err, InvalidShare)
+	share, err := ParseShare("3-00ff")
+	require.NoError(t, err)
+	assert.Equal(t, 3, share.Index)
+	assert.Equal(t, 16, share.Degree)
+}
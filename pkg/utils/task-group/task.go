@@ -37,7 +37,8 @@ func newTask(t Task) *task {
 }
 
 func (t *task) Start() {
-	if Status(t.status.Load()) == Running {
+	if !t.status.CompareAndSwap(int32(Ready), int32(Running)) &&
+		!t.status.CompareAndSwap(int32(Stopped), int32(Running)) {
 		log.Warnf("task(%q) is running", t.Name())
 		return
 	}
@@ -1,18 +1,40 @@
 package taskgroup
 
 import (
+	"fmt"
 	"sync"
 
 	"shamir/pkg/utils/log"
 )
 
+// errorsBuffer 任务启动失败的错误的缓冲，缓冲满时只记录日志
+const errorsBuffer = 16
+
 type TaskGroup struct {
 	taskMap *sync.Map
+	errors  chan error
 }
 
 func NewTaskGroup() *TaskGroup {
 	return &TaskGroup{
 		taskMap: &sync.Map{},
+		errors:  make(chan error, errorsBuffer),
+	}
+}
+
+// Errors 返回任务启动失败或运行中退出时的错误，调用者可以据此停止所有任务
+func (tg *TaskGroup) Errors() <-chan error {
+	return tg.errors
+}
+
+// run 运行任务，任务返回错误时发送到 Errors
+func (tg *TaskGroup) run(t *task) {
+	t.Start()
+	if err := t.Error(); err != nil {
+		select {
+		case tg.errors <- fmt.Errorf("task %q failed: %w", t.Name(), err):
+		default:
+		}
 	}
 }
 
@@ -39,7 +61,7 @@ func (tg *TaskGroup) Start(name string) bool {
 	}
 
 	log.Infof("starting task%q", name)
-	go t.(*task).Start()
+	go tg.run(t.(*task))
 	return true
 }
 
@@ -84,7 +106,7 @@ func (tg *TaskGroup) StartAll() {
 		}
 
 		log.Infof("starting task%q", t.Name())
-		go t.Start()
+		go tg.run(t)
 		return true
 	})
 }
@@ -1,7 +1,9 @@
 package taskgroup
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -71,3 +73,31 @@ func TestTaskGroup(t *testing.T) {
 
 	tg.DeleteAll()
 }
+
+type failedTask struct{}
+
+func (failedTask) Name() string {
+	return "failed"
+}
+
+func (failedTask) Start() error {
+	return errors.New("listen failed")
+}
+
+func (failedTask) Stop() {}
+
+func TestTaskGroupErrors(t *testing.T) {
+	tg := NewTaskGroup()
+	assert.True(t, tg.Add(failedTask{}))
+	assert.True(t, tg.Add(newTestTask("running")))
+	tg.StartAll()
+
+	select {
+	case err := <-tg.Errors():
+		assert.ErrorContains(t, err, "listen failed")
+		assert.ErrorContains(t, err, `"failed"`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("error of failed task not reported")
+	}
+	tg.StopAll()
+}
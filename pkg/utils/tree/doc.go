@@ -0,0 +1,4 @@
+// Package tree 将多个文件和目录作为一个秘密拆分
+// 加密时把输入打包为确定的 tar 流：按名称排序，属主固定为0，只保留权限位和精确到秒的修改时间，
+// 同样的输入总是得到同样的字节；解密时在事务中还原文件树，拒绝绝对路径、.. 和经过符号链接的路径 /*
+package tree
@@ -0,0 +1,240 @@
+package tree
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"shamir/pkg/utils/secure"
+)
+
+// dirPermission 还原时创建的目录，还原结束后才改为归档中的权限
+const dirPermission = 0700
+
+var (
+	InvalidArchive = errors.New("invalid file tree archive")
+	UnsafePath     = errors.New("unsafe path in archive")
+	extractAborted = errors.New("extract aborted")
+)
+
+// entry 提交后需要设置的权限、修改时间或创建的符号链接
+type entry struct {
+	name     string
+	mode     os.FileMode
+	modTime  time.Time
+	linkname string
+}
+
+// Extractor 将 tar 流还原到目录 dir 中。普通文件写入事务 tx，校验秘密后由调用者提交，
+// 提交后 Restore 创建符号链接并设置权限和修改时间。符号链接最后创建，且归档中的路径不能经过符号链接，
+// 所以不会写到 dir 之外
+type Extractor struct {
+	dir string
+	tx  *secure.Transaction
+
+	files, dirs, links []entry
+	// names 归档中已经出现的路径，值为是否是目录
+	names map[string]bool
+	// created 还原时创建的目录，回滚时删除
+	created []string
+
+	pipe *io.PipeWriter
+	done chan error
+	// closed Writer 已经关闭，err 是后台还原的结果
+	closed bool
+	err    error
+}
+
+func NewExtractor(dir string, tx *secure.Transaction) *Extractor {
+	return &Extractor{dir: filepath.Clean(dir), tx: tx, names: map[string]bool{}}
+}
+
+// Writer 返回写入 tar 流的 writer，在后台还原，Close 等待还原结束并返回还原的错误
+func (e *Extractor) Writer() io.WriteCloser {
+	reader, writer := io.Pipe()
+	e.pipe = writer
+	e.done = make(chan error, 1)
+	go func() {
+		err := e.Extract(reader)
+		reader.CloseWithError(err)
+		e.done <- err
+	}()
+	return e
+}
+
+func (e *Extractor) Write(p []byte) (int, error) {
+	return e.pipe.Write(p)
+}
+
+func (e *Extractor) Close() error {
+	return e.stop(nil)
+}
+
+// stop 关闭 tar 流并等待后台的还原结束，可以重复调用
+func (e *Extractor) stop(cause error) error {
+	if e.pipe == nil || e.closed {
+		return e.err
+	}
+	e.closed = true
+	_ = e.pipe.CloseWithError(cause)
+	e.err = <-e.done
+	return e.err
+}
+
+// Extract 读取整个 tar 流并写入事务，归档结束后的数据也会被读完
+func (e *Extractor) Extract(reader io.Reader) error {
+	if err := e.mkdir(e.dir); err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", InvalidArchive, err)
+		}
+		if err = e.extract(tarReader, header); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.Copy(io.Discard, reader)
+	return err
+}
+
+func (e *Extractor) extract(tarReader *tar.Reader, header *tar.Header) error {
+	name, err := e.target(header.Name, header.Typeflag == tar.TypeDir)
+	if err != nil {
+		return err
+	}
+	item := entry{name: name, mode: os.FileMode(header.Mode).Perm(), modTime: header.ModTime}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err = e.mkdir(name); err != nil {
+			return err
+		}
+		e.dirs = append(e.dirs, item)
+	case tar.TypeReg:
+		if err = e.mkdir(filepath.Dir(name)); err != nil {
+			return err
+		}
+		f, err := e.tx.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(f, tarReader); err != nil {
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+		e.files = append(e.files, item)
+	case tar.TypeSymlink:
+		if header.Linkname == "" {
+			return fmt.Errorf("%w: empty link of %s", InvalidArchive, header.Name)
+		}
+		item.linkname = header.Linkname
+		e.links = append(e.links, item)
+	default:
+		return fmt.Errorf("%w: %s of type %q", UnsupportedFile, header.Name, header.Typeflag)
+	}
+	return nil
+}
+
+// target 归档中的路径在 dir 中对应的路径，路径必须是相对的、不包含 ..，不能重复，也不能经过符号链接或文件
+func (e *Extractor) target(name string, isDir bool) (string, error) {
+	cleaned := path.Clean(strings.TrimSuffix(name, "/"))
+	if name == "" || path.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %q", UnsafePath, name)
+	}
+
+	for parent := path.Dir(cleaned); parent != "."; parent = path.Dir(parent) {
+		if dir, ok := e.names[parent]; ok && !dir {
+			return "", fmt.Errorf("%w: %q is under a file or symbolic link", UnsafePath, name)
+		}
+	}
+	if _, ok := e.names[cleaned]; ok {
+		return "", fmt.Errorf("%w: %s", DuplicateName, cleaned)
+	}
+	e.names[cleaned] = isDir
+	return filepath.Join(e.dir, filepath.FromSlash(cleaned)), nil
+}
+
+// mkdir 创建不存在的目录和上级目录。dir 之内已存在的必须是目录，不能是符号链接
+func (e *Extractor) mkdir(dir string) error {
+	stat := os.Stat
+	if strings.HasPrefix(dir, e.dir+string(filepath.Separator)) {
+		stat = os.Lstat
+	}
+	info, err := stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%w: %s is not a directory", UnsafePath, dir)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err = e.mkdir(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err = os.Mkdir(dir, dirPermission); err != nil {
+		return err
+	}
+	e.created = append(e.created, dir)
+	return nil
+}
+
+// Restore 提交事务后创建符号链接，设置文件和目录的权限和修改时间，目录从深到浅设置
+func (e *Extractor) Restore() error {
+	for _, link := range e.links {
+		if err := os.Symlink(link.linkname, link.name); err != nil {
+			return err
+		}
+		times := []unix.Timespec{unix.NsecToTimespec(link.modTime.UnixNano()), unix.NsecToTimespec(link.modTime.UnixNano())}
+		if err := unix.UtimesNanoAt(unix.AT_FDCWD, link.name, times, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return err
+		}
+	}
+	for _, file := range e.files {
+		if err := setMeta(file); err != nil {
+			return err
+		}
+	}
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		if err := setMeta(e.dirs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setMeta(item entry) error {
+	if err := os.Chmod(item.name, item.mode); err != nil {
+		return err
+	}
+	return os.Chtimes(item.name, item.modTime, item.modTime)
+}
+
+// Rollback 停止后台的还原，回滚事务并删除创建的目录，目录不为空时保留
+func (e *Extractor) Rollback() {
+	_ = e.stop(extractAborted)
+	e.tx.Rollback()
+	for i := len(e.created) - 1; i >= 0; i-- {
+		_ = os.Remove(e.created[i])
+	}
+}
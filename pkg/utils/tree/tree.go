@@ -0,0 +1,157 @@
+package tree
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	UnsupportedFile = errors.New("unsupported file type")
+	DuplicateName   = errors.New("duplicate name")
+)
+
+// root 一个输入，name 是它在归档中的名称
+type root struct {
+	path string
+	name string
+}
+
+// NewReader 检查输入后返回读取 tar 流的 reader，tar 流在后台写入，读取结束或关闭 reader 后停止
+func NewReader(paths []string) (io.ReadCloser, error) {
+	roots, err := getRoots(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(write(writer, roots))
+	}()
+	return reader, nil
+}
+
+// Write 将输入的文件和目录打包为 tar 写入 writer，每个输入以其文件名位于归档的顶层
+func Write(writer io.Writer, paths []string) error {
+	roots, err := getRoots(paths)
+	if err != nil {
+		return err
+	}
+	return write(writer, roots)
+}
+
+// getRoots 输入按归档中的名称排序，名称不能重复，输入本身是符号链接时打包链接指向的文件或目录
+func getRoots(paths []string) ([]root, error) {
+	roots := make([]root, 0, len(paths))
+	names := make(map[string]string, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Base(abs)
+		if name == string(filepath.Separator) {
+			return nil, fmt.Errorf("invalid input %q, can not be the root directory", p)
+		}
+		if exist, ok := names[name]; ok {
+			return nil, fmt.Errorf("%w: %q and %q are both %s in archive", DuplicateName, exist, p, name)
+		}
+		names[name] = p
+
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil, fmt.Errorf("%w: %s", UnsupportedFile, p)
+		}
+		roots = append(roots, root{path: resolved, name: name})
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].name < roots[j].name
+	})
+	return roots, nil
+}
+
+func write(writer io.Writer, roots []root) error {
+	tarWriter := tar.NewWriter(writer)
+	for _, r := range roots {
+		// WalkDir 按文件名的字典序遍历，不跟随符号链接
+		err := filepath.WalkDir(r.path, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(r.path, p)
+			if err != nil {
+				return err
+			}
+			return writeEntry(tarWriter, p, filepath.ToSlash(filepath.Join(r.name, rel)))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+func writeEntry(tarWriter *tar.Writer, source, name string) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+
+	// 不记录属主和访问时间，修改时间精确到秒，保证同样的输入得到同样的归档
+	header := &tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime().Truncate(time.Second),
+	}
+	switch {
+	case info.Mode().IsRegular():
+		header.Typeflag = tar.TypeReg
+		header.Size = info.Size()
+	case info.IsDir():
+		header.Typeflag = tar.TypeDir
+		header.Name += "/"
+	case info.Mode()&fs.ModeSymlink != 0:
+		header.Typeflag = tar.TypeSymlink
+		if header.Linkname, err = os.Readlink(source); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %s", UnsupportedFile, source)
+	}
+	if err = tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// 文件在打包时被修改，长度与头部不一致时返回错误
+	written, err := io.Copy(tarWriter, io.LimitReader(f, header.Size+1))
+	if err != nil {
+		return fmt.Errorf("read %s failed: %w", source, err)
+	}
+	if written != header.Size {
+		return fmt.Errorf("read %s failed: file size changed", source)
+	}
+	return nil
+}
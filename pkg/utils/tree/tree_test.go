@@ -0,0 +1,174 @@
+package tree
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"shamir/pkg/utils/secure"
+)
+
+// makeTree 创建包含子目录、不同权限的文件和符号链接的目录
+func makeTree(t *testing.T) (string, string) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "pki")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "private"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), []byte("certificate"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "private", "ca.key"), []byte("private key"), 0600))
+	require.NoError(t, os.Symlink("ca.pem", filepath.Join(dir, "cert.pem")))
+	file := filepath.Join(base, "token.txt")
+	require.NoError(t, os.WriteFile(file, []byte("token"), 0640))
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, name := range []string{filepath.Join(dir, "ca.pem"), filepath.Join(dir, "private", "ca.key"),
+		filepath.Join(dir, "private"), dir, file} {
+		require.NoError(t, os.Chtimes(name, modTime, modTime))
+	}
+	return dir, file
+}
+
+func TestWriteDeterministic(t *testing.T) {
+	dir, file := makeTree(t)
+
+	var first, second bytes.Buffer
+	require.NoError(t, Write(&first, []string{dir, file}))
+	// 输入的顺序和 atime 不影响归档
+	_, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	require.NoError(t, err)
+	require.NoError(t, Write(&second, []string{file, dir}))
+	assert.Equal(t, first.Bytes(), second.Bytes())
+
+	var names []string
+	reader := tar.NewReader(&first)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Zero(t, header.Uid)
+		assert.Zero(t, header.Gid)
+		assert.Empty(t, header.Uname)
+		names = append(names, header.Name)
+	}
+	assert.Equal(t, []string{"pki/", "pki/ca.pem", "pki/cert.pem", "pki/private/", "pki/private/ca.key", "token.txt"}, names)
+}
+
+func TestWriteInvalid(t *testing.T) {
+	dir, file := makeTree(t)
+	other := filepath.Join(t.TempDir(), "pki")
+	require.NoError(t, os.Mkdir(other, 0700))
+
+	assert.ErrorIs(t, Write(io.Discard, []string{dir, other}), DuplicateName)
+	assert.Error(t, Write(io.Discard, []string{file + ".not-exist"}))
+	assert.Error(t, Write(io.Discard, []string{"/"}))
+}
+
+func TestExtract(t *testing.T) {
+	dir, file := makeTree(t)
+	reader, err := NewReader([]string{dir, file})
+	require.NoError(t, err)
+	defer reader.Close()
+
+	output := filepath.Join(t.TempDir(), "restored", "tree")
+	tx := secure.NewTransaction()
+	extractor := NewExtractor(output, tx)
+	writer := extractor.Writer()
+	_, err = io.Copy(writer, reader)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	// 提交前只有临时文件
+	_, err = os.Stat(filepath.Join(output, "token.txt"))
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, tx.Commit())
+	require.NoError(t, extractor.Restore())
+
+	for _, name := range []string{"pki", "pki/ca.pem", "pki/private", "pki/private/ca.key", "token.txt"} {
+		source := filepath.Join(filepath.Dir(dir), name)
+		target := filepath.Join(output, name)
+		want, err := os.Stat(source)
+		require.NoError(t, err)
+		got, err := os.Stat(target)
+		require.NoError(t, err)
+		assert.Equal(t, want.Mode(), got.Mode(), name)
+		assert.Equal(t, want.ModTime(), got.ModTime(), name)
+		if want.Mode().IsRegular() {
+			wantData, _ := os.ReadFile(source)
+			gotData, _ := os.ReadFile(target)
+			assert.Equal(t, wantData, gotData, name)
+		}
+	}
+	link, err := os.Readlink(filepath.Join(output, "pki", "cert.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, "ca.pem", link)
+}
+
+func archive(t *testing.T, headers ...*tar.Header) []byte {
+	var buffer bytes.Buffer
+	writer := tar.NewWriter(&buffer)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(header.Name))
+		}
+		require.NoError(t, writer.WriteHeader(header))
+		if header.Typeflag == tar.TypeReg {
+			_, err := writer.Write([]byte(header.Name))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	cases := map[string][]*tar.Header{
+		"parent":   {{Typeflag: tar.TypeReg, Name: "../escape", Mode: 0600}},
+		"nested":   {{Typeflag: tar.TypeReg, Name: "a/../../escape", Mode: 0600}},
+		"absolute": {{Typeflag: tar.TypeReg, Name: "/tmp/escape", Mode: 0600}},
+		"symlink": {
+			{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "/tmp"},
+			{Typeflag: tar.TypeReg, Name: "link/escape", Mode: 0600},
+		},
+		"duplicate": {
+			{Typeflag: tar.TypeReg, Name: "a", Mode: 0600},
+			{Typeflag: tar.TypeReg, Name: "./a", Mode: 0600},
+		},
+		"device": {{Typeflag: tar.TypeChar, Name: "tty", Mode: 0600}},
+	}
+	for name, headers := range cases {
+		t.Run(name, func(t *testing.T) {
+			base := t.TempDir()
+			output := filepath.Join(base, "out")
+			extractor := NewExtractor(output, secure.NewTransaction())
+			assert.Error(t, extractor.Extract(bytes.NewReader(archive(t, headers...))))
+
+			// 回滚后不留下任何文件
+			extractor.Rollback()
+			entries, err := os.ReadDir(base)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestExtractInvalid(t *testing.T) {
+	base := t.TempDir()
+	extractor := NewExtractor(filepath.Join(base, "out"), secure.NewTransaction())
+	writer := extractor.Writer()
+	_, err := writer.Write(bytes.Repeat([]byte("not a tar archive"), 100))
+	assert.ErrorIs(t, err, InvalidArchive)
+	assert.ErrorIs(t, writer.Close(), InvalidArchive)
+
+	extractor.Rollback()
+	entries, err := os.ReadDir(base)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,159 @@
+package shamir
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/pkg/errors"
+
+	"shamir/pkg/utils/code"
+	"shamir/pkg/utils/secure"
+	scheme "shamir/pkg/utils/shamir"
+)
+
+const (
+	MinThreshold = scheme.MinThreshold
+	// MaxShares 与命令行加密的限制相同
+	MaxShares = 1000
+)
+
+var (
+	InvalidThreshold = errors.New("invalid threshold or number of shares")
+	InvalidShare     = errors.New("invalid share")
+	NotEnoughShares  = errors.New("not enough shares")
+	SharesNotMatch   = errors.New("shares are not from the same secret")
+	DuplicateShare   = errors.New("duplicate share")
+	// HashCheckFailed 还原出的秘密与其中的hash值不一致，份额有误或数量不足
+	HashCheckFailed = scheme.HashCheckFailed
+)
+
+// ShareError 与第 Index 个份额有关的错误
+type ShareError struct {
+	Index int
+	Err   error
+}
+
+func (e *ShareError) Error() string {
+	return fmt.Sprintf("share %d: %v", e.Index, e.Err)
+}
+
+func (e *ShareError) Unwrap() error {
+	return e.Err
+}
+
+type config struct {
+	fast bool
+}
+
+type Option func(conf *config)
+
+// WithoutFastPrime 每段都实时计算素数而不是使用预置的素数，每段更长但拆分更慢
+func WithoutFastPrime() Option {
+	return func(conf *config) {
+		conf.fast = false
+	}
+}
+
+// Split 将秘密拆分为 n 个份额，任意 t 个份额可以还原出秘密，秘密可以为空
+func Split(secret []byte, t, n int, opts ...Option) ([]Share, error) {
+	conf := &config{fast: true}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	if t < MinThreshold || t > n || n > MaxShares {
+		return nil, fmt.Errorf("%w: t=%d, n=%d", InvalidThreshold, t, n)
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i].threshold = t
+	}
+	var primes []*big.Int
+
+	encoder := code.NewSecretEncoder(bytes.NewReader(secret), scheme.SplitLen(conf.fast))
+	for {
+		chunk, err := encoder.Read()
+		if err != nil {
+			return nil, err
+		}
+		last := chunk == nil
+		if last {
+			chunk = encoder.GetHash()
+		}
+
+		keys, prime, err := scheme.Encrypt(chunk, t, n, conf.fast)
+		secure.WipeInt(chunk)
+		if err != nil {
+			return nil, err
+		}
+		primes = append(primes, prime)
+		for i, key := range keys {
+			shares[i].x = append(shares[i].x, key.X)
+			shares[i].y = append(shares[i].y, key.Y)
+		}
+		if last {
+			break
+		}
+	}
+
+	for i := range shares {
+		shares[i].primes = primes
+	}
+	return shares, nil
+}
+
+// Combine 使用份额还原秘密并校验hash值，份额数量不能少于拆分时的门限值。
+// 门限值未知时（命令行密钥解析的份额）数量不足会返回 HashCheckFailed，
+// 使用预置素数拆分的不同秘密的份额无法在解密前区分，同样返回 HashCheckFailed
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) < MinThreshold {
+		return nil, fmt.Errorf("%w: need at least %d shares, got %d", NotEnoughShares, MinThreshold, len(shares))
+	}
+
+	for i, share := range shares {
+		if err := share.check(); err != nil {
+			return nil, &ShareError{Index: i, Err: err}
+		}
+		if !share.sameSecret(shares[0]) {
+			return nil, &ShareError{Index: i, Err: SharesNotMatch}
+		}
+	}
+	threshold := 0
+	for _, share := range shares {
+		if share.threshold > threshold {
+			threshold = share.threshold
+		}
+	}
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("%w: need %d shares, got %d", NotEnoughShares, threshold, len(shares))
+	}
+
+	// 扩容时清零旧的数据，不在内存中留下秘密的副本
+	secret := &secure.Buffer{}
+	decoder := code.NewSecretDecoder(secret)
+	primes := shares[0].primes
+	for chunk, prime := range primes {
+		keys := make([]code.Key, 0, len(shares))
+		for _, share := range shares {
+			keys = append(keys, code.Key{X: share.x[chunk], Y: share.y[chunk]})
+		}
+		if err := scheme.CheckKeys(keys, prime); err != nil {
+			return nil, fmt.Errorf("%w: %v", DuplicateShare, err)
+		}
+
+		data, err := scheme.Decrypt(keys, prime)
+		if err != nil {
+			return nil, err
+		}
+		// 份额有误时解密出的数据可能无法写入，同样视为校验失败
+		if err = decoder.Write(data); err != nil {
+			return nil, HashCheckFailed
+		}
+	}
+
+	if err := decoder.HashCheck(); err != nil {
+		return nil, HashCheckFailed
+	}
+	return secret.Bytes(), nil
+}
@@ -0,0 +1,154 @@
+package shamir
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCombine(t *testing.T) {
+	for _, secret := range [][]byte{
+		{},
+		[]byte("my secret root password"),
+		// 超过一段的长度，需要分段加密
+		bytes.Repeat([]byte("0123456789abcdef"), 64),
+	} {
+		shares, err := Split(secret, 3, 5)
+		require.NoError(t, err)
+		require.Len(t, shares, 5)
+		assert.Equal(t, 3, shares[0].Threshold())
+
+		result, err := Combine([]Share{shares[4], shares[0], shares[2]})
+		require.NoError(t, err)
+		assert.Equal(t, string(secret), string(result))
+		result, err = Combine(shares)
+		require.NoError(t, err)
+		assert.Equal(t, string(secret), string(result))
+
+		_, err = Combine(shares[:2])
+		assert.ErrorIs(t, err, NotEnoughShares)
+	}
+}
+
+func TestSplitNoFast(t *testing.T) {
+	secret := []byte("slow secret")
+	shares, err := Split(secret, 2, 2, WithoutFastPrime())
+	require.NoError(t, err)
+	result, err := Combine(shares)
+	require.NoError(t, err)
+	assert.Equal(t, secret, result)
+}
+
+func TestSplitInvalid(t *testing.T) {
+	for _, tn := range [][2]int{{1, 3}, {4, 3}, {2, MaxShares + 1}} {
+		_, err := Split([]byte("secret"), tn[0], tn[1])
+		assert.ErrorIs(t, err, InvalidThreshold)
+	}
+}
+
+func TestShareText(t *testing.T) {
+	secret := []byte("text secret")
+	shares, err := Split(secret, 2, 3)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(shares)
+	require.NoError(t, err)
+	var parsed []Share
+	require.NoError(t, json.Unmarshal(data, &parsed))
+	result, err := Combine(parsed[1:])
+	require.NoError(t, err)
+	assert.Equal(t, secret, result)
+
+	// 与命令行的密钥格式互相转换
+	x, y, necessary := shares[0].Keys()
+	share, err := ParseKeys(x, y, necessary)
+	require.NoError(t, err)
+	assert.Equal(t, 0, share.Threshold())
+	result, err = Combine([]Share{share, shares[2]})
+	require.NoError(t, err)
+	assert.Equal(t, secret, result)
+
+	for _, text := range []string{"", "shamir1:2:a:b", "shamir2:2:a:b:c", "shamir1:x:a:b:c", "shamir1:2:a:b:!"} {
+		assert.ErrorIs(t, share.UnmarshalText([]byte(text)), InvalidShare, text)
+	}
+	_, err = Share{}.MarshalText()
+	assert.ErrorIs(t, err, InvalidShare)
+}
+
+func TestCombineErrors(t *testing.T) {
+	secret := []byte("combine secret")
+	shares, err := Split(secret, 2, 3)
+	require.NoError(t, err)
+	// 预置素数相同，只有不使用预置素数拆分的份额可以在解密前发现不匹配
+	other, err := Split([]byte("another secret"), 2, 3, WithoutFastPrime())
+	require.NoError(t, err)
+
+	_, err = Combine([]Share{shares[0], other[1]})
+	assert.ErrorIs(t, err, SharesNotMatch)
+	var shareErr *ShareError
+	require.ErrorAs(t, err, &shareErr)
+	assert.Equal(t, 1, shareErr.Index)
+
+	other, err = Split([]byte("another secret"), 2, 3)
+	require.NoError(t, err)
+	_, err = Combine([]Share{shares[0], other[1]})
+	assert.ErrorIs(t, err, HashCheckFailed)
+
+	_, err = Combine([]Share{shares[0], shares[0]})
+	assert.ErrorIs(t, err, DuplicateShare)
+
+	// 篡改份额后无法通过hash校验
+	x, _, necessary := shares[1].Keys()
+	_, y, _ := shares[2].Keys()
+	tampered, err := ParseKeys(x, y, necessary)
+	require.NoError(t, err)
+	_, err = Combine([]Share{shares[0], tampered})
+	assert.ErrorIs(t, err, HashCheckFailed)
+
+	// 门限值未知时数量不足同样无法通过hash校验
+	shares, err = Split(secret, 3, 3)
+	require.NoError(t, err)
+	x, y, necessary = shares[0].Keys()
+	first, err := ParseKeys(x, y, necessary)
+	require.NoError(t, err)
+	x, y, necessary = shares[1].Keys()
+	second, err := ParseKeys(x, y, necessary)
+	require.NoError(t, err)
+	_, err = Combine([]Share{first, second})
+	assert.ErrorIs(t, err, HashCheckFailed)
+}
+
+func TestSplitWriterCombineReader(t *testing.T) {
+	secret := bytes.Repeat([]byte("streaming secret "), 200)
+	sinks := []*bytes.Buffer{{}, {}, {}}
+	necessary := &bytes.Buffer{}
+	writer, err := NewSplitWriter(2, 3, []io.Writer{sinks[0], sinks[1], sinks[2]}, necessary)
+	require.NoError(t, err)
+	_, err = io.Copy(writer, iotest.HalfReader(bytes.NewReader(secret)))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	reader, err := NewCombineReader([]io.Reader{bytes.NewReader(sinks[2].Bytes()), bytes.NewReader(sinks[0].Bytes())},
+		bytes.NewReader(necessary.Bytes()))
+	require.NoError(t, err)
+	result, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, secret, result)
+
+	// 持有人的密钥链被截断
+	reader, err = NewCombineReader([]io.Reader{bytes.NewReader(sinks[2].Bytes()), bytes.NewReader(sinks[0].Bytes()[:40])},
+		bytes.NewReader(necessary.Bytes()))
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+
+	_, err = NewSplitWriter(2, 3, []io.Writer{sinks[0]}, necessary)
+	assert.Error(t, err)
+	_, err = NewCombineReader([]io.Reader{sinks[0]}, necessary)
+	assert.ErrorIs(t, err, NotEnoughShares)
+}
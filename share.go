@@ -0,0 +1,121 @@
+package shamir
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"shamir/pkg/utils/code"
+)
+
+const (
+	// textPrefix 份额文本的前缀和版本，格式为 "shamir1:threshold:x:y:necessary"
+	textPrefix = "shamir1"
+	textSplit  = ":"
+)
+
+// Share 一个持有人的份额，零值不是有效的份额
+type Share struct {
+	// threshold 为0表示未知，如从命令行密钥解析的份额
+	threshold int
+	x, y      []*big.Int
+	// primes 必须密钥，每段一个素数，所有份额相同
+	primes []*big.Int
+}
+
+// Threshold 还原秘密需要的份额数量，未知时返回0
+func (s Share) Threshold() int {
+	return s.threshold
+}
+
+// Keys 返回与命令行 shamir encrypt 输出相同格式的x密钥、y密钥和必须密钥
+func (s Share) Keys() (x, y, necessary string) {
+	return code.DecodeKeys(s.x), code.DecodeKeys(s.y), code.DecodeKeys(s.primes)
+}
+
+// ParseKeys 解析命令行 shamir encrypt 输出的x密钥、y密钥和必须密钥，得到的份额门限值未知
+func ParseKeys(x, y, necessary string) (Share, error) {
+	xKeys, xOk := code.EncodeKeys(strings.TrimSpace(x))
+	yKeys, yOk := code.EncodeKeys(strings.TrimSpace(y))
+	primes, ok := code.EncodeKeys(strings.TrimSpace(necessary))
+	if !xOk || !yOk || !ok {
+		return Share{}, fmt.Errorf("%w: invalid key encoding", InvalidShare)
+	}
+
+	s := Share{x: xKeys, y: yKeys, primes: primes}
+	if err := s.check(); err != nil {
+		return Share{}, err
+	}
+	return s, nil
+}
+
+// MarshalText 实现 encoding.TextMarshaler，文本中包含必须密钥，需要与份额一样谨慎保存
+func (s Share) MarshalText() ([]byte, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	x, y, necessary := s.Keys()
+	return []byte(strings.Join([]string{textPrefix, strconv.Itoa(s.threshold), x, y, necessary}, textSplit)), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler
+func (s *Share) UnmarshalText(text []byte) error {
+	parts := strings.Split(strings.TrimSpace(string(text)), textSplit)
+	if len(parts) != 5 || parts[0] != textPrefix {
+		return fmt.Errorf("%w: invalid syntax", InvalidShare)
+	}
+	threshold, err := strconv.Atoi(parts[1])
+	if err != nil || threshold < 0 {
+		return fmt.Errorf("%w: invalid threshold %q", InvalidShare, parts[1])
+	}
+
+	share, err := ParseKeys(parts[2], parts[3], parts[4])
+	if err != nil {
+		return err
+	}
+	share.threshold = threshold
+	*s = share
+	return nil
+}
+
+// check 检查份额的结构，x、y密钥与必须密钥的段数必须一致，最后一段是hash值的密钥
+func (s Share) check() error {
+	if len(s.primes) < 1 || len(s.x) != len(s.primes) || len(s.y) != len(s.primes) {
+		return fmt.Errorf("%w: key has %d x parts and %d y parts, but necessary key has %d parts",
+			InvalidShare, len(s.x), len(s.y), len(s.primes))
+	}
+	if s.threshold == 1 {
+		return fmt.Errorf("%w: invalid threshold %d", InvalidShare, s.threshold)
+	}
+
+	for i, prime := range s.primes {
+		if prime.Sign() <= 0 {
+			return fmt.Errorf("%w: invalid necessary key", InvalidShare)
+		}
+		if new(big.Int).Mod(s.x[i], prime).Sign() == 0 {
+			return fmt.Errorf("%w: invalid x key", InvalidShare)
+		}
+		if s.y[i].Sign() < 0 || s.y[i].Cmp(prime) >= 0 {
+			return fmt.Errorf("%w: y key out of range", InvalidShare)
+		}
+	}
+	return nil
+}
+
+// sameSecret 两个份额是否来自同一次拆分
+func (s Share) sameSecret(other Share) bool {
+	if len(s.primes) != len(other.primes) {
+		return false
+	}
+	if s.threshold != 0 && other.threshold != 0 && s.threshold != other.threshold {
+		return false
+	}
+	for i := range s.primes {
+		if s.primes[i].Cmp(other.primes[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package shamir
+
+import (
+	"fmt"
+	"io"
+
+	"shamir/pkg/utils/code"
+	scheme "shamir/pkg/utils/shamir"
+)
+
+// NewSplitWriter 流式拆分秘密，写入的秘密每满一段就加密，内存占用与秘密长度无关。
+// 每个持有人的 sink 收到x、y密钥交替排列的一条密钥链，与命令行分开写出的x、y密钥文件不同，只能用 NewCombineReader 还原；
+// necessary 收到的必须密钥与命令行的必须密钥文件格式相同。
+// 必须调用 Close 加密剩余的数据和hash值，Close 不会关闭 sinks 和 necessary
+func NewSplitWriter(t, n int, sinks []io.Writer, necessary io.Writer, opts ...Option) (io.WriteCloser, error) {
+	conf := &config{fast: true}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	if t < MinThreshold || t > n || n > MaxShares {
+		return nil, fmt.Errorf("%w: t=%d, n=%d", InvalidThreshold, t, n)
+	}
+	if len(sinks) != n {
+		return nil, fmt.Errorf("need %d sinks, got %d", n, len(sinks))
+	}
+
+	keys := make([]scheme.KeyWriter, 0, len(sinks))
+	for _, sink := range sinks {
+		keys = append(keys, &pairDecoder{keys: code.NewKeyDecoder(sink)})
+	}
+	return scheme.NewSplitWriter(t, n, conf.fast, keys, necessary)
+}
+
+// NewCombineReader 流式还原秘密，sources 是 NewSplitWriter 写出的至少门限值个持有人的密钥链。
+// 秘密在校验hash前就已经返回，读到结尾时hash校验失败返回 HashCheckFailed 而不是 io.EOF，
+// 此时已经读出的数据应当丢弃
+func NewCombineReader(sources []io.Reader, necessary io.Reader) (io.Reader, error) {
+	if len(sources) < MinThreshold {
+		return nil, fmt.Errorf("%w: need at least %d sources, got %d", NotEnoughShares, MinThreshold, len(sources))
+	}
+
+	keys := make([]scheme.KeyReader, 0, len(sources))
+	for i, source := range sources {
+		keys = append(keys, &pairEncoder{index: i, keys: code.NewKeyEncoder(source)})
+	}
+	return scheme.NewCombineReader(keys, necessary), nil
+}
+
+// pairDecoder 将密钥对的x、y交替写入一条密钥链
+type pairDecoder struct {
+	keys *code.KeyDecoder
+}
+
+func (p *pairDecoder) Write(key code.Key) error {
+	if err := p.keys.Write(key.X); err != nil {
+		return err
+	}
+	return p.keys.Write(key.Y)
+}
+
+// pairEncoder 从x、y交替的密钥链中读取密钥对
+type pairEncoder struct {
+	index int
+	keys  *code.KeyEncoder
+}
+
+func (p *pairEncoder) Read() (code.Key, bool, error) {
+	x, last, err := p.keys.Read()
+	if err != nil {
+		return code.Key{}, false, &ShareError{Index: p.index, Err: fmt.Errorf("%w: %v", InvalidShare, err)}
+	}
+	// x密钥之后一定还有y密钥
+	if last {
+		return code.Key{}, false, &ShareError{Index: p.index, Err: fmt.Errorf("%w: missing y key", InvalidShare)}
+	}
+	y, last, err := p.keys.Read()
+	if err != nil {
+		return code.Key{}, false, &ShareError{Index: p.index, Err: fmt.Errorf("%w: %v", InvalidShare, err)}
+	}
+	return code.Key{X: x, Y: y}, last, nil
+}